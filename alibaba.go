@@ -18,10 +18,20 @@ package storage
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io"
 	"io/ioutil"
+	"iter"
+	"log/slog"
 	"net/http"
 	"os"
 	pathutil "path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 )
@@ -32,19 +42,51 @@ type AlibabaCloudOSSBackend struct {
 	Client *oss.Client
 	Prefix string
 	SSE    string
+	logger *slog.Logger
 }
 
-// NewAlibabaCloudOSSBackend creates a new instance of AlibabaCloudOSSBackend
+// alibabaContextHeader carries the id of the context of a request from the OSS client, which does not accept a
+// context, to alibabaContextTransport. It is not signed, as only the x-oss- headers are.
+const alibabaContextHeader = "X-Storage-Context-Id"
+
+var (
+	// alibabaContexts holds the contexts of the requests being sent by the OSS clients, by id
+	alibabaContexts   sync.Map
+	alibabaContextIDs atomic.Uint64
+)
+
+// alibabaContextTransport sends the requests of the OSS client with the context registered by alibabaContextOptions,
+// so that they are aborted when it is done. A single transport is shared by all the requests of a backend.
+type alibabaContextTransport struct {
+	base http.RoundTripper
+}
+
+// NewAlibabaCloudOSSBackend creates a new instance of AlibabaCloudOSSBackend.
+// It panics if the backend cannot be created; NewAlibabaCloudOSSBackendE returns the error instead.
 func NewAlibabaCloudOSSBackend(bucket string, prefix string, endpoint string, sse string) *AlibabaCloudOSSBackend {
+	b, err := NewAlibabaCloudOSSBackendE(bucket, prefix, endpoint, sse)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// NewAlibabaCloudOSSBackendE creates a new instance of AlibabaCloudOSSBackend, using the credentials
+// of the ALIBABA_CLOUD_ACCESS_KEY_ID and ALIBABA_CLOUD_ACCESS_KEY_SECRET environment variables
+func NewAlibabaCloudOSSBackendE(bucket string, prefix string, endpoint string, sse string) (*AlibabaCloudOSSBackend, error) {
 	accessKeyId := os.Getenv("ALIBABA_CLOUD_ACCESS_KEY_ID")
 	accessKeySecret := os.Getenv("ALIBABA_CLOUD_ACCESS_KEY_SECRET")
 
+	if len(bucket) == 0 {
+		return nil, configError("alibaba", "missing bucket")
+	}
+
 	if len(accessKeyId) == 0 {
-		panic("ALIBABA_CLOUD_ACCESS_KEY_ID environment variable is not set")
+		return nil, configError("alibaba", "ALIBABA_CLOUD_ACCESS_KEY_ID environment variable is not set")
 	}
 
 	if len(accessKeySecret) == 0 {
-		panic("ALIBABA_CLOUD_ACCESS_KEY_SECRET environment variable is not set")
+		return nil, configError("alibaba", "ALIBABA_CLOUD_ACCESS_KEY_SECRET environment variable is not set")
 	}
 
 	if len(endpoint) == 0 {
@@ -52,15 +94,16 @@ func NewAlibabaCloudOSSBackend(bucket string, prefix string, endpoint string, ss
 		endpoint = "oss-cn-hangzhou.aliyuncs.com"
 	}
 
-	client, err := oss.New(endpoint, accessKeyId, accessKeySecret)
+	httpClient := &http.Client{Transport: alibabaContextTransport{base: tracingTransport(http.DefaultTransport)}}
+	client, err := oss.New(endpoint, accessKeyId, accessKeySecret, oss.HTTPClient(httpClient))
 
 	if err != nil {
-		panic("Failed to create OSS client: " + err.Error())
+		return nil, configError("alibaba", "failed to create OSS client for endpoint %q: %s", endpoint, err)
 	}
 
 	ossBucket, err := client.Bucket(bucket)
 	if err != nil {
-		panic("Failed to get bucket: " + err.Error())
+		return nil, configError("alibaba", "failed to get bucket %q: %s", bucket, err)
 	}
 
 	b := &AlibabaCloudOSSBackend{
@@ -69,18 +112,43 @@ func NewAlibabaCloudOSSBackend(bucket string, prefix string, endpoint string, ss
 		Prefix: cleanPrefix(prefix),
 		SSE:    sse,
 	}
-	return b
+	return b, nil
+}
+
+// SetLogger sets the logger of the failed operations on Alibaba Cloud OSS. Nothing is logged without a logger.
+func (b *AlibabaCloudOSSBackend) SetLogger(logger *slog.Logger) {
+	b.logger = logger
+}
+
+// Capabilities returns the optional features supported by Alibaba Cloud OSS
+func (b AlibabaCloudOSSBackend) Capabilities() CapabilitySet {
+	return NewCapabilitySet(
+		CapabilityContext, CapabilityStream, CapabilityRange, CapabilityMetadata,
+		CapabilityStat, CapabilityHierarchical, CapabilityPaging, CapabilityBatchDelete,
+		CapabilityCopy, CapabilityPresign, CapabilityVersioning,
+	)
 }
 
 // ListObjects lists all objects in Alibaba Cloud OSS bucket, at prefix
 func (b AlibabaCloudOSSBackend) ListObjects(prefix string) ([]Object, error) {
+	return b.ListObjectsContext(context.Background(), prefix)
+}
+
+// ListObjectsContext lists all objects in Alibaba Cloud OSS bucket, at prefix, aborting when ctx is done
+func (b AlibabaCloudOSSBackend) ListObjectsContext(ctx context.Context, prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
 	var objects []Object
+	ctxOptions, release := alibabaContextOptions(ctx)
+	defer release()
 
 	prefix = pathutil.Join(b.Prefix, prefix)
 	ossPrefix := oss.Prefix(prefix)
 	marker := oss.Marker("")
 	for {
-		lor, err := b.Bucket.ListObjects(oss.MaxKeys(50), marker, ossPrefix)
+		if err := ctx.Err(); err != nil {
+			return objects, err
+		}
+		lor, err := b.Bucket.ListObjects(append(ctxOptions, oss.MaxKeys(defaultPageSize), marker, ossPrefix)...)
 		if err != nil {
 			return objects, err
 		}
@@ -93,6 +161,9 @@ func (b AlibabaCloudOSSBackend) ListObjects(prefix string) ([]Object, error) {
 				Path:         path,
 				Content:      []byte{},
 				LastModified: obj.LastModified,
+				Size:         obj.Size,
+				ETag:         cleanETag(obj.ETag),
+				StorageClass: obj.StorageClass,
 			}
 			objects = append(objects, object)
 		}
@@ -106,13 +177,101 @@ func (b AlibabaCloudOSSBackend) ListObjects(prefix string) ([]Object, error) {
 	return objects, nil
 }
 
+// ListObjectsPage lists a page of the objects in Alibaba Cloud OSS bucket, at prefix.
+// The token is the marker returned by OSS for the next page.
+func (b AlibabaCloudOSSBackend) ListObjectsPage(prefix string, token string, limit int) (_ ObjectPage, err error) {
+	defer b.wrapError(&err, "ListObjectsPage", prefix)
+	var page ObjectPage
+
+	prefix = listPrefix(b.Prefix, prefix)
+	lor, err := b.Bucket.ListObjects(oss.MaxKeys(pageSize(limit)), oss.Prefix(prefix), oss.Delimiter("/"), oss.Marker(token))
+	if err != nil {
+		return page, err
+	}
+	for _, obj := range lor.Objects {
+		path := strings.TrimPrefix(obj.Key, prefix)
+		if objectPathIsInvalid(path) {
+			continue
+		}
+		object := Object{
+			Path:         path,
+			Content:      []byte{},
+			LastModified: obj.LastModified,
+			Size:         obj.Size,
+			ETag:         cleanETag(obj.ETag),
+			StorageClass: obj.StorageClass,
+		}
+		page.Objects = append(page.Objects, object)
+	}
+	if lor.IsTruncated {
+		page.NextToken = lor.NextMarker
+	}
+	return page, nil
+}
+
+// ListObjectsIter iterates over the objects in Alibaba Cloud OSS bucket, at prefix
+func (b AlibabaCloudOSSBackend) ListObjectsIter(prefix string) iter.Seq2[Object, error] {
+	return listObjectsIter(b, prefix)
+}
+
+// ListObjectsWithOptions lists the objects and common prefixes below prefix in Alibaba Cloud OSS bucket
+func (b AlibabaCloudOSSBackend) ListObjectsWithOptions(prefix string, options ListObjectsOptions) (_ ListObjectsResult, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
+	var result ListObjectsResult
+
+	prefix = listPrefix(b.Prefix, prefix)
+	ossOptions := []oss.Option{oss.MaxKeys(1000), oss.Prefix(prefix), oss.Delimiter(options.nativeDelimiter())}
+	marker := ""
+	for {
+		lor, err := b.Bucket.ListObjects(append(ossOptions, oss.Marker(marker))...)
+		if err != nil {
+			return result, err
+		}
+		for _, obj := range lor.Objects {
+			path := strings.TrimPrefix(obj.Key, prefix)
+			if objectPathIsInvalid(path) {
+				continue
+			}
+			object := Object{
+				Path:         path,
+				Content:      []byte{},
+				LastModified: obj.LastModified,
+				Size:         obj.Size,
+				ETag:         cleanETag(obj.ETag),
+				StorageClass: obj.StorageClass,
+			}
+			result.Objects = append(result.Objects, object)
+		}
+		for _, commonPrefix := range lor.CommonPrefixes {
+			result.CommonPrefixes = append(result.CommonPrefixes, strings.TrimPrefix(commonPrefix, prefix))
+		}
+		if !lor.IsTruncated {
+			break
+		}
+		marker = lor.NextMarker
+	}
+
+	return result, nil
+}
+
 // GetObject retrieves an object from Alibaba Cloud OSS bucket, at prefix
 func (b AlibabaCloudOSSBackend) GetObject(path string) (Object, error) {
+	return b.GetObjectContext(context.Background(), path)
+}
+
+// GetObjectContext retrieves an object from Alibaba Cloud OSS bucket, at prefix, aborting when ctx is done
+func (b AlibabaCloudOSSBackend) GetObjectContext(ctx context.Context, path string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObject", path)
 	var object Object
 	object.Path = path
+	if err := ctx.Err(); err != nil {
+		return object, err
+	}
 	var content []byte
 	key := pathutil.Join(b.Prefix, path)
-	body, err := b.Bucket.GetObject(key)
+	ctxOptions, release := alibabaContextOptions(ctx)
+	defer release()
+	body, err := b.Bucket.GetObject(key, ctxOptions...)
 
 	if err != nil {
 		return object, err
@@ -124,31 +283,286 @@ func (b AlibabaCloudOSSBackend) GetObject(path string) (Object, error) {
 	}
 	object.Content = content
 
-	headers, err := b.Bucket.GetObjectMeta(key)
+	headers, err := b.Bucket.GetObjectDetailedMeta(key, ctxOptions...)
 	if err != nil {
 		return object, err
 	}
-	lastModified, _ := http.ParseTime(headers.Get(oss.HTTPHeaderLastModified))
-	object.LastModified = lastModified
-	return object, nil
+	return objectWithInfo(objectInfoFromHeader(path, headers, "oss"), content), nil
 }
 
 // PutObject uploads an object to Alibaba Cloud OSS bucket, at prefix
 func (b AlibabaCloudOSSBackend) PutObject(path string, content []byte) error {
+	return b.PutObjectContext(context.Background(), path, content)
+}
+
+// PutObjectContext uploads an object to Alibaba Cloud OSS bucket, at prefix, aborting when ctx is done
+func (b AlibabaCloudOSSBackend) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	defer b.wrapError(&err, "PutObject", path)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	key := pathutil.Join(b.Prefix, path)
-	var err error
+	ctxOptions, release := alibabaContextOptions(ctx)
+	defer release()
 	if b.SSE == "" {
-		err = b.Bucket.PutObject(key, bytes.NewReader(content))
+		err = b.Bucket.PutObject(key, bytes.NewReader(content), ctxOptions...)
 	} else {
 		sse := oss.ServerSideEncryption(b.SSE)
-		err = b.Bucket.PutObject(key, bytes.NewReader(content), sse)
+		err = b.Bucket.PutObject(key, bytes.NewReader(content), append(ctxOptions, sse)...)
 	}
 	return err
 }
 
+// PutObjectWithOptions uploads an object to Alibaba Cloud OSS bucket, at prefix, along with its content type,
+// storage class and user metadata
+func (b AlibabaCloudOSSBackend) PutObjectWithOptions(path string, content []byte, options PutObjectOptions) (err error) {
+	defer b.wrapError(&err, "PutObjectWithOptions", path)
+	key := pathutil.Join(b.Prefix, path)
+	ossOptions := b.putOptions()
+	if options.ContentType != "" {
+		ossOptions = append(ossOptions, oss.ContentType(options.ContentType))
+	}
+	if options.StorageClass != "" {
+		ossOptions = append(ossOptions, oss.ObjectStorageClass(oss.StorageClassType(options.StorageClass)))
+	}
+	for key, value := range options.UserMetadata {
+		ossOptions = append(ossOptions, oss.Meta(key, value))
+	}
+	return b.Bucket.PutObject(key, bytes.NewReader(content), ossOptions...)
+}
+
+// putOptions returns the options common to every upload, i.e. server-side encryption if configured
+func (b AlibabaCloudOSSBackend) putOptions() []oss.Option {
+	var options []oss.Option
+	if b.SSE != "" {
+		options = append(options, oss.ServerSideEncryption(b.SSE))
+	}
+	return options
+}
+
+// GetObjectReader opens an object in Alibaba Cloud OSS bucket, at prefix, for reading
+func (b AlibabaCloudOSSBackend) GetObjectReader(path string) (_ io.ReadCloser, _ ObjectInfo, err error) {
+	defer b.wrapError(&err, "GetObjectReader", path)
+	key := pathutil.Join(b.Prefix, path)
+	result, err := b.Bucket.DoGetObject(&oss.GetObjectRequest{ObjectKey: key}, nil)
+	if err != nil {
+		return nil, ObjectInfo{Path: path}, err
+	}
+	return result.Response.Body, objectInfoFromHeader(path, result.Response.Headers, "oss"), nil
+}
+
+// GetObjectRange reads part of an object from Alibaba Cloud OSS bucket, at prefix, with an HTTP range request.
+// The standard range behavior makes OSS reject ranges past the end of the object, instead of returning it whole.
+func (b AlibabaCloudOSSBackend) GetObjectRange(path string, offset int64, length int64) (_ []byte, err error) {
+	defer b.wrapError(&err, "GetObjectRange", path)
+	return getObjectRange(offset, length, alibabaCloudOSSErrorKind, func(offset int64, length int64) ([]byte, error) {
+		body, err := b.Bucket.GetObject(pathutil.Join(b.Prefix, path), oss.NormalizedRange(byteRange(offset, length)), oss.RangeBehavior("standard"))
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	})
+}
+
+// PutObjectStream uploads an object to Alibaba Cloud OSS bucket, at prefix, from a stream
+func (b AlibabaCloudOSSBackend) PutObjectStream(path string, content io.Reader, size int64) (err error) {
+	defer b.wrapError(&err, "PutObjectStream", path)
+	key := pathutil.Join(b.Prefix, path)
+	options := b.putOptions()
+	if size >= 0 {
+		options = append(options, oss.ContentLength(size))
+	}
+	return b.Bucket.PutObject(key, content, options...)
+}
+
+// StatObject retrieves the meta information of an object in Alibaba Cloud OSS bucket, at prefix
+func (b AlibabaCloudOSSBackend) StatObject(path string) (_ ObjectInfo, err error) {
+	defer b.wrapError(&err, "StatObject", path)
+	headers, err := b.Bucket.GetObjectDetailedMeta(pathutil.Join(b.Prefix, path))
+	if err != nil {
+		return ObjectInfo{Path: path}, err
+	}
+	return objectInfoFromHeader(path, headers, "oss"), nil
+}
+
 // DeleteObject removes an object from Alibaba Cloud OSS bucket, at prefix
 func (b AlibabaCloudOSSBackend) DeleteObject(path string) error {
+	return b.DeleteObjectContext(context.Background(), path)
+}
+
+// DeleteObjectContext removes an object from Alibaba Cloud OSS bucket, at prefix, aborting when ctx is done
+func (b AlibabaCloudOSSBackend) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	defer b.wrapError(&err, "DeleteObject", path)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	key := pathutil.Join(b.Prefix, path)
-	err := b.Bucket.DeleteObject(key)
+	ctxOptions, release := alibabaContextOptions(ctx)
+	defer release()
+	err = b.Bucket.DeleteObject(key, ctxOptions...)
 	return err
 }
+
+// alibabaContextOptions registers ctx for the requests of the OSS client made with the returned options, until release
+// is called. No options are needed for contexts which are never done.
+func alibabaContextOptions(ctx context.Context) (_ []oss.Option, release func()) {
+	if ctx.Done() == nil {
+		return nil, func() {}
+	}
+	id := strconv.FormatUint(alibabaContextIDs.Add(1), 10)
+	alibabaContexts.Store(id, ctx)
+	return []oss.Option{oss.SetHeader(alibabaContextHeader, id)}, func() { alibabaContexts.Delete(id) }
+}
+
+// RoundTrip sends a request with the context registered for it by alibabaContextOptions, if any
+func (t alibabaContextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := req.Header.Get(alibabaContextHeader)
+	if id == "" {
+		return t.base.RoundTrip(req)
+	}
+	ctx := req.Context()
+	if registered, ok := alibabaContexts.Load(id); ok {
+		ctx = registered.(context.Context)
+	}
+	req = req.Clone(ctx)
+	req.Header.Del(alibabaContextHeader)
+	return t.base.RoundTrip(req)
+}
+
+// DeleteObjects removes objects from Alibaba Cloud OSS bucket, at prefix, with up to 1000 keys per request
+func (b AlibabaCloudOSSBackend) DeleteObjects(paths []string) ([]DeleteResult, error) {
+	return deleteObjectsInBatches(paths, maxDeleteBatchSize, b.deleteBatch)
+}
+
+// deleteBatch removes the objects of a batch from Alibaba Cloud OSS bucket, at prefix, with a single request.
+// OSS only reports the keys it deleted, so the other keys are reported as not deleted.
+func (b AlibabaCloudOSSBackend) deleteBatch(batch []DeleteResult) (err error) {
+	defer b.wrapError(&err, "DeleteObjects", "")
+	keys := make([]string, len(batch))
+	for i := range batch {
+		keys[i] = pathutil.Join(b.Prefix, batch[i].Path)
+	}
+	result, err := b.Bucket.DeleteObjects(keys)
+	if err != nil {
+		return err
+	}
+	deleted := make(map[string]bool, len(result.DeletedObjects))
+	for _, key := range result.DeletedObjects {
+		deleted[key] = true
+	}
+	for i, key := range keys {
+		if !deleted[key] {
+			batch[i].Err = errors.New("object was not deleted")
+			b.wrapError(&batch[i].Err, "DeleteObjects", batch[i].Path)
+		}
+	}
+	return nil
+}
+
+// CopyObject copies an object within Alibaba Cloud OSS bucket, at prefix, without downloading it
+func (b AlibabaCloudOSSBackend) CopyObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "CopyObject", src)
+	_, err = b.Bucket.CopyObject(pathutil.Join(b.Prefix, src), pathutil.Join(b.Prefix, dst), b.putOptions()...)
+	return err
+}
+
+// MoveObject copies an object within Alibaba Cloud OSS bucket, at prefix, and deletes the original
+func (b AlibabaCloudOSSBackend) MoveObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "MoveObject", src)
+	return moveObject(src, dst, b.CopyObject, b.DeleteObject)
+}
+
+// PresignGet generates a signed URL to download an object from Alibaba Cloud OSS bucket, at prefix, until ttl expires
+func (b AlibabaCloudOSSBackend) PresignGet(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignGet", path)
+	if err := validatePresignTTL(ttl); err != nil {
+		return "", err
+	}
+	return b.Bucket.SignURL(pathutil.Join(b.Prefix, path), oss.HTTPGet, int64(ttl.Seconds()))
+}
+
+// PresignPut generates a signed URL to upload an object to Alibaba Cloud OSS bucket, at prefix, until ttl expires.
+// Objects uploaded with the URL are encrypted with the default encryption of the bucket.
+func (b AlibabaCloudOSSBackend) PresignPut(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignPut", path)
+	if err := validatePresignTTL(ttl); err != nil {
+		return "", err
+	}
+	return b.Bucket.SignURL(pathutil.Join(b.Prefix, path), oss.HTTPPut, int64(ttl.Seconds()))
+}
+
+// ListObjectVersions lists all versions of the objects in Alibaba Cloud OSS bucket, at prefix.
+// Versions are only kept by buckets with versioning enabled; delete markers are not listed.
+func (b AlibabaCloudOSSBackend) ListObjectVersions(prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjectVersions", prefix)
+	var objects []Object
+	prefix = pathutil.Join(b.Prefix, prefix)
+	keyMarker, versionIDMarker := "", ""
+	for {
+		result, err := b.Bucket.ListObjectVersions(oss.Prefix(prefix), oss.MaxKeys(defaultPageSize),
+			oss.KeyMarker(keyMarker), oss.VersionIdMarker(versionIDMarker))
+		if err != nil {
+			return objects, err
+		}
+		for _, version := range result.ObjectVersions {
+			path := removePrefixFromObjectPath(prefix, version.Key)
+			if objectPathIsInvalid(path) {
+				continue
+			}
+			objects = append(objects, Object{
+				Meta:         Metadata{Name: path, Version: version.VersionId},
+				Path:         path,
+				Content:      []byte{},
+				LastModified: version.LastModified,
+				Size:         version.Size,
+				ETag:         cleanETag(version.ETag),
+				StorageClass: version.StorageClass,
+			})
+		}
+		if !result.IsTruncated {
+			break
+		}
+		keyMarker, versionIDMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+	return objects, nil
+}
+
+// GetObjectVersion retrieves a version of an object from Alibaba Cloud OSS bucket, at prefix
+func (b AlibabaCloudOSSBackend) GetObjectVersion(path string, versionID string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObjectVersion", path)
+	object := Object{Path: path}
+	request := &oss.GetObjectRequest{ObjectKey: pathutil.Join(b.Prefix, path)}
+	result, err := b.Bucket.DoGetObject(request, []oss.Option{oss.VersionId(versionID)})
+	if err != nil {
+		return object, err
+	}
+	content, err := ioutil.ReadAll(result.Response.Body)
+	result.Response.Body.Close()
+	if err != nil {
+		return object, err
+	}
+	object = objectWithInfo(objectInfoFromHeader(path, result.Response.Headers, "oss"), content)
+	object.Meta = Metadata{Name: path, Version: versionID}
+	return object, nil
+}
+
+// DeleteObjectVersion permanently removes a version of an object from Alibaba Cloud OSS bucket, at prefix
+func (b AlibabaCloudOSSBackend) DeleteObjectVersion(path string, versionID string) (err error) {
+	defer b.wrapError(&err, "DeleteObjectVersion", path)
+	return b.Bucket.DeleteObject(pathutil.Join(b.Prefix, path), oss.VersionId(versionID))
+}
+
+func (b AlibabaCloudOSSBackend) wrapError(err *error, op string, path string) {
+	if wrapStorageError(err, "AlibabaCloudOSS", op, path, alibabaCloudOSSErrorKind) {
+		logStorageError(b.logger, *err)
+	}
+}
+
+func alibabaCloudOSSErrorKind(err error) error {
+	var serviceErr oss.ServiceError
+	if errors.As(err, &serviceErr) {
+		return kindFromStatusCode(serviceErr.StatusCode)
+	}
+	return nil
+}
@@ -18,14 +18,25 @@ package storage
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
-	pathutil "path"
-	"strings"
+	"iter"
+	"log/slog"
 	"net/http"
-	"crypto/tls"
+	"net/url"
 	"os"
+	pathutil "path"
+	"strings"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -39,17 +50,12 @@ type AmazonS3Backend struct {
 	Prefix     string
 	Uploader   *s3manager.Uploader
 	SSE        string
+	logger     *slog.Logger
 }
 
 // NewAmazonS3Backend creates a new instance of AmazonS3Backend
 func NewAmazonS3Backend(bucket string, prefix string, region string, endpoint string, sse string) *AmazonS3Backend {
-	client := http.DefaultClient
-	if os.Getenv("AWS_INSECURE_SKIP_VERIFY") == "true" {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client = &http.Client{Transport: tr}
-	}
+	client := amazonS3HTTPClient()
 	service := s3.New(session.New(), &aws.Config{
 		HTTPClient:       client,
 		Region:           aws.String(region),
@@ -68,18 +74,24 @@ func NewAmazonS3Backend(bucket string, prefix string, region string, endpoint st
 	return b
 }
 
+// amazonS3HTTPClient returns the HTTP client of the S3 service, which propagates the trace context of the
+// requests, and skips the verification of TLS certificates if AWS_INSECURE_SKIP_VERIFY is true
+func amazonS3HTTPClient() *http.Client {
+	transport := http.DefaultTransport
+	if os.Getenv("AWS_INSECURE_SKIP_VERIFY") == "true" {
+		transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	return &http.Client{Transport: tracingTransport(transport)}
+}
+
 type AmazonS3Options struct {
 	S3ForcePathStyle *bool
 }
 
 func NewAmazonS3BackendWithOptions(bucket string, prefix string, region string, endpoint string, sse string, options *AmazonS3Options) *AmazonS3Backend {
-	client := http.DefaultClient
-	if os.Getenv("AWS_INSECURE_SKIP_VERIFY") == "true" {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client = &http.Client{Transport: tr}
-	}
+	client := amazonS3HTTPClient()
 	s3ForcePathStyle := endpoint != ""
 	if options != nil && options.S3ForcePathStyle != nil {
 		s3ForcePathStyle = *options.S3ForcePathStyle
@@ -104,13 +116,7 @@ func NewAmazonS3BackendWithOptions(bucket string, prefix string, region string,
 
 // NewAmazonS3BackendWithCredentials creates a new instance of AmazonS3Backend with credentials
 func NewAmazonS3BackendWithCredentials(bucket string, prefix string, region string, endpoint string, sse string, credentials *credentials.Credentials) *AmazonS3Backend {
-	client := http.DefaultClient
-	if os.Getenv("AWS_INSECURE_SKIP_VERIFY") == "true" {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client = &http.Client{Transport: tr}
-	}
+	client := amazonS3HTTPClient()
 	service := s3.New(session.New(), &aws.Config{
 		HTTPClient:       client,
 		Credentials:      credentials,
@@ -130,8 +136,28 @@ func NewAmazonS3BackendWithCredentials(bucket string, prefix string, region stri
 	return b
 }
 
+// SetLogger sets the logger of the failed operations on Amazon S3. Nothing is logged without a logger.
+func (b *AmazonS3Backend) SetLogger(logger *slog.Logger) {
+	b.logger = logger
+}
+
+// Capabilities returns the optional features supported by Amazon S3
+func (b AmazonS3Backend) Capabilities() CapabilitySet {
+	return NewCapabilitySet(
+		CapabilityContext, CapabilityStream, CapabilityRange, CapabilityMetadata,
+		CapabilityStat, CapabilityHierarchical, CapabilityPaging, CapabilityBatchDelete,
+		CapabilityCopy, CapabilityPresign, CapabilityConditional, CapabilityVersioning,
+	)
+}
+
 // ListObjects lists all objects in Amazon S3 bucket, at prefix
 func (b AmazonS3Backend) ListObjects(prefix string) ([]Object, error) {
+	return b.ListObjectsContext(context.Background(), prefix)
+}
+
+// ListObjectsContext lists all objects in Amazon S3 bucket, at prefix, using ctx for the requests
+func (b AmazonS3Backend) ListObjectsContext(ctx context.Context, prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
 	var objects []Object
 	prefix = pathutil.Join(b.Prefix, prefix)
 	s3Input := &s3.ListObjectsInput{
@@ -139,7 +165,7 @@ func (b AmazonS3Backend) ListObjects(prefix string) ([]Object, error) {
 		Prefix: aws.String(prefix),
 	}
 	for {
-		s3Result, err := b.Client.ListObjects(s3Input)
+		s3Result, err := b.Client.ListObjectsWithContext(ctx, s3Input)
 		if err != nil {
 			return objects, err
 		}
@@ -152,6 +178,9 @@ func (b AmazonS3Backend) ListObjects(prefix string) ([]Object, error) {
 				Path:         path,
 				Content:      []byte{},
 				LastModified: *obj.LastModified,
+				Size:         aws.Int64Value(obj.Size),
+				ETag:         cleanETag(aws.StringValue(obj.ETag)),
+				StorageClass: aws.StringValue(obj.StorageClass),
 			}
 			objects = append(objects, object)
 		}
@@ -163,50 +192,451 @@ func (b AmazonS3Backend) ListObjects(prefix string) ([]Object, error) {
 	return objects, nil
 }
 
+// ListObjectsPage lists a page of the objects in Amazon S3 bucket, at prefix.
+// The token is the marker returned by S3 for the next page.
+func (b AmazonS3Backend) ListObjectsPage(prefix string, token string, limit int) (_ ObjectPage, err error) {
+	defer b.wrapError(&err, "ListObjectsPage", prefix)
+	var page ObjectPage
+	prefix = listPrefix(b.Prefix, prefix)
+	s3Input := &s3.ListObjectsInput{
+		Bucket:    aws.String(b.Bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+		MaxKeys:   aws.Int64(int64(pageSize(limit))),
+	}
+	if token != "" {
+		s3Input.Marker = aws.String(token)
+	}
+	s3Result, err := b.Client.ListObjects(s3Input)
+	if err != nil {
+		return page, err
+	}
+	for _, obj := range s3Result.Contents {
+		path := strings.TrimPrefix(*obj.Key, prefix)
+		if objectPathIsInvalid(path) {
+			continue
+		}
+		object := Object{
+			Path:         path,
+			Content:      []byte{},
+			LastModified: *obj.LastModified,
+			Size:         aws.Int64Value(obj.Size),
+			ETag:         cleanETag(aws.StringValue(obj.ETag)),
+			StorageClass: aws.StringValue(obj.StorageClass),
+		}
+		page.Objects = append(page.Objects, object)
+	}
+	if aws.BoolValue(s3Result.IsTruncated) {
+		page.NextToken = aws.StringValue(s3Result.NextMarker)
+	}
+	return page, nil
+}
+
+// ListObjectsIter iterates over the objects in Amazon S3 bucket, at prefix
+func (b AmazonS3Backend) ListObjectsIter(prefix string) iter.Seq2[Object, error] {
+	return listObjectsIter(b, prefix)
+}
+
+// ListObjectsWithOptions lists the objects and common prefixes below prefix in Amazon S3 bucket
+func (b AmazonS3Backend) ListObjectsWithOptions(prefix string, options ListObjectsOptions) (_ ListObjectsResult, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
+	var result ListObjectsResult
+	prefix = listPrefix(b.Prefix, prefix)
+	s3Input := &s3.ListObjectsInput{
+		Bucket: aws.String(b.Bucket),
+		Prefix: aws.String(prefix),
+	}
+	if delimiter := options.nativeDelimiter(); delimiter != "" {
+		s3Input.Delimiter = aws.String(delimiter)
+	}
+	err = b.Client.ListObjectsPages(s3Input, func(s3Result *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, obj := range s3Result.Contents {
+			path := strings.TrimPrefix(*obj.Key, prefix)
+			if objectPathIsInvalid(path) {
+				continue
+			}
+			object := Object{
+				Path:         path,
+				Content:      []byte{},
+				LastModified: *obj.LastModified,
+				Size:         aws.Int64Value(obj.Size),
+				ETag:         cleanETag(aws.StringValue(obj.ETag)),
+				StorageClass: aws.StringValue(obj.StorageClass),
+			}
+			result.Objects = append(result.Objects, object)
+		}
+		for _, commonPrefix := range s3Result.CommonPrefixes {
+			result.CommonPrefixes = append(result.CommonPrefixes, strings.TrimPrefix(*commonPrefix.Prefix, prefix))
+		}
+		return true
+	})
+	return result, err
+}
+
 // GetObject retrieves an object from Amazon S3 bucket, at prefix
 func (b AmazonS3Backend) GetObject(path string) (Object, error) {
+	return b.GetObjectContext(context.Background(), path)
+}
+
+// GetObjectContext retrieves an object from Amazon S3 bucket, at prefix, using ctx for the request
+func (b AmazonS3Backend) GetObjectContext(ctx context.Context, path string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObject", path)
+	return b.getObject(ctx, path, nil)
+}
+
+// GetObjectVersion retrieves a version of an object from Amazon S3 bucket, at prefix
+func (b AmazonS3Backend) GetObjectVersion(path string, versionID string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObjectVersion", path)
+	return b.getObject(context.Background(), path, aws.String(versionID))
+}
+
+// getObject retrieves the version versionID of an object, or its current version if versionID is nil
+func (b AmazonS3Backend) getObject(ctx context.Context, path string, versionID *string) (Object, error) {
 	var object Object
 	object.Path = path
 	var content []byte
 	s3Input := &s3.GetObjectInput{
-		Bucket: aws.String(b.Bucket),
-		Key:    aws.String(pathutil.Join(b.Prefix, path)),
+		Bucket:    aws.String(b.Bucket),
+		Key:       aws.String(pathutil.Join(b.Prefix, path)),
+		VersionId: versionID,
 	}
-	s3Result, err := b.Client.GetObject(s3Input)
+	s3Result, err := b.Client.GetObjectWithContext(ctx, s3Input)
 	if err != nil {
 		return object, err
 	}
+	defer s3Result.Body.Close()
 	content, err = ioutil.ReadAll(s3Result.Body)
 	if err != nil {
 		return object, err
 	}
 	object.Content = content
 	object.LastModified = *s3Result.LastModified
+	object.Size = aws.Int64Value(s3Result.ContentLength)
+	object.ETag = cleanETag(aws.StringValue(s3Result.ETag))
+	object.ContentType = aws.StringValue(s3Result.ContentType)
+	object.StorageClass = aws.StringValue(s3Result.StorageClass)
+	object.UserMetadata = amazonS3UserMetadata(s3Result.Metadata)
+	object.Meta = Metadata{Name: path, Version: aws.StringValue(s3Result.VersionId)}
 	return object, nil
 }
 
 // PutObject uploads an object to Amazon S3 bucket, at prefix
 func (b AmazonS3Backend) PutObject(path string, content []byte) error {
+	return b.PutObjectContext(context.Background(), path, content)
+}
+
+// PutObjectContext uploads an object to Amazon S3 bucket, at prefix, using ctx for the upload
+func (b AmazonS3Backend) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	defer b.wrapError(&err, "PutObject", path)
+	s3Input := b.uploadInput(path, bytes.NewBuffer(content), PutObjectOptions{})
+	_, err = b.Uploader.UploadWithContext(ctx, s3Input)
+	return err
+}
+
+// PutObjectWithOptions uploads an object to Amazon S3 bucket, at prefix, along with its content type,
+// storage class and user metadata
+func (b AmazonS3Backend) PutObjectWithOptions(path string, content []byte, options PutObjectOptions) (err error) {
+	defer b.wrapError(&err, "PutObjectWithOptions", path)
+	s3Input := b.uploadInput(path, bytes.NewBuffer(content), options)
+	_, err = b.Uploader.Upload(s3Input)
+	return err
+}
+
+// PutObjectIf uploads an object to Amazon S3 bucket, at prefix, if condition is met.
+// The object is sent in a single PUT request carrying an If-None-Match or If-Match header.
+func (b AmazonS3Backend) PutObjectIf(path string, content []byte, condition Condition) (err error) {
+	defer b.wrapError(&err, "PutObjectIf", path)
+	err = condition.validate()
+	if err != nil {
+		return err
+	}
+	s3Input := &s3.PutObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(pathutil.Join(b.Prefix, path)),
+		Body:   bytes.NewReader(content),
+	}
+	if b.SSE != "" {
+		s3Input.ServerSideEncryption = aws.String(b.SSE)
+	}
+	headers := make(map[string]string)
+	if condition.IfNotExists {
+		headers["If-None-Match"] = "*"
+	}
+	if condition.IfMatch != "" {
+		headers["If-Match"] = fmt.Sprintf("%q", condition.IfMatch)
+	}
+	_, err = b.Client.PutObjectWithContext(context.Background(), s3Input, request.WithSetRequestHeaders(headers))
+	return err
+}
+
+// uploadInput prepares the upload of body to path, with server-side encryption if configured
+func (b AmazonS3Backend) uploadInput(path string, body io.Reader, options PutObjectOptions) *s3manager.UploadInput {
 	s3Input := &s3manager.UploadInput{
 		Bucket: aws.String(b.Bucket),
 		Key:    aws.String(pathutil.Join(b.Prefix, path)),
-		Body:   bytes.NewBuffer(content),
+		Body:   body,
 	}
 
 	if b.SSE != "" {
 		s3Input.ServerSideEncryption = aws.String(b.SSE)
 	}
+	if options.ContentType != "" {
+		s3Input.ContentType = aws.String(options.ContentType)
+	}
+	if options.StorageClass != "" {
+		s3Input.StorageClass = aws.String(options.StorageClass)
+	}
+	if len(options.UserMetadata) > 0 {
+		s3Input.Metadata = aws.StringMap(options.UserMetadata)
+	}
+	return s3Input
+}
+
+// GetObjectReader opens an object in Amazon S3 bucket, at prefix, for reading
+func (b AmazonS3Backend) GetObjectReader(path string) (_ io.ReadCloser, _ ObjectInfo, err error) {
+	defer b.wrapError(&err, "GetObjectReader", path)
+	info := ObjectInfo{Path: path}
+	s3Input := &s3.GetObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(pathutil.Join(b.Prefix, path)),
+	}
+	s3Result, err := b.Client.GetObject(s3Input)
+	if err != nil {
+		return nil, info, err
+	}
+	info.Size = aws.Int64Value(s3Result.ContentLength)
+	info.LastModified = aws.TimeValue(s3Result.LastModified)
+	info.ETag = cleanETag(aws.StringValue(s3Result.ETag))
+	info.ContentType = aws.StringValue(s3Result.ContentType)
+	info.StorageClass = aws.StringValue(s3Result.StorageClass)
+	info.UserMetadata = amazonS3UserMetadata(s3Result.Metadata)
+	return s3Result.Body, info, nil
+}
+
+// GetObjectRange reads part of an object from Amazon S3 bucket, at prefix, with an HTTP range request
+func (b AmazonS3Backend) GetObjectRange(path string, offset int64, length int64) (_ []byte, err error) {
+	defer b.wrapError(&err, "GetObjectRange", path)
+	return getObjectRange(offset, length, amazonS3ErrorKind, func(offset int64, length int64) ([]byte, error) {
+		s3Result, err := b.Client.GetObjectWithContext(context.Background(), &s3.GetObjectInput{
+			Bucket: aws.String(b.Bucket),
+			Key:    aws.String(pathutil.Join(b.Prefix, path)),
+			Range:  aws.String(httpRange(offset, length)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer s3Result.Body.Close()
+		return io.ReadAll(s3Result.Body)
+	})
+}
 
-	_, err := b.Uploader.Upload(s3Input)
+// PutObjectStream uploads an object to Amazon S3 bucket, at prefix, from a stream.
+// Large content is sent as a multipart upload, so it never has to be held in memory.
+func (b AmazonS3Backend) PutObjectStream(path string, content io.Reader, size int64) (err error) {
+	defer b.wrapError(&err, "PutObjectStream", path)
+	s3Input := b.uploadInput(path, content, PutObjectOptions{})
+	_, err = b.Uploader.Upload(s3Input)
 	return err
 }
 
+// StatObject retrieves the information of an object in Amazon S3 bucket, at prefix, with a HEAD request
+func (b AmazonS3Backend) StatObject(path string) (_ ObjectInfo, err error) {
+	defer b.wrapError(&err, "StatObject", path)
+	info := ObjectInfo{Path: path}
+	s3Input := &s3.HeadObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(pathutil.Join(b.Prefix, path)),
+	}
+	s3Result, err := b.Client.HeadObject(s3Input)
+	if err != nil {
+		return info, err
+	}
+	info.Size = aws.Int64Value(s3Result.ContentLength)
+	info.LastModified = aws.TimeValue(s3Result.LastModified)
+	info.ETag = cleanETag(aws.StringValue(s3Result.ETag))
+	info.ContentType = aws.StringValue(s3Result.ContentType)
+	info.StorageClass = aws.StringValue(s3Result.StorageClass)
+	info.UserMetadata = amazonS3UserMetadata(s3Result.Metadata)
+	return info, nil
+}
+
 // DeleteObject removes an object from Amazon S3 bucket, at prefix
 func (b AmazonS3Backend) DeleteObject(path string) error {
+	return b.DeleteObjectContext(context.Background(), path)
+}
+
+// DeleteObjectContext removes an object from Amazon S3 bucket, at prefix, using ctx for the request
+func (b AmazonS3Backend) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	defer b.wrapError(&err, "DeleteObject", path)
 	s3Input := &s3.DeleteObjectInput{
 		Bucket: aws.String(b.Bucket),
 		Key:    aws.String(pathutil.Join(b.Prefix, path)),
 	}
-	_, err := b.Client.DeleteObject(s3Input)
+	_, err = b.Client.DeleteObjectWithContext(ctx, s3Input)
+	return err
+}
+
+// DeleteObjects removes objects from Amazon S3 bucket, at prefix, with up to 1000 keys per request
+func (b AmazonS3Backend) DeleteObjects(paths []string) ([]DeleteResult, error) {
+	return deleteObjectsInBatches(paths, maxDeleteBatchSize, b.deleteBatch)
+}
+
+// deleteBatch removes the objects of a batch from Amazon S3 bucket, at prefix, with a single request
+func (b AmazonS3Backend) deleteBatch(batch []DeleteResult) (err error) {
+	defer b.wrapError(&err, "DeleteObjects", "")
+	results := make(map[string]*DeleteResult, len(batch))
+	s3Delete := &s3.Delete{Quiet: aws.Bool(true)}
+	for i := range batch {
+		key := pathutil.Join(b.Prefix, batch[i].Path)
+		results[key] = &batch[i]
+		s3Delete.Objects = append(s3Delete.Objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+	}
+	s3Result, err := b.Client.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(b.Bucket),
+		Delete: s3Delete,
+	})
+	if err != nil {
+		return err
+	}
+	for _, s3Error := range s3Result.Errors {
+		if result, ok := results[aws.StringValue(s3Error.Key)]; ok {
+			result.Err = awserr.New(aws.StringValue(s3Error.Code), aws.StringValue(s3Error.Message), nil)
+			b.wrapError(&result.Err, "DeleteObjects", result.Path)
+		}
+	}
+	return nil
+}
+
+// amazonS3UserMetadata converts the user metadata returned by S3, whose keys are canonicalized like HTTP headers
+func amazonS3UserMetadata(metadata map[string]*string) map[string]string {
+	return lowerCaseKeys(aws.StringValueMap(metadata))
+}
+
+// CopyObject copies an object within Amazon S3 bucket, at prefix, without downloading it
+func (b AmazonS3Backend) CopyObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "CopyObject", src)
+	s3Input := &s3.CopyObjectInput{
+		Bucket:     aws.String(b.Bucket),
+		Key:        aws.String(pathutil.Join(b.Prefix, dst)),
+		CopySource: aws.String(url.PathEscape(pathutil.Join(b.Bucket, b.Prefix, src))),
+	}
+	if b.SSE != "" {
+		s3Input.ServerSideEncryption = aws.String(b.SSE)
+	}
+	_, err = b.Client.CopyObjectWithContext(context.Background(), s3Input)
 	return err
 }
+
+// MoveObject copies an object within Amazon S3 bucket, at prefix, and deletes the original
+func (b AmazonS3Backend) MoveObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "MoveObject", src)
+	return moveObject(src, dst, b.CopyObject, b.DeleteObject)
+}
+
+// PresignGet generates a URL to download an object from Amazon S3 bucket, at prefix, until ttl expires
+func (b AmazonS3Backend) PresignGet(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignGet", path)
+	req, _ := b.Client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(pathutil.Join(b.Prefix, path)),
+	})
+	return b.presign(req, ttl)
+}
+
+// PresignPut generates a URL to upload an object to Amazon S3 bucket, at prefix, until ttl expires.
+// If the backend encrypts objects with SSE, the x-amz-server-side-encryption header is signed with the URL,
+// so the upload must send that header with the value of SSE, and cannot store the object unencrypted.
+// Otherwise, objects uploaded with the URL are encrypted with the default encryption of the bucket.
+func (b AmazonS3Backend) PresignPut(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignPut", path)
+	s3Input := &s3.PutObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(pathutil.Join(b.Prefix, path)),
+	}
+	if b.SSE != "" {
+		s3Input.ServerSideEncryption = aws.String(b.SSE)
+	}
+	req, _ := b.Client.PutObjectRequest(s3Input)
+	return b.presign(req, ttl)
+}
+
+// presign signs req with the credentials of the client, which must not be anonymous
+func (b AmazonS3Backend) presign(req *request.Request, ttl time.Duration) (string, error) {
+	if err := validatePresignTTL(ttl); err != nil {
+		return "", err
+	}
+	if b.Client.Config.Credentials == credentials.AnonymousCredentials {
+		return "", ErrNotSupported
+	}
+	return req.Presign(ttl)
+}
+
+// ListObjectVersions lists all versions of the objects in Amazon S3 bucket, at prefix, newest first for each object.
+// Versions are only kept by buckets with versioning enabled; delete markers are not listed.
+func (b AmazonS3Backend) ListObjectVersions(prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjectVersions", prefix)
+	var objects []Object
+	prefix = pathutil.Join(b.Prefix, prefix)
+	s3Input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(b.Bucket),
+		Prefix: aws.String(prefix),
+	}
+	err = b.Client.ListObjectVersionsPagesWithContext(context.Background(), s3Input, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		for _, version := range page.Versions {
+			path := removePrefixFromObjectPath(prefix, aws.StringValue(version.Key))
+			if objectPathIsInvalid(path) {
+				continue
+			}
+			objects = append(objects, Object{
+				Meta:         Metadata{Name: path, Version: aws.StringValue(version.VersionId)},
+				Path:         path,
+				Content:      []byte{},
+				LastModified: aws.TimeValue(version.LastModified),
+				Size:         aws.Int64Value(version.Size),
+				ETag:         cleanETag(aws.StringValue(version.ETag)),
+				StorageClass: aws.StringValue(version.StorageClass),
+			})
+		}
+		return true
+	})
+	return objects, err
+}
+
+// DeleteObjectVersion permanently removes a version of an object from Amazon S3 bucket, at prefix
+func (b AmazonS3Backend) DeleteObjectVersion(path string, versionID string) (err error) {
+	defer b.wrapError(&err, "DeleteObjectVersion", path)
+	s3Input := &s3.DeleteObjectInput{
+		Bucket:    aws.String(b.Bucket),
+		Key:       aws.String(pathutil.Join(b.Prefix, path)),
+		VersionId: aws.String(versionID),
+	}
+	_, err = b.Client.DeleteObjectWithContext(context.Background(), s3Input)
+	return err
+}
+
+func (b AmazonS3Backend) wrapError(err *error, op string, path string) {
+	if wrapStorageError(err, "AmazonS3", op, path, amazonS3ErrorKind) {
+		logStorageError(b.logger, *err)
+	}
+}
+
+func amazonS3ErrorKind(err error) error {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case s3.ErrCodeNoSuchKey, s3.ErrCodeNoSuchBucket, "NotFound":
+			return ErrObjectNotFound
+		case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+			return ErrPermissionDenied
+		case "SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded":
+			return ErrThrottled
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return ErrPreconditionFailed
+		}
+	}
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) {
+		return kindFromStatusCode(requestFailure.StatusCode())
+	}
+	return nil
+}
@@ -17,33 +17,61 @@ limitations under the License.
 package storage
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"iter"
+	"log/slog"
+	"net/http"
 	"os"
 	pathutil "path"
+	"strings"
 	"time"
 
+	"github.com/baidubce/bce-sdk-go/bce"
 	"github.com/baidubce/bce-sdk-go/services/bos"
 	"github.com/baidubce/bce-sdk-go/services/bos/api"
 )
 
-// BaiduBOSBackend is a storage backend for Baidu Cloud BOS
+// BaiduBOSBackend is a storage backend for Baidu Cloud BOS.
+// The BOS SDK sends every request with a package-global HTTP client which cannot be replaced, so the requests of
+// this backend are not instrumented for tracing, and cannot be aborted: its ContextBackend methods only check
+// their context between requests, and it does not declare CapabilityContext.
 type BaiduBOSBackend struct {
 	Client *bos.Client
 	Bucket string
 	Prefix string
+	logger *slog.Logger
 }
 
-// NewBaiduBOSBackend creates a new instance of BaiduBOSBackend
+// NewBaiduBOSBackend creates a new instance of BaiduBOSBackend.
+// It panics if the backend cannot be created; NewBaiDuBOSBackendE returns the error instead.
 func NewBaiDuBOSBackend(bucket string, prefix string, endpoint string) *BaiduBOSBackend {
+	b, err := NewBaiDuBOSBackendE(bucket, prefix, endpoint)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// NewBaiDuBOSBackendE creates a new instance of BaiduBOSBackend, using the credentials of the
+// BAIDU_CLOUD_ACCESS_KEY_ID and BAIDU_CLOUD_ACCESS_KEY_SECRET environment variables
+func NewBaiDuBOSBackendE(bucket string, prefix string, endpoint string) (*BaiduBOSBackend, error) {
 	accessKeyId := os.Getenv("BAIDU_CLOUD_ACCESS_KEY_ID")
 	accessKeySecret := os.Getenv("BAIDU_CLOUD_ACCESS_KEY_SECRET")
 
+	if len(bucket) == 0 {
+		return nil, configError("baidu", "missing bucket")
+	}
+
 	if len(accessKeyId) == 0 {
-		panic("BAIDU_CLOUD_ACCESS_KEY_ID environment variable is not set")
+		return nil, configError("baidu", "BAIDU_CLOUD_ACCESS_KEY_ID environment variable is not set")
 	}
 
 	if len(accessKeySecret) == 0 {
-		panic("BAIDU_CLOUD_ACCESS_KEY_SECRET environment variable is not set")
+		return nil, configError("baidu", "BAIDU_CLOUD_ACCESS_KEY_SECRET environment variable is not set")
 	}
 
 	if len(endpoint) == 0 {
@@ -54,7 +82,7 @@ func NewBaiDuBOSBackend(bucket string, prefix string, endpoint string) *BaiduBOS
 	client, err := bos.NewClient(accessKeyId, accessKeySecret, endpoint)
 
 	if err != nil {
-		panic("Failed to create BOS client: " + err.Error())
+		return nil, configError("baidu", "failed to create BOS client for endpoint %q: %s", endpoint, err)
 	}
 
 	b := &BaiduBOSBackend{
@@ -62,20 +90,46 @@ func NewBaiDuBOSBackend(bucket string, prefix string, endpoint string) *BaiduBOS
 		Bucket: bucket,
 		Prefix: cleanPrefix(prefix),
 	}
-	return b
+	return b, nil
+}
+
+// SetLogger sets the logger of the failed operations on Baidu Cloud BOS. Nothing is logged without a logger.
+func (b *BaiduBOSBackend) SetLogger(logger *slog.Logger) {
+	b.logger = logger
+}
+
+// Capabilities returns the optional features supported by Baidu Cloud BOS. CapabilityContext is not declared,
+// as requests in flight are not aborted when their context is done.
+func (b BaiduBOSBackend) Capabilities() CapabilitySet {
+	return NewCapabilitySet(
+		CapabilityStream, CapabilityRange, CapabilityMetadata,
+		CapabilityStat, CapabilityHierarchical, CapabilityPaging, CapabilityBatchDelete,
+		CapabilityCopy, CapabilityPresign,
+	)
 }
 
 // ListObjects lists all objects in Baidu Cloud BOS bucket, at prefix
 func (b BaiduBOSBackend) ListObjects(prefix string) ([]Object, error) {
+	return b.ListObjectsContext(context.Background(), prefix)
+}
+
+// ListObjectsContext lists all objects in Baidu Cloud BOS bucket, at prefix.
+// The BOS client does not accept a context, so ctx is only checked before each page is requested, and a request
+// in flight is not aborted when ctx is done.
+func (b BaiduBOSBackend) ListObjectsContext(ctx context.Context, prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
     var objects []Object
 
     prefix = pathutil.Join(b.Prefix, prefix)
     listObjectsArgs := &api.ListObjectsArgs{
         Prefix:  prefix,
         Marker:  "",
-        MaxKeys: 1000,
+        MaxKeys: defaultPageSize,
     }
     for {
+        if err := ctx.Err(); err != nil {
+            return objects, err
+        }
         lor, err := b.Client.ListObjects(b.Bucket, listObjectsArgs)
         if err != nil {
             return objects, err
@@ -94,6 +148,9 @@ func (b BaiduBOSBackend) ListObjects(prefix string) ([]Object, error) {
                 Path:         path,
                 Content:      []byte{},
                 LastModified: lastModified,
+                Size:         int64(obj.Size),
+                ETag:         cleanETag(obj.ETag),
+                StorageClass: obj.StorageClass,
             }
             objects = append(objects, object)
         }
@@ -107,10 +164,108 @@ func (b BaiduBOSBackend) ListObjects(prefix string) ([]Object, error) {
     return objects, nil
 }
 
+// ListObjectsPage lists a page of the objects in Baidu Cloud BOS bucket, at prefix.
+// The token is the marker returned by BOS for the next page.
+func (b BaiduBOSBackend) ListObjectsPage(prefix string, token string, limit int) (_ ObjectPage, err error) {
+	defer b.wrapError(&err, "ListObjectsPage", prefix)
+	var page ObjectPage
+
+	prefix = listPrefix(b.Prefix, prefix)
+	listObjectsArgs := &api.ListObjectsArgs{
+		Prefix:    prefix,
+		Delimiter: "/",
+		Marker:    token,
+		MaxKeys:   pageSize(limit),
+	}
+	lor, err := b.Client.ListObjects(b.Bucket, listObjectsArgs)
+	if err != nil {
+		return page, err
+	}
+	for _, obj := range lor.Contents {
+		path := strings.TrimPrefix(obj.Key, prefix)
+		if objectPathIsInvalid(path) {
+			continue
+		}
+		lastModified, _ := time.Parse(time.RFC3339, obj.LastModified)
+		object := Object{
+			Path:         path,
+			Content:      []byte{},
+			LastModified: lastModified,
+			Size:         int64(obj.Size),
+			ETag:         cleanETag(obj.ETag),
+			StorageClass: obj.StorageClass,
+		}
+		page.Objects = append(page.Objects, object)
+	}
+	if lor.IsTruncated {
+		page.NextToken = lor.NextMarker
+	}
+	return page, nil
+}
+
+// ListObjectsIter iterates over the objects in Baidu Cloud BOS bucket, at prefix
+func (b BaiduBOSBackend) ListObjectsIter(prefix string) iter.Seq2[Object, error] {
+	return listObjectsIter(b, prefix)
+}
+
+// ListObjectsWithOptions lists the objects and common prefixes below prefix in Baidu Cloud BOS bucket
+func (b BaiduBOSBackend) ListObjectsWithOptions(prefix string, options ListObjectsOptions) (_ ListObjectsResult, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
+	var result ListObjectsResult
+
+	prefix = listPrefix(b.Prefix, prefix)
+	listObjectsArgs := &api.ListObjectsArgs{
+		Prefix:    prefix,
+		Delimiter: options.nativeDelimiter(),
+		MaxKeys:   1000,
+	}
+	for {
+		lor, err := b.Client.ListObjects(b.Bucket, listObjectsArgs)
+		if err != nil {
+			return result, err
+		}
+		for _, obj := range lor.Contents {
+			path := strings.TrimPrefix(obj.Key, prefix)
+			if objectPathIsInvalid(path) {
+				continue
+			}
+			lastModified, _ := time.Parse(time.RFC3339, obj.LastModified)
+			object := Object{
+				Path:         path,
+				Content:      []byte{},
+				LastModified: lastModified,
+				Size:         int64(obj.Size),
+				ETag:         cleanETag(obj.ETag),
+				StorageClass: obj.StorageClass,
+			}
+			result.Objects = append(result.Objects, object)
+		}
+		for _, commonPrefix := range lor.CommonPrefixes {
+			result.CommonPrefixes = append(result.CommonPrefixes, strings.TrimPrefix(commonPrefix.Prefix, prefix))
+		}
+		if !lor.IsTruncated {
+			break
+		}
+		listObjectsArgs.Marker = lor.NextMarker
+	}
+
+	return result, nil
+}
+
 // GetObject retrieves an object from Baidu Cloud BOS bucket, at prefix
 func (b BaiduBOSBackend) GetObject(path string) (Object, error) {
+	return b.GetObjectContext(context.Background(), path)
+}
+
+// GetObjectContext retrieves an object from Baidu Cloud BOS bucket, at prefix, unless ctx is done.
+// The BOS client does not accept a context, so ctx is only checked before the object is requested.
+func (b BaiduBOSBackend) GetObjectContext(ctx context.Context, path string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObject", path)
 	var object Object
 	object.Path = path
+	if err := ctx.Err(); err != nil {
+		return object, err
+	}
 	var content []byte
 	key := pathutil.Join(b.Prefix, path)
 	bosObject, err := b.Client.BasicGetObject(b.Bucket, key)
@@ -124,28 +279,223 @@ func (b BaiduBOSBackend) GetObject(path string) (Object, error) {
 	if err != nil {
 		return object, err
 	}
-	object.Content = content
-
-	meta, err := b.Client.GetObjectMeta(b.Bucket, key)
-	if err != nil {
-		return object, err
-	}
-	lastModified, err := time.Parse(time.RFC1123, meta.LastModified)
-	object.LastModified = lastModified
-	return object, nil
+	return objectWithInfo(baiduBOSObjectInfo(path, &bosObject.ObjectMeta), content), nil
 }
 
 // PutObject uploads an object to Baidu Cloud BOS bucket, at prefix
 func (b BaiduBOSBackend) PutObject(path string, content []byte) error {
+	return b.PutObjectContext(context.Background(), path, content)
+}
+
+// PutObjectContext uploads an object to Baidu Cloud BOS bucket, at prefix, unless ctx is done.
+// The BOS client does not accept a context, so ctx is only checked before the object is uploaded.
+func (b BaiduBOSBackend) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	defer b.wrapError(&err, "PutObject", path)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	key := pathutil.Join(b.Prefix, path)
-	var err error
 	_, err = b.Client.PutObjectFromBytes(b.Bucket, key, content, nil)
 	return err
 }
 
+// PutObjectWithOptions uploads an object to Baidu Cloud BOS bucket, at prefix, along with its content type,
+// storage class and user metadata
+func (b BaiduBOSBackend) PutObjectWithOptions(path string, content []byte, options PutObjectOptions) (err error) {
+	defer b.wrapError(&err, "PutObjectWithOptions", path)
+	key := pathutil.Join(b.Prefix, path)
+	args := &api.PutObjectArgs{
+		ContentType:  options.ContentType,
+		StorageClass: options.StorageClass,
+		UserMeta:     options.UserMetadata,
+	}
+	_, err = b.Client.PutObjectFromBytes(b.Bucket, key, content, args)
+	return err
+}
+
+// GetObjectReader opens an object in Baidu Cloud BOS bucket, at prefix, for reading
+func (b BaiduBOSBackend) GetObjectReader(path string) (_ io.ReadCloser, _ ObjectInfo, err error) {
+	defer b.wrapError(&err, "GetObjectReader", path)
+	info := ObjectInfo{Path: path}
+	key := pathutil.Join(b.Prefix, path)
+	bosObject, err := b.Client.BasicGetObject(b.Bucket, key)
+	if err != nil {
+		return nil, info, err
+	}
+	return bosObject.Body, baiduBOSObjectInfo(path, &bosObject.ObjectMeta), nil
+}
+
+// GetObjectRange reads part of an object from Baidu Cloud BOS bucket, at prefix, with an HTTP range request
+func (b BaiduBOSBackend) GetObjectRange(path string, offset int64, length int64) (_ []byte, err error) {
+	defer b.wrapError(&err, "GetObjectRange", path)
+	return getObjectRange(offset, length, baiduBOSErrorKind, func(offset int64, length int64) ([]byte, error) {
+		bounds := []int64{offset}
+		if length >= 0 {
+			bounds = append(bounds, offset+length-1)
+		}
+		bosObject, err := b.Client.GetObject(b.Bucket, pathutil.Join(b.Prefix, path), nil, bounds...)
+		if err != nil {
+			return nil, err
+		}
+		defer bosObject.Body.Close()
+		return io.ReadAll(bosObject.Body)
+	})
+}
+
+// PutObjectStream uploads an object to Baidu Cloud BOS bucket, at prefix, from a stream.
+// The BOS client buffers request bodies, so content larger than one part of maxChunkSize
+// bytes is sent as a multipart upload to bound memory usage.
+func (b BaiduBOSBackend) PutObjectStream(path string, content io.Reader, size int64) (err error) {
+	defer b.wrapError(&err, "PutObjectStream", path)
+	key := pathutil.Join(b.Prefix, path)
+	chunk := make([]byte, maxChunkSize)
+	n, err := io.ReadFull(content, chunk)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		_, err = b.Client.PutObjectFromBytes(b.Bucket, key, chunk[:n], nil)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	upload, err := b.Client.BasicInitiateMultipartUpload(b.Bucket, key)
+	if err != nil {
+		return err
+	}
+	var parts []api.UploadInfoType
+	for partNumber := 1; n > 0; partNumber++ {
+		body, err := bce.NewBodyFromBytes(chunk[:n])
+		if err == nil {
+			var etag string
+			etag, err = b.Client.BasicUploadPart(b.Bucket, key, upload.UploadId, partNumber, body)
+			parts = append(parts, api.UploadInfoType{PartNumber: partNumber, ETag: etag})
+		}
+		if err == nil {
+			n, err = io.ReadFull(content, chunk)
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = nil
+			}
+		}
+		if err != nil {
+			b.Client.AbortMultipartUpload(b.Bucket, key, upload.UploadId)
+			return err
+		}
+	}
+	_, err = b.Client.CompleteMultipartUploadFromStruct(b.Bucket, key, upload.UploadId,
+		&api.CompleteMultipartUploadArgs{Parts: parts})
+	return err
+}
+
+// StatObject retrieves the meta information of an object in Baidu Cloud BOS bucket, at prefix
+func (b BaiduBOSBackend) StatObject(path string) (_ ObjectInfo, err error) {
+	defer b.wrapError(&err, "StatObject", path)
+	info := ObjectInfo{Path: path}
+	meta, err := b.Client.GetObjectMeta(b.Bucket, pathutil.Join(b.Prefix, path))
+	if err != nil {
+		return info, err
+	}
+	return baiduBOSObjectInfo(path, &meta.ObjectMeta), nil
+}
+
 // DeleteObject removes an object from Baidu Cloud BOS bucket, at prefix
 func (b BaiduBOSBackend) DeleteObject(path string) error {
+	return b.DeleteObjectContext(context.Background(), path)
+}
+
+// DeleteObjectContext removes an object from Baidu Cloud BOS bucket, at prefix, unless ctx is done.
+// The BOS client does not accept a context, so ctx is only checked before the object is deleted.
+func (b BaiduBOSBackend) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	defer b.wrapError(&err, "DeleteObject", path)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	key := pathutil.Join(b.Prefix, path)
-	err := b.Client.DeleteObject(b.Bucket, key)
+	err = b.Client.DeleteObject(b.Bucket, key)
 	return err
 }
+
+// baiduBOSObjectInfo builds an ObjectInfo from the meta information of an object
+func baiduBOSObjectInfo(path string, meta *api.ObjectMeta) ObjectInfo {
+	lastModified, _ := time.Parse(time.RFC1123, meta.LastModified)
+	return ObjectInfo{
+		Path:         path,
+		Size:         meta.ContentLength,
+		LastModified: lastModified,
+		ETag:         cleanETag(meta.ETag),
+		ContentType:  meta.ContentType,
+		StorageClass: meta.StorageClass,
+		UserMetadata: lowerCaseKeys(meta.UserMeta),
+	}
+}
+
+// DeleteObjects removes objects from Baidu Cloud BOS bucket, at prefix, with up to 1000 keys per request
+func (b BaiduBOSBackend) DeleteObjects(paths []string) ([]DeleteResult, error) {
+	return deleteObjectsInBatches(paths, maxDeleteBatchSize, b.deleteBatch)
+}
+
+// deleteBatch removes the objects of a batch from Baidu Cloud BOS bucket, at prefix, with a single request
+func (b BaiduBOSBackend) deleteBatch(batch []DeleteResult) (err error) {
+	defer b.wrapError(&err, "DeleteObjects", "")
+	results := make(map[string]*DeleteResult, len(batch))
+	keys := make([]string, len(batch))
+	for i := range batch {
+		keys[i] = pathutil.Join(b.Prefix, batch[i].Path)
+		results[keys[i]] = &batch[i]
+	}
+	deleteResult, err := b.Client.DeleteMultipleObjectsFromKeyList(b.Bucket, keys)
+	if err != nil {
+		return err
+	}
+	for _, bosError := range deleteResult.Errors {
+		if result, ok := results[bosError.Key]; ok {
+			result.Err = fmt.Errorf("%s: %s", bosError.Code, bosError.Message)
+			b.wrapError(&result.Err, "DeleteObjects", result.Path)
+		}
+	}
+	return nil
+}
+
+// CopyObject copies an object within Baidu Cloud BOS bucket, at prefix, without downloading it
+func (b BaiduBOSBackend) CopyObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "CopyObject", src)
+	_, err = b.Client.BasicCopyObject(b.Bucket, pathutil.Join(b.Prefix, dst), b.Bucket, pathutil.Join(b.Prefix, src))
+	return err
+}
+
+// MoveObject copies an object within Baidu Cloud BOS bucket, at prefix, and deletes the original
+func (b BaiduBOSBackend) MoveObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "MoveObject", src)
+	return moveObject(src, dst, b.CopyObject, b.DeleteObject)
+}
+
+// PresignGet generates a signed URL to download an object from Baidu Cloud BOS bucket, at prefix, until ttl expires
+func (b BaiduBOSBackend) PresignGet(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignGet", path)
+	if err := validatePresignTTL(ttl); err != nil {
+		return "", err
+	}
+	return b.Client.GeneratePresignedUrl(b.Bucket, pathutil.Join(b.Prefix, path), int(ttl.Seconds()), http.MethodGet, nil, nil), nil
+}
+
+// PresignPut generates a signed URL to upload an object to Baidu Cloud BOS bucket, at prefix, until ttl expires
+func (b BaiduBOSBackend) PresignPut(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignPut", path)
+	if err := validatePresignTTL(ttl); err != nil {
+		return "", err
+	}
+	return b.Client.GeneratePresignedUrl(b.Bucket, pathutil.Join(b.Prefix, path), int(ttl.Seconds()), http.MethodPut, nil, nil), nil
+}
+
+func (b BaiduBOSBackend) wrapError(err *error, op string, path string) {
+	if wrapStorageError(err, "BaiduCloudBOS", op, path, baiduBOSErrorKind) {
+		logStorageError(b.logger, *err)
+	}
+}
+
+func baiduBOSErrorKind(err error) error {
+	var serviceErr *bce.BceServiceError
+	if errors.As(err, &serviceErr) {
+		return kindFromStatusCode(serviceErr.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"maps"
+	pathutil "path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheMaxBytes is the size of the content cached by a CachingBackend, unless configured otherwise
+const DefaultCacheMaxBytes = 64 << 20
+
+type (
+	// CachingOptions configures a CachingBackend
+	CachingOptions struct {
+		// MaxBytes bounds the total size of the cached content; objects larger than MaxBytes are not cached.
+		// DefaultCacheMaxBytes is used if MaxBytes is not positive.
+		MaxBytes int64
+		// MaxAge is how long cached content is served without being revalidated. Older content is revalidated
+		// against the LastModified and ETag of the object if the backend is a StatBackend, and fetched again
+		// otherwise. Zero revalidates content on every GetObject.
+		MaxAge time.Duration
+		// ListTTL is how long the result of ListObjects is cached. Zero disables caching of listings.
+		ListTTL time.Duration
+	}
+
+	// CacheStats counts the requests served by a CachingBackend
+	CacheStats struct {
+		Hits          int64
+		Misses        int64
+		Revalidations int64
+		Evictions     int64
+		ListHits      int64
+		ListMisses    int64
+		Bytes         int64
+	}
+
+	// CachingBackend is a Backend which caches the objects and listings of another Backend in memory.
+	// Content is kept in a least recently used cache bounded in size, and listings for a limited time.
+	// Objects put or deleted through the CachingBackend are invalidated, while changes made by other
+	// clients are detected when content is revalidated, or when listings expire.
+	CachingBackend struct {
+		backend Backend
+		options CachingOptions
+
+		mu       sync.Mutex
+		lru      *list.List
+		objects  map[string]*list.Element
+		listings map[string]cachedListing
+		stats    CacheStats
+		// generation counts invalidations and purges, so that objects fetched before them are not cached
+		generation uint64
+	}
+
+	// cachedObject is an object held by the least recently used cache
+	cachedObject struct {
+		key       string
+		object    Object
+		fetchedAt time.Time
+	}
+
+	// cachedListing is the result of ListObjects for a prefix, until it expires
+	cachedListing struct {
+		objects   []Object
+		expiresAt time.Time
+	}
+)
+
+// NewCachingBackend creates a new instance of CachingBackend caching the objects of backend
+func NewCachingBackend(backend Backend, options CachingOptions) *CachingBackend {
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DefaultCacheMaxBytes
+	}
+	return &CachingBackend{
+		backend:  backend,
+		options:  options,
+		lru:      list.New(),
+		objects:  make(map[string]*list.Element),
+		listings: make(map[string]cachedListing),
+	}
+}
+
+// Capabilities returns the optional features supported by the CachingBackend, which lists content
+// if the cached backend does
+func (c *CachingBackend) Capabilities() CapabilitySet {
+	return CapabilitiesOf(c.backend) & NewCapabilitySet(CapabilityListContent)
+}
+
+// Stats returns the counters of the cache
+func (c *CachingBackend) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Purge empties the cache. Objects and listings being fetched while purging are not cached.
+func (c *CachingBackend) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.lru.Init()
+	c.objects = make(map[string]*list.Element)
+	c.listings = make(map[string]cachedListing)
+	c.stats.Bytes = 0
+}
+
+// ListObjects lists the objects at prefix, from the cache until the listing expires
+func (c *CachingBackend) ListObjects(prefix string) ([]Object, error) {
+	if c.options.ListTTL <= 0 {
+		return c.backend.ListObjects(prefix)
+	}
+	key := cacheKey(prefix)
+	c.mu.Lock()
+	if listing, ok := c.listings[key]; ok && time.Now().Before(listing.expiresAt) {
+		c.stats.ListHits++
+		c.mu.Unlock()
+		return copyObjects(listing.objects), nil
+	}
+	c.stats.ListMisses++
+	generation := c.generation
+	c.mu.Unlock()
+
+	objects, err := c.backend.ListObjects(prefix)
+	if err != nil {
+		return objects, err
+	}
+	c.mu.Lock()
+	if generation == c.generation {
+		c.listings[key] = cachedListing{
+			objects:   copyObjects(objects),
+			expiresAt: time.Now().Add(c.options.ListTTL),
+		}
+	}
+	c.mu.Unlock()
+	return objects, nil
+}
+
+// GetObject retrieves an object from the cache, revalidating it once it is older than MaxAge,
+// or from the cached backend if it is not cached
+func (c *CachingBackend) GetObject(path string) (Object, error) {
+	key := cacheKey(path)
+	c.mu.Lock()
+	element, ok := c.objects[key]
+	var entry *cachedObject
+	var cached cachedObject
+	if ok {
+		entry = element.Value.(*cachedObject)
+		cached = *entry
+		c.lru.MoveToFront(element)
+	}
+	c.mu.Unlock()
+
+	if ok {
+		fresh, err := c.fresh(path, entry, cached)
+		if err != nil {
+			if errors.Is(err, ErrObjectNotFound) {
+				c.invalidate(path)
+			}
+			return Object{Path: path}, err
+		}
+		if fresh {
+			c.mu.Lock()
+			c.stats.Hits++
+			c.mu.Unlock()
+			return copyObject(cached.object), nil
+		}
+	}
+
+	c.mu.Lock()
+	c.stats.Misses++
+	generation := c.generation
+	c.mu.Unlock()
+	object, err := c.backend.GetObject(path)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			c.invalidate(path)
+		}
+		return object, err
+	}
+	c.add(key, object, generation)
+	return copyObject(object), nil
+}
+
+// PutObject uploads an object through the cached backend, invalidating the object and the listings holding it
+func (c *CachingBackend) PutObject(path string, content []byte) error {
+	defer c.invalidate(path)
+	return c.backend.PutObject(path, content)
+}
+
+// DeleteObject removes an object through the cached backend, invalidating the object and the listings holding it
+func (c *CachingBackend) DeleteObject(path string) error {
+	defer c.invalidate(path)
+	return c.backend.DeleteObject(path)
+}
+
+// fresh determines whether or not a cached object can be served: it is either younger than MaxAge, or
+// still has the LastModified and ETag of the object in the cached backend. cached is a copy of entry,
+// read while holding the lock.
+func (c *CachingBackend) fresh(path string, entry *cachedObject, cached cachedObject) (bool, error) {
+	if time.Since(cached.fetchedAt) < c.options.MaxAge {
+		return true, nil
+	}
+	statBackend, ok := c.backend.(StatBackend)
+	if !ok || !Supports(c.backend, CapabilityStat) {
+		return false, nil
+	}
+	c.mu.Lock()
+	c.stats.Revalidations++
+	c.mu.Unlock()
+	info, err := statBackend.StatObject(path)
+	if err != nil || !sameVersion(cached.object.Info(), info) {
+		return false, err
+	}
+	// the revalidated object is served without revalidation for another MaxAge, unless it was replaced
+	// by another object fetched in the meantime, which was not revalidated
+	c.mu.Lock()
+	if element, ok := c.objects[cacheKey(path)]; ok && element.Value == entry {
+		entry.fetchedAt = time.Now()
+	}
+	c.mu.Unlock()
+	return true, nil
+}
+
+// add caches an object fetched at generation, evicting the least recently used objects to stay within MaxBytes
+func (c *CachingBackend) add(key string, object Object, generation uint64) {
+	size := int64(len(object.Content))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.objects[key]; ok {
+		c.remove(element)
+	}
+	if size > c.options.MaxBytes || generation != c.generation {
+		return
+	}
+	for c.stats.Bytes+size > c.options.MaxBytes {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+	c.objects[key] = c.lru.PushFront(&cachedObject{key: key, object: copyObject(object), fetchedAt: time.Now()})
+	c.stats.Bytes += size
+}
+
+// remove drops an element of the least recently used cache; the lock must be held
+func (c *CachingBackend) remove(element *list.Element) {
+	cached := c.lru.Remove(element).(*cachedObject)
+	delete(c.objects, cached.key)
+	c.stats.Bytes -= int64(len(cached.object.Content))
+}
+
+// invalidate drops an object, and the listings of the prefixes it is below, from the cache
+func (c *CachingBackend) invalidate(path string) {
+	key := cacheKey(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if element, ok := c.objects[key]; ok {
+		c.remove(element)
+	}
+	for prefix := range c.listings {
+		if prefix == "" || strings.HasPrefix(key, prefix+"/") {
+			delete(c.listings, prefix)
+		}
+	}
+}
+
+// cacheKey normalizes a path or prefix, so that equivalent paths share their cache entries
+func cacheKey(path string) string {
+	return strings.Trim(pathutil.Clean("/"+path), "/")
+}
+
+// sameVersion determines whether or not two descriptions of an object designate the same version of it,
+// comparing their ETags when both are known, and their modification times and sizes otherwise
+func sameVersion(cached ObjectInfo, current ObjectInfo) bool {
+	if cached.ETag != "" && current.ETag != "" {
+		return cached.ETag == current.ETag
+	}
+	if current.Size >= 0 && cached.Size != current.Size {
+		return false
+	}
+	return cached.LastModified.Equal(current.LastModified)
+}
+
+// copyObject returns a copy of an object which does not share its content or user metadata
+func copyObject(object Object) Object {
+	object.Content = bytes.Clone(object.Content)
+	object.UserMetadata = maps.Clone(object.UserMetadata)
+	return object
+}
+
+// copyObjects returns copies of objects, as copyObject does
+func copyObjects(objects []Object) []Object {
+	var copies []Object
+	for _, object := range objects {
+		copies = append(copies, copyObject(object))
+	}
+	return copies
+}
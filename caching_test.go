@@ -0,0 +1,342 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// countingBackend counts the calls made to the methods of a Backend
+type countingBackend struct {
+	Backend
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newCountingBackend(backend Backend) *countingBackend {
+	return &countingBackend{Backend: backend, calls: make(map[string]int)}
+}
+
+func (b *countingBackend) count(op string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls[op]++
+}
+
+func (b *countingBackend) Calls(op string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls[op]
+}
+
+func (b *countingBackend) ListObjects(prefix string) ([]Object, error) {
+	b.count("ListObjects")
+	return b.Backend.ListObjects(prefix)
+}
+
+func (b *countingBackend) GetObject(path string) (Object, error) {
+	b.count("GetObject")
+	return b.Backend.GetObject(path)
+}
+
+func (b *countingBackend) PutObject(path string, content []byte) error {
+	b.count("PutObject")
+	return b.Backend.PutObject(path, content)
+}
+
+func (b *countingBackend) DeleteObject(path string) error {
+	b.count("DeleteObject")
+	return b.Backend.DeleteObject(path)
+}
+
+// countingStatBackend also counts the calls made to StatObject
+type countingStatBackend struct {
+	*countingBackend
+}
+
+func (b countingStatBackend) StatObject(path string) (ObjectInfo, error) {
+	b.count("StatObject")
+	return b.Backend.(StatBackend).StatObject(path)
+}
+
+// hookStatBackend calls onStat, if set, before getting the information of an object
+type hookStatBackend struct {
+	countingStatBackend
+	onStat func()
+}
+
+func (b *hookStatBackend) StatObject(path string) (ObjectInfo, error) {
+	if b.onStat != nil {
+		b.onStat()
+	}
+	return b.countingStatBackend.StatObject(path)
+}
+
+// userMetadataBackend gets objects along with user metadata
+type userMetadataBackend struct {
+	Backend
+}
+
+func (b userMetadataBackend) GetObject(path string) (Object, error) {
+	object, err := b.Backend.GetObject(path)
+	object.UserMetadata = map[string]string{"owner": "charts"}
+	return object, err
+}
+
+// purgingBackend calls purge, if set, while getting or listing objects
+type purgingBackend struct {
+	Backend
+	purge func()
+}
+
+func (b *purgingBackend) GetObject(path string) (Object, error) {
+	if b.purge != nil {
+		b.purge()
+	}
+	return b.Backend.GetObject(path)
+}
+
+func (b *purgingBackend) ListObjects(prefix string) ([]Object, error) {
+	if b.purge != nil {
+		b.purge()
+	}
+	return b.Backend.ListObjects(prefix)
+}
+
+type CachingTestSuite struct {
+	suite.Suite
+	TempDirectory string
+	Local         *LocalFilesystemBackend
+}
+
+func (suite *CachingTestSuite) SetupTest() {
+	timestamp := time.Now().Format("20060102150405.000000")
+	suite.TempDirectory = fmt.Sprintf("../../.test/storage-caching/%s", timestamp)
+	suite.Local = NewLocalFilesystemBackend(suite.TempDirectory)
+}
+
+func (suite *CachingTestSuite) TearDownTest() {
+	os.RemoveAll(suite.TempDirectory)
+}
+
+func (suite *CachingTestSuite) TestGetObject() {
+	counting := newCountingBackend(suite.Local)
+	cache := NewCachingBackend(counting, CachingOptions{MaxAge: time.Hour})
+	suite.Nil(suite.Local.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+
+	for i := 0; i < 3; i++ {
+		object, err := cache.GetObject("mychart-0.1.0.tgz")
+		suite.Nil(err, "no error getting object through cache")
+		suite.Equal([]byte("mychart"), object.Content, "cached content is returned")
+		object.Content[0] = 'M'
+	}
+	suite.Equal(1, counting.Calls("GetObject"), "object is fetched once")
+	stats := cache.Stats()
+	suite.Equal(int64(2), stats.Hits)
+	suite.Equal(int64(1), stats.Misses)
+	suite.Equal(int64(len("mychart")), stats.Bytes)
+
+	_, err := cache.GetObject("missing.tgz")
+	suite.True(errors.Is(err, ErrObjectNotFound), "missing object is not found through cache")
+}
+
+func (suite *CachingTestSuite) TestRevalidation() {
+	counting := newCountingBackend(suite.Local)
+	cache := NewCachingBackend(countingStatBackend{counting}, CachingOptions{})
+	suite.Nil(suite.Local.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+
+	_, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+	object, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err, "no error getting revalidated object")
+	suite.Equal([]byte("mychart"), object.Content)
+	suite.Equal(1, counting.Calls("GetObject"), "unchanged object is not fetched again")
+	suite.Equal(1, counting.Calls("StatObject"), "cached object is revalidated")
+
+	suite.Nil(suite.Local.PutObject("mychart-0.1.0.tgz", []byte("mychart changed")))
+	object, err = cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err, "no error getting changed object")
+	suite.Equal([]byte("mychart changed"), object.Content, "changed object is fetched again")
+	suite.Equal(2, counting.Calls("GetObject"))
+
+	suite.Nil(suite.Local.DeleteObject("mychart-0.1.0.tgz"))
+	_, err = cache.GetObject("mychart-0.1.0.tgz")
+	suite.True(errors.Is(err, ErrObjectNotFound), "object deleted by another client is not found")
+	suite.Equal(int64(0), cache.Stats().Bytes, "deleted object is evicted")
+}
+
+func (suite *CachingTestSuite) TestConcurrentRevalidation() {
+	counting := newCountingBackend(suite.Local)
+	stat := &hookStatBackend{countingStatBackend: countingStatBackend{counting}}
+	cache := NewCachingBackend(stat, CachingOptions{})
+	suite.Nil(suite.Local.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+	_, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+
+	// another goroutine caches a newer object while the cached one is revalidated
+	stale := time.Now().Add(-time.Hour)
+	stat.onStat = func() {
+		cache.add("mychart-0.1.0.tgz", Object{Path: "mychart-0.1.0.tgz", Content: []byte("newer")}, cache.generation)
+		cache.objects["mychart-0.1.0.tgz"].Value.(*cachedObject).fetchedAt = stale
+	}
+	object, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+	suite.Equal([]byte("mychart"), object.Content, "revalidated object is served")
+	suite.Equal(stale, cache.objects["mychart-0.1.0.tgz"].Value.(*cachedObject).fetchedAt,
+		"object cached during the revalidation is not marked as revalidated")
+}
+
+func (suite *CachingTestSuite) TestUserMetadataIsCopied() {
+	cache := NewCachingBackend(userMetadataBackend{suite.Local}, CachingOptions{MaxAge: time.Hour})
+	suite.Nil(suite.Local.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+
+	object, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+	object.UserMetadata["owner"] = "changed"
+	object, err = cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+	suite.Equal("charts", object.UserMetadata["owner"], "user metadata of cached object is not changed by callers")
+}
+
+func (suite *CachingTestSuite) TestRevalidationWithoutStat() {
+	counting := newCountingBackend(suite.Local)
+	cache := NewCachingBackend(counting, CachingOptions{})
+	suite.Nil(suite.Local.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetObject("mychart-0.1.0.tgz")
+		suite.Nil(err)
+	}
+	suite.Equal(2, counting.Calls("GetObject"), "objects which cannot be revalidated are fetched again")
+}
+
+func (suite *CachingTestSuite) TestEviction() {
+	counting := newCountingBackend(suite.Local)
+	cache := NewCachingBackend(counting, CachingOptions{MaxBytes: 10, MaxAge: time.Hour})
+	for _, path := range []string{"a.tgz", "b.tgz", "c.tgz"} {
+		suite.Nil(suite.Local.PutObject(path, []byte("four")))
+	}
+	suite.Nil(suite.Local.PutObject("large.tgz", []byte("larger than the cache")))
+
+	for _, path := range []string{"a.tgz", "b.tgz", "a.tgz", "c.tgz"} {
+		_, err := cache.GetObject(path)
+		suite.Nil(err)
+	}
+	stats := cache.Stats()
+	suite.Equal(int64(1), stats.Evictions, "least recently used object is evicted")
+	suite.Equal(int64(8), stats.Bytes, "cached content stays within MaxBytes")
+
+	_, err := cache.GetObject("a.tgz")
+	suite.Nil(err)
+	suite.Equal(3, counting.Calls("GetObject"), "recently used object is still cached")
+	_, err = cache.GetObject("b.tgz")
+	suite.Nil(err)
+	suite.Equal(4, counting.Calls("GetObject"), "evicted object is fetched again")
+
+	for i := 0; i < 2; i++ {
+		_, err = cache.GetObject("large.tgz")
+		suite.Nil(err)
+	}
+	suite.Equal(6, counting.Calls("GetObject"), "objects larger than the cache are not cached")
+}
+
+func (suite *CachingTestSuite) TestListObjects() {
+	counting := newCountingBackend(suite.Local)
+	cache := NewCachingBackend(counting, CachingOptions{ListTTL: time.Hour})
+	suite.Nil(suite.Local.PutObject("stable/mychart-0.1.0.tgz", []byte("mychart")))
+
+	for i := 0; i < 2; i++ {
+		objects, err := cache.ListObjects("stable")
+		suite.Nil(err, "no error listing objects through cache")
+		suite.Len(objects, 1)
+	}
+	suite.Equal(1, counting.Calls("ListObjects"), "listing is cached")
+
+	suite.Nil(cache.PutObject("incubator/mychart-0.1.0.tgz", []byte("mychart")))
+	_, err := cache.ListObjects("stable")
+	suite.Nil(err)
+	suite.Equal(1, counting.Calls("ListObjects"), "listing of other prefixes is not invalidated")
+
+	suite.Nil(cache.PutObject("stable/mychart-0.2.0.tgz", []byte("mychart")))
+	objects, err := cache.ListObjects("stable/")
+	suite.Nil(err)
+	suite.Len(objects, 2, "put object is listed")
+	suite.Equal(2, counting.Calls("ListObjects"), "listing of the prefix is invalidated by a put")
+
+	expired := NewCachingBackend(counting, CachingOptions{ListTTL: time.Nanosecond})
+	for i := 0; i < 2; i++ {
+		_, err := expired.ListObjects("stable")
+		suite.Nil(err)
+		time.Sleep(time.Millisecond)
+	}
+	suite.Equal(4, counting.Calls("ListObjects"), "expired listing is listed again")
+}
+
+func (suite *CachingTestSuite) TestInvalidation() {
+	counting := newCountingBackend(suite.Local)
+	cache := NewCachingBackend(counting, CachingOptions{MaxAge: time.Hour})
+	suite.Nil(cache.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+	_, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+
+	suite.Nil(cache.PutObject("./mychart-0.1.0.tgz", []byte("mychart changed")))
+	object, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+	suite.Equal([]byte("mychart changed"), object.Content, "put object is invalidated")
+
+	suite.Nil(cache.DeleteObject("mychart-0.1.0.tgz"))
+	_, err = cache.GetObject("mychart-0.1.0.tgz")
+	suite.True(errors.Is(err, ErrObjectNotFound), "deleted object is invalidated")
+
+	suite.Nil(cache.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+	_, err = cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+	cache.Purge()
+	suite.Equal(int64(0), cache.Stats().Bytes, "purged cache is empty")
+}
+
+func (suite *CachingTestSuite) TestPurgeDuringFetch() {
+	purging := &purgingBackend{Backend: suite.Local}
+	cache := NewCachingBackend(purging, CachingOptions{MaxAge: time.Hour, ListTTL: time.Hour})
+	suite.Nil(suite.Local.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+
+	purging.purge = cache.Purge
+	_, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+	_, err = cache.ListObjects("")
+	suite.Nil(err)
+	purging.purge = nil
+
+	suite.Nil(suite.Local.PutObject("mychart-0.1.0.tgz", []byte("mychart changed")))
+	object, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+	suite.Equal([]byte("mychart changed"), object.Content, "object got while purging is not cached")
+	_, err = cache.ListObjects("")
+	suite.Nil(err)
+	suite.Equal(int64(2), cache.Stats().ListMisses, "listing made while purging is not cached")
+}
+
+func TestCachingTestSuite(t *testing.T) {
+	suite.Run(t, new(CachingTestSuite))
+}
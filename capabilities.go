@@ -0,0 +1,253 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// Capability identifies an optional feature of a Backend
+type Capability uint32
+
+const (
+	// CapabilityContext means the backend implements ContextBackend, and aborts its requests when their context is done
+	CapabilityContext Capability = 1 << iota
+	// CapabilityStream means the backend implements StreamBackend
+	CapabilityStream
+	// CapabilityRange means the backend implements RangeBackend
+	CapabilityRange
+	// CapabilityMetadata means the backend implements MetadataBackend
+	CapabilityMetadata
+	// CapabilityStat means the backend implements StatBackend
+	CapabilityStat
+	// CapabilityHierarchical means the backend implements HierarchicalBackend
+	CapabilityHierarchical
+	// CapabilityPaging means the backend implements PagingBackend
+	CapabilityPaging
+	// CapabilityBatchDelete means the backend implements BatchDeleteBackend
+	CapabilityBatchDelete
+	// CapabilityCopy means the backend implements CopyBackend
+	CapabilityCopy
+	// CapabilityPresign means the backend implements Presigner
+	CapabilityPresign
+	// CapabilityConditional means the backend implements ConditionalBackend
+	CapabilityConditional
+	// CapabilityVersioning means the backend implements VersionedBackend
+	CapabilityVersioning
+	// CapabilityListContent means ListObjects returns the content of the objects, not only their information
+	CapabilityListContent
+	// CapabilityWatch means the backend implements WatchBackend
+	CapabilityWatch
+)
+
+var capabilityNames = map[Capability]string{
+	CapabilityContext:      "context",
+	CapabilityStream:       "stream",
+	CapabilityRange:        "range",
+	CapabilityMetadata:     "metadata",
+	CapabilityStat:         "stat",
+	CapabilityHierarchical: "hierarchical",
+	CapabilityPaging:       "paging",
+	CapabilityBatchDelete:  "batch-delete",
+	CapabilityCopy:         "copy",
+	CapabilityPresign:      "presign",
+	CapabilityConditional:  "conditional",
+	CapabilityVersioning:   "versioning",
+	CapabilityListContent:  "list-content",
+	CapabilityWatch:        "watch",
+}
+
+func (c Capability) String() string {
+	if name, ok := capabilityNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// CapabilitySet is a set of Capabilities
+type CapabilitySet uint32
+
+// NewCapabilitySet returns the set of the given capabilities
+func NewCapabilitySet(capabilities ...Capability) CapabilitySet {
+	var set CapabilitySet
+	for _, capability := range capabilities {
+		set |= CapabilitySet(capability)
+	}
+	return set
+}
+
+// Has determines whether or not the set contains capability
+func (set CapabilitySet) Has(capability Capability) bool {
+	return set&CapabilitySet(capability) != 0
+}
+
+// With returns the set with capability added
+func (set CapabilitySet) With(capability Capability) CapabilitySet {
+	return set | CapabilitySet(capability)
+}
+
+// Without returns the set with capability removed
+func (set CapabilitySet) Without(capability Capability) CapabilitySet {
+	return set &^ CapabilitySet(capability)
+}
+
+// List returns the capabilities of the set, in the order they are declared
+func (set CapabilitySet) List() []Capability {
+	capabilities := make([]Capability, 0, bits.OnesCount32(uint32(set)))
+	for remaining := uint32(set); remaining != 0; remaining &= remaining - 1 {
+		capabilities = append(capabilities, Capability(1<<bits.TrailingZeros32(remaining)))
+	}
+	return capabilities
+}
+
+func (set CapabilitySet) String() string {
+	names := make([]string, 0, bits.OnesCount32(uint32(set)))
+	for _, capability := range set.List() {
+		names = append(names, capability.String())
+	}
+	return strings.Join(names, ",")
+}
+
+// CapabilitiesOf returns the capabilities of a backend. Backends which do not implement CapabilityBackend
+// are inspected for the optional interfaces they implement; CapabilityListContent cannot be detected that way.
+func CapabilitiesOf(backend Backend) CapabilitySet {
+	if cb, ok := backend.(CapabilityBackend); ok {
+		return cb.Capabilities()
+	}
+	return implementedCapabilities(backend)
+}
+
+// implementedCapabilities returns the capabilities matching the optional interfaces implemented by a backend
+func implementedCapabilities(backend Backend) CapabilitySet {
+	var set CapabilitySet
+	if _, ok := backend.(ContextBackend); ok {
+		set = set.With(CapabilityContext)
+	}
+	if _, ok := backend.(StreamBackend); ok {
+		set = set.With(CapabilityStream)
+	}
+	if _, ok := backend.(RangeBackend); ok {
+		set = set.With(CapabilityRange)
+	}
+	if _, ok := backend.(MetadataBackend); ok {
+		set = set.With(CapabilityMetadata)
+	}
+	if _, ok := backend.(StatBackend); ok {
+		set = set.With(CapabilityStat)
+	}
+	if _, ok := backend.(HierarchicalBackend); ok {
+		set = set.With(CapabilityHierarchical)
+	}
+	if _, ok := backend.(PagingBackend); ok {
+		set = set.With(CapabilityPaging)
+	}
+	if _, ok := backend.(BatchDeleteBackend); ok {
+		set = set.With(CapabilityBatchDelete)
+	}
+	if _, ok := backend.(CopyBackend); ok {
+		set = set.With(CapabilityCopy)
+	}
+	if _, ok := backend.(Presigner); ok {
+		set = set.With(CapabilityPresign)
+	}
+	if _, ok := backend.(ConditionalBackend); ok {
+		set = set.With(CapabilityConditional)
+	}
+	if _, ok := backend.(VersionedBackend); ok {
+		set = set.With(CapabilityVersioning)
+	}
+	if _, ok := backend.(WatchBackend); ok {
+		set = set.With(CapabilityWatch)
+	}
+	return set
+}
+
+// Supports determines whether or not a backend has capability
+func Supports(backend Backend, capability Capability) bool {
+	return CapabilitiesOf(backend).Has(capability)
+}
+
+// StatObject retrieves the information of an object, falling back to getting the whole object
+// if the backend does not have CapabilityStat
+func StatObject(backend Backend, path string) (ObjectInfo, error) {
+	if sb, ok := backend.(StatBackend); ok && Supports(backend, CapabilityStat) {
+		return sb.StatObject(path)
+	}
+	object, err := backend.GetObject(path)
+	if err != nil {
+		return ObjectInfo{Path: path}, err
+	}
+	return object.Info(), nil
+}
+
+// GetObjectRange reads part of an object, falling back to getting the whole object and slicing
+// its content if the backend does not have CapabilityRange
+func GetObjectRange(backend Backend, path string, offset int64, length int64) ([]byte, error) {
+	if rb, ok := backend.(RangeBackend); ok && Supports(backend, CapabilityRange) {
+		return rb.GetObjectRange(path, offset, length)
+	}
+	if err := validateRangeOffset(offset); err != nil {
+		return nil, err
+	}
+	object, err := backend.GetObject(path)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(object.Content, offset, length)
+}
+
+// CopyObject copies an object within the backend, falling back to getting the object and putting
+// its content, along with its content type and user metadata where the backend stores them, if the
+// backend does not have CapabilityCopy
+func CopyObject(backend Backend, src string, dst string) error {
+	if cb, ok := backend.(CopyBackend); ok && Supports(backend, CapabilityCopy) {
+		return cb.CopyObject(src, dst)
+	}
+	object, err := backend.GetObject(src)
+	if err != nil {
+		return err
+	}
+	if mb, ok := backend.(MetadataBackend); ok && Supports(backend, CapabilityMetadata) {
+		return mb.PutObjectWithOptions(dst, object.Content, PutObjectOptions{
+			ContentType:  object.ContentType,
+			StorageClass: object.StorageClass,
+			UserMetadata: object.UserMetadata,
+		})
+	}
+	return backend.PutObject(dst, object.Content)
+}
+
+// MoveObject moves an object within the backend, falling back to copying it with CopyObject and
+// deleting it if the backend does not have CapabilityCopy
+func MoveObject(backend Backend, src string, dst string) error {
+	if cb, ok := backend.(CopyBackend); ok && Supports(backend, CapabilityCopy) {
+		return cb.MoveObject(src, dst)
+	}
+	return moveObject(src, dst, func(src string, dst string) error {
+		return CopyObject(backend, src, dst)
+	}, backend.DeleteObject)
+}
+
+// DeleteObjects removes many objects, falling back to deleting them one at a time, in parallel,
+// if the backend does not have CapabilityBatchDelete
+func DeleteObjects(backend Backend, paths []string) ([]DeleteResult, error) {
+	if bb, ok := backend.(BatchDeleteBackend); ok && Supports(backend, CapabilityBatchDelete) {
+		return bb.DeleteObjects(paths)
+	}
+	return deleteObjectsInParallel(paths, backend.DeleteObject), nil
+}
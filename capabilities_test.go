@@ -0,0 +1,118 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// watchingBackend notifies no change of the objects of the backend it wraps
+type watchingBackend struct {
+	Backend
+}
+
+func (b watchingBackend) Watch(ctx context.Context, prefix string) <-chan WatchEvent {
+	events := make(chan WatchEvent)
+	close(events)
+	return events
+}
+
+type CapabilitiesTestSuite struct {
+	suite.Suite
+	PlainBackend  Backend
+	TempDirectory string
+}
+
+func (suite *CapabilitiesTestSuite) SetupSuite() {
+	timestamp := time.Now().Format("20060102150405")
+	suite.TempDirectory = fmt.Sprintf("../../.test/storage-capabilities/%s", timestamp)
+	suite.PlainBackend = plainBackend{NewLocalFilesystemBackend(suite.TempDirectory)}
+}
+
+func (suite *CapabilitiesTestSuite) TearDownSuite() {
+	os.RemoveAll(suite.TempDirectory)
+}
+
+func (suite *CapabilitiesTestSuite) TestCapabilitySet() {
+	set := NewCapabilitySet(CapabilityCopy, CapabilityContext)
+	suite.True(set.Has(CapabilityCopy), "set has its capabilities")
+	suite.False(set.Has(CapabilityPresign), "set does not have other capabilities")
+	suite.Equal([]Capability{CapabilityContext, CapabilityCopy}, set.List(), "capabilities are listed in declaration order")
+	suite.Equal("context,copy", set.String())
+
+	set = set.With(CapabilityListContent).Without(CapabilityContext)
+	suite.Equal("copy,list-content", set.String())
+	suite.Equal("", CapabilitySet(0).String(), "empty set has no capabilities")
+}
+
+func (suite *CapabilitiesTestSuite) TestCapabilitiesOf() {
+	local := NewLocalFilesystemBackend(suite.TempDirectory)
+	suite.Equal(local.Capabilities(), CapabilitiesOf(local), "declared capabilities are returned")
+	suite.True(Supports(local, CapabilityCopy), "local filesystem supports copies")
+	suite.False(Supports(local, CapabilityPresign), "local filesystem does not support presigning")
+	suite.Equal(CapabilitySet(0), CapabilitiesOf(suite.PlainBackend), "plain backend has no capabilities")
+	suite.Equal(NewCapabilitySet(CapabilityContext), CapabilitiesOf(NewContextBackend(suite.PlainBackend)),
+		"capabilities are detected from the implemented interfaces")
+	suite.Equal("watch", CapabilitiesOf(watchingBackend{suite.PlainBackend}).String(), "watching is detected")
+}
+
+func (suite *CapabilitiesTestSuite) TestFallbacks() {
+	backend := suite.PlainBackend
+	src, dst := "mychart-0.1.0.tgz", "mychart-0.1.1.tgz"
+	content := []byte("mychart")
+	err := backend.PutObject(src, content)
+	suite.Nil(err, "no error putting object")
+
+	info, err := StatObject(backend, src)
+	suite.Nil(err, "no error getting object information without StatBackend")
+	suite.Equal(int64(len(content)), info.Size)
+
+	part, err := GetObjectRange(backend, src, 4, -1)
+	suite.Nil(err, "no error reading range without RangeBackend")
+	suite.Equal([]byte("art"), part)
+	_, err = GetObjectRange(backend, src, -1, 1)
+	suite.NotNil(err, "cannot read range at negative offset")
+
+	err = CopyObject(backend, src, dst)
+	suite.Nil(err, "no error copying object without CopyBackend")
+	err = MoveObject(backend, dst, dst)
+	suite.Nil(err, "moving object onto itself leaves it unchanged")
+	err = MoveObject(backend, dst, "moved.tgz")
+	suite.Nil(err, "no error moving object without CopyBackend")
+	_, err = backend.GetObject(dst)
+	suite.True(errors.Is(err, ErrObjectNotFound), "moved object is not found")
+	err = CopyObject(backend, dst, src)
+	suite.True(errors.Is(err, ErrObjectNotFound), "cannot copy missing object")
+
+	results, err := DeleteObjects(backend, []string{src, "moved.tgz"})
+	suite.Nil(err, "no error deleting objects without BatchDeleteBackend")
+	suite.Len(results, 2)
+	for _, result := range results {
+		suite.Nil(result.Err, "object %s is deleted", result.Path)
+	}
+}
+
+func TestCapabilitiesTestSuite(t *testing.T) {
+	suite.Run(t, new(CapabilitiesTestSuite))
+}
@@ -0,0 +1,340 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultCircuitBreakerPolicy is the policy of a CircuitBreakerBackend, unless configured otherwise
+var DefaultCircuitBreakerPolicy = CircuitBreakerPolicy{
+	FailureRate:      0.5,
+	MinRequests:      10,
+	Window:           time.Minute,
+	CoolDown:         30 * time.Second,
+	HalfOpenRequests: 1,
+}
+
+// States of the circuits of a CircuitBreakerBackend
+const (
+	// CircuitClosed lets the operations through, measuring their failure rate
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails the operations fast, without calling the backend, until the cool-down elapses
+	CircuitOpen
+	// CircuitHalfOpen lets a few trial operations through, closing the circuit if they succeed
+	CircuitHalfOpen
+)
+
+type (
+	// CircuitState is the state of a circuit of a CircuitBreakerBackend
+	CircuitState int
+
+	// CircuitBreakerPolicy describes when the circuit of an operation opens, and when it closes again
+	CircuitBreakerPolicy struct {
+		// FailureRate is the ratio of failed operations, between 0 and 1, which opens the circuit
+		FailureRate float64
+		// MinRequests is the number of operations within a window below which the circuit stays closed
+		MinRequests int
+		// Window is the period over which the failure rate is measured; the counts start over every Window
+		Window time.Duration
+		// CoolDown is how long an open circuit fails operations before letting trial operations through
+		CoolDown time.Duration
+		// HalfOpenRequests is the number of trial operations which must succeed to close the circuit
+		HalfOpenRequests int
+	}
+
+	// CircuitBreakerOptions configures a CircuitBreakerBackend
+	CircuitBreakerOptions struct {
+		// Policy is the policy of the operations without their own policy.
+		// DefaultCircuitBreakerPolicy is used if Policy is the zero value.
+		Policy CircuitBreakerPolicy
+		// Policies overrides Policy for some operations, keyed by the name of the Backend method,
+		// such as "GetObject"
+		Policies map[string]CircuitBreakerPolicy
+		// IsFailure determines whether or not an error counts as a failure of the backend. IsRetryable is
+		// used if nil, so that missing objects or denied permissions do not open the circuit.
+		IsFailure func(err error) bool
+		// OnStateChange is called after the circuit of an operation changed state, if not nil
+		OnStateChange func(change CircuitStateChange)
+	}
+
+	// CircuitStateChange describes a transition of the circuit of an operation of a CircuitBreakerBackend
+	CircuitStateChange struct {
+		Op   string
+		From CircuitState
+		To   CircuitState
+		// Err is the error of the operation which caused the transition, if any
+		Err error
+	}
+
+	// CircuitBreakerBackend is a Backend which stops calling another Backend while it is failing. Each
+	// operation has its own circuit: once the failure rate of an operation reaches the threshold of its
+	// policy, its circuit opens and the operation fails fast with an error matching ErrCircuitOpen, until
+	// the cool-down elapses. Trial operations are then let through, which close the circuit if they succeed
+	// and open it again otherwise.
+	CircuitBreakerBackend struct {
+		backend        Backend
+		contextBackend ContextBackend
+		name           string
+		options        CircuitBreakerOptions
+		circuits       map[string]*circuit
+	}
+
+	// circuit is the state machine of the circuit of one operation
+	circuit struct {
+		mu     sync.Mutex
+		op     string
+		policy CircuitBreakerPolicy
+		state  CircuitState
+		// requests and failures count the completed operations of the current window, while closed
+		requests, failures int
+		windowStart        time.Time
+		openedAt           time.Time
+		// trials and successes count the trial operations in progress and succeeded, while half-open
+		trials, successes int
+	}
+)
+
+// circuitOps lists the operations which have a circuit
+var circuitOps = []string{"ListObjects", "GetObject", "PutObject", "DeleteObject"}
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// NewCircuitBreakerBackend creates a new instance of CircuitBreakerBackend, all of its circuits closed
+func NewCircuitBreakerBackend(backend Backend, options CircuitBreakerOptions) *CircuitBreakerBackend {
+	if options.Policy == (CircuitBreakerPolicy{}) {
+		options.Policy = DefaultCircuitBreakerPolicy
+	}
+	if options.IsFailure == nil {
+		options.IsFailure = IsRetryable
+	}
+	circuits := make(map[string]*circuit, len(circuitOps))
+	now := time.Now()
+	for _, op := range circuitOps {
+		policy, ok := options.Policies[op]
+		if !ok {
+			policy = options.Policy
+		}
+		circuits[op] = &circuit{op: op, policy: policy.withDefaults(), windowStart: now}
+	}
+	return &CircuitBreakerBackend{
+		backend:        backend,
+		contextBackend: NewContextBackend(backend),
+		name:           backendName(backend),
+		options:        options,
+		circuits:       circuits,
+	}
+}
+
+// withDefaults returns the policy, with the fields which are not set taken from DefaultCircuitBreakerPolicy
+func (p CircuitBreakerPolicy) withDefaults() CircuitBreakerPolicy {
+	if p.FailureRate <= 0 || p.FailureRate > 1 {
+		p.FailureRate = DefaultCircuitBreakerPolicy.FailureRate
+	}
+	if p.MinRequests <= 0 {
+		p.MinRequests = DefaultCircuitBreakerPolicy.MinRequests
+	}
+	if p.Window <= 0 {
+		p.Window = DefaultCircuitBreakerPolicy.Window
+	}
+	if p.CoolDown <= 0 {
+		p.CoolDown = DefaultCircuitBreakerPolicy.CoolDown
+	}
+	if p.HalfOpenRequests <= 0 {
+		p.HalfOpenRequests = DefaultCircuitBreakerPolicy.HalfOpenRequests
+	}
+	return p
+}
+
+// Capabilities returns the optional features supported by the CircuitBreakerBackend, which accepts contexts
+// and lists content if the protected backend does
+func (c *CircuitBreakerBackend) Capabilities() CapabilitySet {
+	return CapabilitiesOf(c.backend)&NewCapabilitySet(CapabilityListContent) | NewCapabilitySet(CapabilityContext)
+}
+
+// State returns the current state of the circuit of op, the name of a Backend method such as "GetObject"
+func (c *CircuitBreakerBackend) State(op string) CircuitState {
+	circuit, ok := c.circuits[op]
+	if !ok {
+		return CircuitClosed
+	}
+	circuit.mu.Lock()
+	defer circuit.mu.Unlock()
+	if circuit.state == CircuitOpen && time.Since(circuit.openedAt) >= circuit.policy.CoolDown {
+		return CircuitHalfOpen
+	}
+	return circuit.state
+}
+
+// ListObjects lists all objects at prefix, unless the circuit of ListObjects is open
+func (c *CircuitBreakerBackend) ListObjects(prefix string) ([]Object, error) {
+	return c.ListObjectsContext(context.Background(), prefix)
+}
+
+// GetObject retrieves an object at path, unless the circuit of GetObject is open
+func (c *CircuitBreakerBackend) GetObject(path string) (Object, error) {
+	return c.GetObjectContext(context.Background(), path)
+}
+
+// PutObject uploads an object to path, unless the circuit of PutObject is open
+func (c *CircuitBreakerBackend) PutObject(path string, content []byte) error {
+	return c.PutObjectContext(context.Background(), path, content)
+}
+
+// DeleteObject removes an object at path, unless the circuit of DeleteObject is open
+func (c *CircuitBreakerBackend) DeleteObject(path string) error {
+	return c.DeleteObjectContext(context.Background(), path)
+}
+
+// ListObjectsContext lists all objects at prefix, unless the circuit of ListObjects is open
+func (c *CircuitBreakerBackend) ListObjectsContext(ctx context.Context, prefix string) (objects []Object, err error) {
+	err = c.call(ctx, "ListObjects", prefix, func() error {
+		objects, err = c.contextBackend.ListObjectsContext(ctx, prefix)
+		return err
+	})
+	return objects, err
+}
+
+// GetObjectContext retrieves an object at path, unless the circuit of GetObject is open
+func (c *CircuitBreakerBackend) GetObjectContext(ctx context.Context, path string) (object Object, err error) {
+	object.Path = path
+	err = c.call(ctx, "GetObject", path, func() error {
+		object, err = c.contextBackend.GetObjectContext(ctx, path)
+		return err
+	})
+	return object, err
+}
+
+// PutObjectContext uploads an object to path, unless the circuit of PutObject is open
+func (c *CircuitBreakerBackend) PutObjectContext(ctx context.Context, path string, content []byte) error {
+	return c.call(ctx, "PutObject", path, func() error {
+		return c.contextBackend.PutObjectContext(ctx, path, content)
+	})
+}
+
+// DeleteObjectContext removes an object at path, unless the circuit of DeleteObject is open
+func (c *CircuitBreakerBackend) DeleteObjectContext(ctx context.Context, path string) error {
+	return c.call(ctx, "DeleteObject", path, func() error {
+		return c.contextBackend.DeleteObjectContext(ctx, path)
+	})
+}
+
+// call makes an operation if its circuit allows it, and records its outcome. An operation rejected by an
+// open circuit fails with a StorageError of kind ErrUnavailable wrapping ErrCircuitOpen.
+func (c *CircuitBreakerBackend) call(ctx context.Context, op string, path string, operation func() error) error {
+	circuit := c.circuits[op]
+	allowed, change := circuit.allow(time.Now())
+	c.notify(change)
+	if !allowed {
+		return &StorageError{Backend: c.name, Op: op, Path: path, Kind: ErrUnavailable, Err: ErrCircuitOpen}
+	}
+	err := operation()
+	// operations canceled by their caller tell nothing about the health of the backend
+	ignored := err != nil && ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+	failed := err != nil && !ignored && c.options.IsFailure(err)
+	c.notify(circuit.record(time.Now(), failed, ignored, err))
+	return err
+}
+
+// notify calls OnStateChange with change, if the circuit changed state
+func (c *CircuitBreakerBackend) notify(change *CircuitStateChange) {
+	if change != nil && c.options.OnStateChange != nil {
+		c.options.OnStateChange(*change)
+	}
+}
+
+// allow determines whether or not an operation can be made at now, moving an open circuit whose cool-down
+// elapsed to half-open
+func (c *circuit) allow(now time.Time) (bool, *CircuitStateChange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var change *CircuitStateChange
+	switch c.state {
+	case CircuitClosed:
+		if now.Sub(c.windowStart) >= c.policy.Window {
+			c.requests, c.failures, c.windowStart = 0, 0, now
+		}
+		return true, nil
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < c.policy.CoolDown {
+			return false, nil
+		}
+		change = c.transition(CircuitHalfOpen, now, nil)
+	}
+	if c.trials+c.successes >= c.policy.HalfOpenRequests {
+		return false, change
+	}
+	c.trials++
+	return true, change
+}
+
+// record records the outcome of an operation allowed by the circuit, which completed at now with err
+func (c *circuit) record(now time.Time, failed bool, ignored bool, err error) *CircuitStateChange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch c.state {
+	case CircuitClosed:
+		if ignored {
+			return nil
+		}
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if c.requests >= c.policy.MinRequests && float64(c.failures) >= c.policy.FailureRate*float64(c.requests) {
+			return c.transition(CircuitOpen, now, err)
+		}
+	case CircuitHalfOpen:
+		c.trials = max(c.trials-1, 0)
+		switch {
+		case failed:
+			return c.transition(CircuitOpen, now, err)
+		case !ignored:
+			c.successes++
+			if c.successes >= c.policy.HalfOpenRequests {
+				return c.transition(CircuitClosed, now, nil)
+			}
+		}
+	}
+	// operations allowed before the circuit opened do not change it
+	return nil
+}
+
+// transition moves the circuit to state at now, resetting its counts
+func (c *circuit) transition(state CircuitState, now time.Time, err error) *CircuitStateChange {
+	change := &CircuitStateChange{Op: c.op, From: c.state, To: state, Err: err}
+	c.state = state
+	c.requests, c.failures, c.windowStart = 0, 0, now
+	c.trials, c.successes = 0, 0
+	if state == CircuitOpen {
+		c.openedAt = now
+	}
+	return change
+}
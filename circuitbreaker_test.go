@@ -0,0 +1,144 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var circuitBreakerTestPolicy = CircuitBreakerPolicy{FailureRate: 0.5, MinRequests: 4, CoolDown: 20 * time.Millisecond}
+
+type CircuitBreakerTestSuite struct {
+	suite.Suite
+	TempDirectory string
+	Local         *LocalFilesystemBackend
+
+	mu      sync.Mutex
+	changes []string
+}
+
+func (suite *CircuitBreakerTestSuite) SetupTest() {
+	timestamp := time.Now().Format("20060102150405.000000")
+	suite.TempDirectory = fmt.Sprintf("../../.test/storage-circuitbreaker/%s", timestamp)
+	suite.Local = NewLocalFilesystemBackend(suite.TempDirectory)
+	suite.Nil(suite.Local.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+	suite.changes = nil
+}
+
+func (suite *CircuitBreakerTestSuite) TearDownTest() {
+	os.RemoveAll(suite.TempDirectory)
+}
+
+// onStateChange records the transitions of the circuits
+func (suite *CircuitBreakerTestSuite) onStateChange(change CircuitStateChange) {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	suite.changes = append(suite.changes, fmt.Sprintf("%s %s->%s", change.Op, change.From, change.To))
+}
+
+func (suite *CircuitBreakerTestSuite) TestCircuitBreaker() {
+	flaky := &flakyBackend{countingBackend: newCountingBackend(suite.Local), failures: 4, err: ErrUnavailable}
+	breaker := NewCircuitBreakerBackend(flaky, CircuitBreakerOptions{
+		Policy:        circuitBreakerTestPolicy,
+		OnStateChange: suite.onStateChange,
+	})
+
+	for i := 0; i < 4; i++ {
+		_, err := breaker.GetObject("mychart-0.1.0.tgz")
+		suite.True(errors.Is(err, ErrUnavailable), "failure of the backend is returned")
+	}
+	suite.Equal(CircuitOpen, breaker.State("GetObject"), "circuit opens at the failure rate")
+
+	_, err := breaker.GetObject("mychart-0.1.0.tgz")
+	suite.True(errors.Is(err, ErrCircuitOpen), "open circuit fails fast")
+	suite.True(errors.Is(err, ErrUnavailable), "open circuit fails as unavailable")
+	suite.False(IsRetryable(err), "operation failed fast is not retryable")
+	suite.Equal(4, flaky.Calls("GetObject"), "open circuit does not call the backend")
+
+	_, err = breaker.ListObjects("")
+	suite.False(errors.Is(err, ErrCircuitOpen), "other operations have their own circuit")
+	suite.Equal(1, flaky.Calls("ListObjects"))
+
+	time.Sleep(2 * circuitBreakerTestPolicy.CoolDown)
+	suite.Equal(CircuitHalfOpen, breaker.State("GetObject"), "circuit is half-open after the cool-down")
+	object, err := breaker.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err, "trial operation is let through")
+	suite.Equal([]byte("mychart"), object.Content)
+	suite.Equal(CircuitClosed, breaker.State("GetObject"), "successful trial closes the circuit")
+
+	suite.Equal([]string{
+		"GetObject closed->open",
+		"GetObject open->half-open",
+		"GetObject half-open->closed",
+	}, suite.changes, "state transitions are reported")
+}
+
+func (suite *CircuitBreakerTestSuite) TestFailedTrial() {
+	flaky := &flakyBackend{countingBackend: newCountingBackend(suite.Local), failures: 5, err: ErrThrottled}
+	breaker := NewCircuitBreakerBackend(flaky, CircuitBreakerOptions{
+		Policies:      map[string]CircuitBreakerPolicy{"PutObject": circuitBreakerTestPolicy},
+		OnStateChange: suite.onStateChange,
+	})
+
+	for i := 0; i < 4; i++ {
+		suite.NotNil(breaker.PutObject("mychart-0.1.1.tgz", []byte("mychart")))
+	}
+	time.Sleep(2 * circuitBreakerTestPolicy.CoolDown)
+	suite.True(errors.Is(breaker.PutObject("mychart-0.1.1.tgz", []byte("mychart")), ErrThrottled), "trial operation is let through")
+	suite.Equal(CircuitOpen, breaker.State("PutObject"), "failed trial opens the circuit again")
+	suite.True(errors.Is(breaker.PutObject("mychart-0.1.1.tgz", []byte("mychart")), ErrCircuitOpen))
+
+	suite.Equal([]string{
+		"PutObject closed->open",
+		"PutObject open->half-open",
+		"PutObject half-open->open",
+	}, suite.changes)
+	suite.Equal(CircuitClosed, breaker.State("DeleteObject"))
+}
+
+func (suite *CircuitBreakerTestSuite) TestFailures() {
+	breaker := NewCircuitBreakerBackend(suite.Local, CircuitBreakerOptions{Policy: circuitBreakerTestPolicy})
+	for i := 0; i < 8; i++ {
+		_, err := breaker.GetObject("missing.tgz")
+		suite.True(errors.Is(err, ErrObjectNotFound))
+	}
+	suite.Equal(CircuitClosed, breaker.State("GetObject"), "missing objects are not failures of the backend")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	flaky := &flakyBackend{countingBackend: newCountingBackend(suite.Local), failures: 8, err: context.Canceled}
+	breaker = NewCircuitBreakerBackend(flaky, CircuitBreakerOptions{
+		Policy:    circuitBreakerTestPolicy,
+		IsFailure: func(err error) bool { return true },
+	})
+	for i := 0; i < 8; i++ {
+		suite.NotNil(breaker.DeleteObjectContext(ctx, "mychart-0.1.0.tgz"))
+	}
+	suite.Equal(CircuitClosed, breaker.State("DeleteObject"), "operations canceled by their caller are not failures")
+}
+
+func TestCircuitBreakerTestSuite(t *testing.T) {
+	suite.Run(t, new(CircuitBreakerTestSuite))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+)
+
+// contextAdapter turns a plain Backend into a ContextBackend
+type contextAdapter struct {
+	Backend
+}
+
+// NewContextBackend returns a ContextBackend for the given backend. Backends which already
+// implement ContextBackend are returned as-is; any other backend is wrapped so that the
+// context is checked before each call is delegated to it.
+func NewContextBackend(backend Backend) ContextBackend {
+	if cb, ok := backend.(ContextBackend); ok {
+		return cb
+	}
+	return contextAdapter{Backend: backend}
+}
+
+// ListObjectsContext lists all objects at prefix, unless ctx is already done
+func (a contextAdapter) ListObjectsContext(ctx context.Context, prefix string) ([]Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.Backend.ListObjects(prefix)
+}
+
+// GetObjectContext retrieves an object at path, unless ctx is already done
+func (a contextAdapter) GetObjectContext(ctx context.Context, path string) (Object, error) {
+	if err := ctx.Err(); err != nil {
+		return Object{Path: path}, err
+	}
+	return a.Backend.GetObject(path)
+}
+
+// PutObjectContext uploads an object to path, unless ctx is already done
+func (a contextAdapter) PutObjectContext(ctx context.Context, path string, content []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.Backend.PutObject(path, content)
+}
+
+// DeleteObjectContext removes an object at path, unless ctx is already done
+func (a contextAdapter) DeleteObjectContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.Backend.DeleteObject(path)
+}
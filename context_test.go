@@ -0,0 +1,77 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// plainBackend hides every method but those of Backend
+type plainBackend struct {
+	Backend
+}
+
+type ContextTestSuite struct {
+	suite.Suite
+	ContextBackend ContextBackend
+	TempDirectory  string
+}
+
+func (suite *ContextTestSuite) SetupSuite() {
+	timestamp := time.Now().Format("20060102150405")
+	suite.TempDirectory = fmt.Sprintf("../../.test/storage-context/%s", timestamp)
+	suite.ContextBackend = NewContextBackend(plainBackend{NewLocalFilesystemBackend(suite.TempDirectory)})
+}
+
+func (suite *ContextTestSuite) TearDownSuite() {
+	os.RemoveAll(suite.TempDirectory)
+}
+
+func (suite *ContextTestSuite) TestAdapter() {
+	_, ok := suite.ContextBackend.(contextAdapter)
+	suite.True(ok, "plain backend is wrapped in an adapter")
+
+	ctx := context.Background()
+	err := suite.ContextBackend.PutObjectContext(ctx, "test.txt", []byte("test content"))
+	suite.Nil(err, "can put object with context")
+	objects, err := suite.ContextBackend.ListObjectsContext(ctx, "")
+	suite.Nil(err, "can list objects with context")
+	suite.Len(objects, 1, "one object listed")
+	object, err := suite.ContextBackend.GetObjectContext(ctx, "test.txt")
+	suite.Nil(err, "can get object with context")
+	suite.Equal([]byte("test content"), object.Content, "object content as expected")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = suite.ContextBackend.GetObjectContext(cancelled, "test.txt")
+	suite.Equal(context.Canceled, err, "cannot get object with cancelled context")
+	err = suite.ContextBackend.DeleteObjectContext(cancelled, "test.txt")
+	suite.Equal(context.Canceled, err, "cannot delete object with cancelled context")
+
+	err = suite.ContextBackend.DeleteObjectContext(ctx, "test.txt")
+	suite.Nil(err, "can delete object with context")
+}
+
+func TestContextTestSuite(t *testing.T) {
+	suite.Run(t, new(ContextTestSuite))
+}
@@ -0,0 +1,479 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	pathutil "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDiskCacheMaxBytes is the size of the content cached by a DiskCacheBackend, unless configured otherwise
+	DefaultDiskCacheMaxBytes = 1 << 30
+
+	// DefaultDiskCacheMaxAge is how long a DiskCacheBackend serves a cached object without revalidating it,
+	// unless configured otherwise
+	DefaultDiskCacheMaxAge = time.Minute
+
+	// diskCacheIndexSuffix names the index file of a cached object, written next to its content
+	diskCacheIndexSuffix = ".json"
+)
+
+type (
+	// DiskCacheOptions configures a DiskCacheBackend
+	DiskCacheOptions struct {
+		// Directory holds the cached objects. It is created if it does not exist.
+		Directory string
+		// MaxBytes bounds the total size of the cached content; least recently used objects are evicted
+		// beyond it, and objects larger than MaxBytes are not cached. DefaultDiskCacheMaxBytes is used
+		// if MaxBytes is not positive.
+		MaxBytes int64
+		// MaxAge is how long a cached object is served after it was last validated. Older objects are
+		// revalidated by getting their information from the remote backend, and comparing their size and
+		// ETag, or LastModified; they are got again from remote backends without CapabilityStat.
+		// DefaultDiskCacheMaxAge is used if MaxAge is not positive.
+		MaxAge time.Duration
+	}
+
+	// DiskCacheBackend is a Backend which caches the objects of a remote Backend in a local directory,
+	// so that the cache survives restarts. Objects are read through the cache, and revalidated using the
+	// LastModified, and ETag where known, returned by StatObject, or by ListObjects: listing a prefix
+	// through the DiskCacheBackend also revalidates the cached objects below it. Objects put or deleted
+	// through the DiskCacheBackend are invalidated.
+	//
+	// Each cached object is stored as a content file and an index file, both written atomically, the
+	// index file last. On startup, the index is rebuilt from the index files, and the files left over by
+	// interrupted writes or evictions are removed.
+	DiskCacheBackend struct {
+		backend Backend
+		options DiskCacheOptions
+
+		mu      sync.Mutex
+		entries map[string]*diskCacheEntry
+		bytes   int64
+		// generation counts invalidations, so that objects fetched before an invalidation are not cached
+		generation uint64
+	}
+
+	// diskCacheEntry is the index file of a cached object
+	diskCacheEntry struct {
+		Path         string            `json:"path"`
+		Size         int64             `json:"size"`
+		LastModified time.Time         `json:"lastModified"`
+		ETag         string            `json:"etag,omitempty"`
+		ContentType  string            `json:"contentType,omitempty"`
+		StorageClass string            `json:"storageClass,omitempty"`
+		UserMetadata map[string]string `json:"userMetadata,omitempty"`
+
+		lastAccess  time.Time
+		validatedAt time.Time
+	}
+)
+
+// NewDiskCacheBackend creates a new instance of DiskCacheBackend caching the objects of backend in
+// options.Directory, and recovers the objects cached there by a previous instance
+func NewDiskCacheBackend(backend Backend, options DiskCacheOptions) (*DiskCacheBackend, error) {
+	if options.Directory == "" {
+		return nil, configError("diskcache", "missing directory")
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DefaultDiskCacheMaxBytes
+	}
+	if options.MaxAge <= 0 {
+		options.MaxAge = DefaultDiskCacheMaxAge
+	}
+	directory, err := filepath.Abs(options.Directory)
+	if err != nil {
+		return nil, configError("diskcache", "invalid directory %q: %s", options.Directory, err)
+	}
+	options.Directory = directory
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, configError("diskcache", "cannot create directory %q: %s", directory, err)
+	}
+	c := &DiskCacheBackend{
+		backend: backend,
+		options: options,
+		entries: make(map[string]*diskCacheEntry),
+	}
+	if err := c.recover(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Capabilities returns the optional features supported by the DiskCacheBackend, which lists content
+// if the remote backend does
+func (c *DiskCacheBackend) Capabilities() CapabilitySet {
+	return CapabilitiesOf(c.backend) & NewCapabilitySet(CapabilityListContent)
+}
+
+// Size returns the number of cached objects and their total size
+func (c *DiskCacheBackend) Size() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries), c.bytes
+}
+
+// ListObjects lists the objects at prefix in the remote backend, and revalidates the cached objects
+// below prefix against the listing
+func (c *DiskCacheBackend) ListObjects(prefix string) ([]Object, error) {
+	objects, err := c.backend.ListObjects(prefix)
+	if err != nil {
+		return objects, err
+	}
+	c.revalidate(prefix, objects)
+	return objects, nil
+}
+
+// GetObject retrieves an object from the cache directory, or from the remote backend if it is not cached
+// or has changed since it was cached
+func (c *DiskCacheBackend) GetObject(path string) (Object, error) {
+	key := cacheKey(path)
+	if entry, ok := c.entry(key); ok {
+		if time.Since(entry.validatedAt) >= c.options.MaxAge {
+			// without a native StatObject, revalidating the object would get it anyway:
+			// it is got once, replacing the cached object
+			ok = false
+			if statBackend, canStat := c.backend.(StatBackend); canStat && Supports(c.backend, CapabilityStat) {
+				info, err := statBackend.StatObject(path)
+				if err != nil {
+					if errors.Is(err, ErrObjectNotFound) {
+						c.evict(key)
+					}
+					return Object{Path: path}, err
+				}
+				ok = sameVersion(entry.info(path), info)
+				if ok {
+					c.validated(key, entry)
+				} else {
+					c.evict(key)
+				}
+			}
+		}
+		if ok {
+			if content, err := os.ReadFile(c.contentPath(key)); err == nil && int64(len(content)) == entry.Size {
+				c.touch(key)
+				return objectWithInfo(entry.info(path), content), nil
+			}
+			c.evict(key)
+		}
+	}
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+	object, err := c.backend.GetObject(path)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			c.evict(key)
+		}
+		return object, err
+	}
+	c.add(key, object, generation)
+	return object, nil
+}
+
+// PutObject uploads an object to the remote backend, invalidating its cached copy
+func (c *DiskCacheBackend) PutObject(path string, content []byte) error {
+	defer c.invalidate(cacheKey(path))
+	return c.backend.PutObject(path, content)
+}
+
+// DeleteObject removes an object from the remote backend, and from the cache
+func (c *DiskCacheBackend) DeleteObject(path string) error {
+	defer c.invalidate(cacheKey(path))
+	return c.backend.DeleteObject(path)
+}
+
+// invalidate evicts an object changed through the DiskCacheBackend
+func (c *DiskCacheBackend) invalidate(key string) {
+	c.mu.Lock()
+	c.generation++
+	c.mu.Unlock()
+	c.evict(key)
+}
+
+// entry returns a copy of the index entry of a cached object
+func (c *DiskCacheBackend) entry(key string) (diskCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return diskCacheEntry{}, false
+	}
+	return *entry, true
+}
+
+// validated marks a cached object as validated, unless it was replaced since its entry was read
+func (c *DiskCacheBackend) validated(key string, entry diskCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if current, ok := c.entries[key]; ok && current.Size == entry.Size && current.ETag == entry.ETag &&
+		current.LastModified.Equal(entry.LastModified) {
+		current.validatedAt = time.Now()
+	}
+}
+
+// revalidate evicts the cached objects below prefix which are not listed, or listed with another version,
+// and marks the others as validated
+func (c *DiskCacheBackend) revalidate(prefix string, objects []Object) {
+	listed := make(map[string]ObjectInfo, len(objects))
+	for _, object := range objects {
+		listed[cacheKey(pathutil.Join(prefix, object.Path))] = object.Info()
+	}
+	dir := cacheKey(prefix)
+	now := time.Now()
+	var stale []string
+	c.mu.Lock()
+	for key, entry := range c.entries {
+		if pathutil.Dir("/"+key) != pathutil.Clean("/"+dir) {
+			continue
+		}
+		if info, ok := listed[key]; ok && sameListedVersion(entry.info(key), info) {
+			entry.validatedAt = now
+		} else {
+			stale = append(stale, key)
+		}
+	}
+	c.mu.Unlock()
+	for _, key := range stale {
+		c.evict(key)
+	}
+}
+
+// add writes an object fetched at generation to the cache directory, evicting the least recently used objects
+// to stay within MaxBytes. The content file is written before the index file, so that an interrupted write
+// leaves no index file behind.
+func (c *DiskCacheBackend) add(key string, object Object, generation uint64) {
+	size := int64(len(object.Content))
+	c.evict(key)
+	if size > c.options.MaxBytes {
+		return
+	}
+	entry := &diskCacheEntry{
+		Path:         key,
+		Size:         size,
+		LastModified: object.LastModified,
+		ETag:         object.ETag,
+		ContentType:  object.ContentType,
+		StorageClass: object.StorageClass,
+		UserMetadata: object.UserMetadata,
+	}
+	index, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomically(c.contentPath(key), object.Content); err != nil {
+		return
+	}
+	if err := writeFileAtomically(c.indexPath(key), index); err != nil {
+		os.Remove(c.contentPath(key))
+		return
+	}
+
+	now := time.Now()
+	entry.lastAccess, entry.validatedAt = now, now
+	c.mu.Lock()
+	if generation != c.generation {
+		c.mu.Unlock()
+		c.evict(key)
+		return
+	}
+	if previous, ok := c.entries[key]; ok {
+		c.bytes -= previous.Size
+	}
+	c.entries[key] = entry
+	c.bytes += size
+	evicted := c.leastRecentlyUsed(key)
+	c.mu.Unlock()
+	for _, key := range evicted {
+		c.evict(key)
+	}
+}
+
+// leastRecentlyUsed returns the keys of the least recently used objects, other than key, to evict so that
+// the cached content stays within MaxBytes; the lock must be held
+func (c *DiskCacheBackend) leastRecentlyUsed(key string) []string {
+	excess := c.bytes - c.options.MaxBytes
+	if excess <= 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(c.entries))
+	for k := range c.entries {
+		if k != key {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].lastAccess.Before(c.entries[keys[j]].lastAccess)
+	})
+	var evicted []string
+	for _, k := range keys {
+		if excess <= 0 {
+			break
+		}
+		excess -= c.entries[k].Size
+		evicted = append(evicted, k)
+	}
+	return evicted
+}
+
+// touch records an access to a cached object, in memory and as the modification time of its content
+// file, so that the least recently used objects are still known after a restart
+func (c *DiskCacheBackend) touch(key string) {
+	now := time.Now()
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok {
+		entry.lastAccess = now
+	}
+	c.mu.Unlock()
+	os.Chtimes(c.contentPath(key), now, now)
+}
+
+// evict removes a cached object. The index file is removed before the content file, so that an
+// interrupted eviction leaves no index file without content behind.
+func (c *DiskCacheBackend) evict(key string) {
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok {
+		c.bytes -= entry.Size
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	os.Remove(c.indexPath(key))
+	os.Remove(c.contentPath(key))
+}
+
+// recover rebuilds the index from the index files of the cache directory. Index files which cannot be read,
+// or whose content file is missing or truncated, are removed along with the content files without index
+// files and the temporary files left over by interrupted writes. Other files are left untouched.
+func (c *DiskCacheBackend) recover() error {
+	files, err := os.ReadDir(c.options.Directory)
+	if err != nil {
+		return configError("diskcache", "cannot read directory %q: %s", c.options.Directory, err)
+	}
+	indexed := make(map[string]bool)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, diskCacheIndexSuffix) || strings.HasPrefix(name, ".") || !isDiskCacheFile(name) {
+			continue
+		}
+		indexPath := filepath.Join(c.options.Directory, name)
+		contentName := strings.TrimSuffix(name, diskCacheIndexSuffix)
+		var entry diskCacheEntry
+		index, err := os.ReadFile(indexPath)
+		if err == nil {
+			err = json.Unmarshal(index, &entry)
+		}
+		if err == nil && diskCacheName(entry.Path) != contentName {
+			err = errors.New("index file does not match its name")
+		}
+		var stat os.FileInfo
+		if err == nil {
+			stat, err = os.Stat(filepath.Join(c.options.Directory, contentName))
+		}
+		if err != nil || stat.Size() != entry.Size {
+			os.Remove(indexPath)
+			continue
+		}
+		entry.lastAccess = stat.ModTime()
+		c.entries[entry.Path] = &entry
+		c.bytes += entry.Size
+		indexed[contentName] = true
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || strings.HasSuffix(name, diskCacheIndexSuffix) || indexed[name] || !isDiskCacheFile(name) {
+			continue
+		}
+		os.Remove(filepath.Join(c.options.Directory, name))
+	}
+	for _, key := range c.leastRecentlyUsed("") {
+		c.evict(key)
+	}
+	return nil
+}
+
+// contentPath returns the path of the content file of a cached object
+func (c *DiskCacheBackend) contentPath(key string) string {
+	return filepath.Join(c.options.Directory, diskCacheName(key))
+}
+
+// indexPath returns the path of the index file of a cached object
+func (c *DiskCacheBackend) indexPath(key string) string {
+	return c.contentPath(key) + diskCacheIndexSuffix
+}
+
+// info returns the information of a cached object
+func (entry diskCacheEntry) info(path string) ObjectInfo {
+	return ObjectInfo{
+		Path:         path,
+		Size:         entry.Size,
+		LastModified: entry.LastModified,
+		ETag:         entry.ETag,
+		ContentType:  entry.ContentType,
+		StorageClass: entry.StorageClass,
+		UserMetadata: entry.UserMetadata,
+	}
+}
+
+// isDiskCacheFile determines whether or not a file, or a temporary file, is named after the hash of a key
+func isDiskCacheFile(name string) bool {
+	name = strings.TrimPrefix(name, ".")
+	if len(name) < sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(name[:sha256.Size*2])
+	return err == nil
+}
+
+// diskCacheName names the files of a cached object after the hash of its key, as keys may not be valid file names
+func diskCacheName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// sameListedVersion determines whether or not a listed object is the cached version of it. Listings do not
+// always report the same information as GetObject, so only the information reported by both is compared.
+func sameListedVersion(cached ObjectInfo, listed ObjectInfo) bool {
+	if cached.ETag != "" && listed.ETag != "" {
+		return cached.ETag == listed.ETag
+	}
+	return cached.LastModified.Equal(listed.LastModified)
+}
+
+// writeFileAtomically writes content to a temporary file, and renames it to fullpath
+func writeFileAtomically(fullpath string, content []byte) error {
+	tmpPath, err := writeTempFile(fullpath, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, fullpath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
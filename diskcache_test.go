@@ -0,0 +1,256 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DiskCacheTestSuite struct {
+	suite.Suite
+	TempDirectory  string
+	CacheDirectory string
+	Remote         *LocalFilesystemBackend
+	Counting       *countingBackend
+}
+
+func (suite *DiskCacheTestSuite) SetupTest() {
+	timestamp := time.Now().Format("20060102150405.000000")
+	suite.TempDirectory = fmt.Sprintf("../../.test/storage-diskcache/%s", timestamp)
+	suite.CacheDirectory = filepath.Join(suite.TempDirectory, "cache")
+	suite.Remote = NewLocalFilesystemBackend(filepath.Join(suite.TempDirectory, "remote"))
+	suite.Counting = newCountingBackend(suite.Remote)
+}
+
+func (suite *DiskCacheTestSuite) TearDownTest() {
+	os.RemoveAll(suite.TempDirectory)
+}
+
+func (suite *DiskCacheTestSuite) newCache(maxBytes int64, maxAge time.Duration) *DiskCacheBackend {
+	cache, err := NewDiskCacheBackend(countingStatBackend{suite.Counting}, DiskCacheOptions{
+		Directory: suite.CacheDirectory,
+		MaxBytes:  maxBytes,
+		MaxAge:    maxAge,
+	})
+	suite.Nil(err, "no error creating disk cache")
+	return cache
+}
+
+func (suite *DiskCacheTestSuite) TestGetObject() {
+	cache := suite.newCache(0, time.Hour)
+	suite.Nil(suite.Remote.PutObject("stable/mychart-0.1.0.tgz", []byte("mychart")))
+
+	for i := 0; i < 2; i++ {
+		object, err := cache.GetObject("stable/mychart-0.1.0.tgz")
+		suite.Nil(err, "no error getting object through disk cache")
+		suite.Equal([]byte("mychart"), object.Content)
+		suite.Equal("stable/mychart-0.1.0.tgz", object.Path)
+	}
+	suite.Equal(1, suite.Counting.Calls("GetObject"), "object is fetched once")
+	count, size := cache.Size()
+	suite.Equal(1, count)
+	suite.Equal(int64(len("mychart")), size)
+
+	_, err := cache.GetObject("stable/missing.tgz")
+	suite.True(errors.Is(err, ErrObjectNotFound), "missing object is not found through disk cache")
+}
+
+func (suite *DiskCacheTestSuite) TestRestart() {
+	cache := suite.newCache(0, time.Hour)
+	suite.Nil(suite.Remote.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+	_, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+
+	restarted := suite.newCache(0, time.Hour)
+	count, _ := restarted.Size()
+	suite.Equal(1, count, "cached object is recovered after a restart")
+	object, err := restarted.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err, "no error getting recovered object")
+	suite.Equal([]byte("mychart"), object.Content)
+	suite.Equal(1, suite.Counting.Calls("GetObject"), "recovered object is not fetched again")
+	suite.Equal(1, suite.Counting.Calls("StatObject"), "recovered object is revalidated")
+}
+
+func (suite *DiskCacheTestSuite) TestWarmHit() {
+	cache := suite.newCache(0, time.Hour)
+	suite.Nil(suite.Remote.PutObject("stable/mychart-0.1.0.tgz", []byte("mychart")))
+	suite.Nil(suite.Remote.PutObject("stable/mychart-0.2.0.tgz", []byte("mychart")))
+	_, err := cache.GetObject("stable/mychart-0.1.0.tgz")
+	suite.Nil(err)
+
+	for i := 0; i < 3; i++ {
+		_, err = cache.GetObject("stable/mychart-0.1.0.tgz")
+		suite.Nil(err)
+	}
+	suite.Equal(map[string]int{"GetObject": 1}, suite.Counting.calls, "fresh object is served without calling the remote backend")
+
+	expiring := suite.newCache(0, time.Nanosecond)
+	object, err := expiring.GetObject("stable/mychart-0.1.0.tgz")
+	suite.Nil(err)
+	suite.Equal([]byte("mychart"), object.Content)
+	suite.Equal(map[string]int{"GetObject": 1, "StatObject": 1}, suite.Counting.calls,
+		"expired object is revalidated with a single stat, without listing its prefix")
+	suite.Equal(DefaultDiskCacheMaxAge, suite.newCache(0, 0).options.MaxAge, "objects are not revalidated on every hit by default")
+}
+
+func (suite *DiskCacheTestSuite) TestExpiredWithoutStat() {
+	cache, err := NewDiskCacheBackend(suite.Counting, DiskCacheOptions{
+		Directory: suite.CacheDirectory,
+		MaxAge:    time.Nanosecond,
+	})
+	suite.Nil(err)
+	suite.Nil(suite.Remote.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+	_, err = cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+
+	suite.Nil(suite.Remote.PutObject("mychart-0.1.0.tgz", []byte("mychart changed")))
+	object, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+	suite.Equal([]byte("mychart changed"), object.Content, "expired object is got again")
+	suite.Equal(map[string]int{"GetObject": 2}, suite.Counting.calls,
+		"expired object is got once from a remote backend which cannot stat objects")
+	count, size := cache.Size()
+	suite.Equal(1, count, "object got again replaces the cached object")
+	suite.Equal(int64(len("mychart changed")), size)
+}
+
+func (suite *DiskCacheTestSuite) TestRevalidation() {
+	cache := suite.newCache(0, time.Hour)
+	suite.Nil(suite.Remote.PutObject("stable/mychart-0.1.0.tgz", []byte("mychart")))
+	_, err := cache.GetObject("stable/mychart-0.1.0.tgz")
+	suite.Nil(err)
+
+	suite.Nil(suite.Remote.PutObject("stable/mychart-0.1.0.tgz", []byte("mychart changed")))
+	objects, err := cache.ListObjects("stable")
+	suite.Nil(err, "no error listing objects through disk cache")
+	suite.Len(objects, 1)
+	count, _ := cache.Size()
+	suite.Equal(0, count, "object changed in the remote backend is evicted when listed")
+
+	object, err := cache.GetObject("stable/mychart-0.1.0.tgz")
+	suite.Nil(err)
+	suite.Equal([]byte("mychart changed"), object.Content, "changed object is fetched again")
+
+	expiring := suite.newCache(0, time.Nanosecond)
+	suite.Nil(suite.Remote.PutObject("stable/mychart-0.1.0.tgz", []byte("mychart changed again")))
+	object, err = expiring.GetObject("stable/mychart-0.1.0.tgz")
+	suite.Nil(err)
+	suite.Equal([]byte("mychart changed again"), object.Content, "object changed in the remote backend is revalidated")
+
+	suite.Nil(suite.Remote.DeleteObject("stable/mychart-0.1.0.tgz"))
+	_, err = expiring.GetObject("stable/mychart-0.1.0.tgz")
+	suite.True(errors.Is(err, ErrObjectNotFound), "object deleted from the remote backend is revalidated")
+	count, _ = expiring.Size()
+	suite.Equal(0, count, "deleted object is evicted")
+}
+
+func (suite *DiskCacheTestSuite) TestEviction() {
+	cache := suite.newCache(10, time.Hour)
+	for _, path := range []string{"a.tgz", "b.tgz", "c.tgz"} {
+		suite.Nil(suite.Remote.PutObject(path, []byte("four")))
+	}
+	suite.Nil(suite.Remote.PutObject("large.tgz", []byte("larger than the cache")))
+
+	for _, path := range []string{"a.tgz", "b.tgz", "a.tgz", "c.tgz", "large.tgz"} {
+		_, err := cache.GetObject(path)
+		suite.Nil(err)
+		time.Sleep(time.Millisecond)
+	}
+	count, size := cache.Size()
+	suite.Equal(2, count, "least recently used object is evicted")
+	suite.Equal(int64(8), size, "cached content stays within MaxBytes")
+
+	_, err := cache.GetObject("a.tgz")
+	suite.Nil(err)
+	suite.Equal(4, suite.Counting.Calls("GetObject"), "recently used object is still cached")
+	_, err = cache.GetObject("b.tgz")
+	suite.Nil(err)
+	suite.Equal(5, suite.Counting.Calls("GetObject"), "evicted object is fetched again")
+
+	restarted := suite.newCache(4, time.Hour)
+	count, size = restarted.Size()
+	suite.Equal(1, count, "objects beyond MaxBytes are evicted after a restart")
+	suite.Equal(int64(4), size)
+}
+
+func (suite *DiskCacheTestSuite) TestRecovery() {
+	cache := suite.newCache(0, time.Hour)
+	for _, path := range []string{"a.tgz", "b.tgz", "c.tgz"} {
+		suite.Nil(suite.Remote.PutObject(path, []byte("four")))
+		_, err := cache.GetObject(path)
+		suite.Nil(err)
+	}
+
+	// b.tgz was truncated, c.tgz lost its content, and writes of other objects were interrupted
+	suite.Nil(os.WriteFile(cache.contentPath("b.tgz"), []byte("fo"), 0o644))
+	suite.Nil(os.Remove(cache.contentPath("c.tgz")))
+	orphan := cache.contentPath("orphan.tgz")
+	suite.Nil(os.WriteFile(orphan, []byte("orphan"), 0o644))
+	tmp := filepath.Join(suite.CacheDirectory, "."+diskCacheName("tmp.tgz")+".tmp-123")
+	suite.Nil(os.WriteFile(tmp, []byte("tmp"), 0o644))
+	corrupt := cache.indexPath("corrupt.tgz")
+	suite.Nil(os.WriteFile(corrupt, []byte("{"), 0o644))
+	unrelated := filepath.Join(suite.CacheDirectory, "README")
+	suite.Nil(os.WriteFile(unrelated, []byte("unrelated"), 0o644))
+
+	restarted := suite.newCache(0, time.Hour)
+	count, size := restarted.Size()
+	suite.Equal(1, count, "only intact objects are recovered")
+	suite.Equal(int64(4), size)
+	for _, path := range []string{cache.indexPath("b.tgz"), cache.indexPath("c.tgz"), orphan, tmp, corrupt} {
+		_, err := os.Stat(path)
+		suite.True(os.IsNotExist(err), "leftover file %s is removed", filepath.Base(path))
+	}
+	_, err := os.Stat(unrelated)
+	suite.Nil(err, "unrelated file is left untouched")
+
+	object, err := restarted.GetObject("b.tgz")
+	suite.Nil(err)
+	suite.Equal([]byte("four"), object.Content, "truncated object is fetched again")
+}
+
+func (suite *DiskCacheTestSuite) TestInvalidation() {
+	cache := suite.newCache(0, time.Hour)
+	suite.Nil(cache.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+	_, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+
+	suite.Nil(cache.PutObject("mychart-0.1.0.tgz", []byte("mychart changed")))
+	object, err := cache.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+	suite.Equal([]byte("mychart changed"), object.Content, "put object is invalidated")
+
+	suite.Nil(cache.DeleteObject("mychart-0.1.0.tgz"))
+	_, err = cache.GetObject("mychart-0.1.0.tgz")
+	suite.True(errors.Is(err, ErrObjectNotFound), "deleted object is invalidated")
+}
+
+func (suite *DiskCacheTestSuite) TestInvalidConfig() {
+	_, err := NewDiskCacheBackend(suite.Remote, DiskCacheOptions{})
+	suite.True(errors.Is(err, ErrInvalidConfig), "directory is required")
+}
+
+func TestDiskCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(DiskCacheTestSuite))
+}
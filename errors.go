@@ -0,0 +1,137 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrObjectNotFound is returned when the requested object does not exist
+	ErrObjectNotFound = errors.New("object not found")
+	// ErrPermissionDenied is returned when the credentials are missing or not allowed to perform the operation
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrThrottled is returned when the storage service rejected the request because of rate limiting
+	ErrThrottled = errors.New("request throttled")
+	// ErrPreconditionFailed is returned when a condition attached to the request was not met
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnavailable is returned when the storage service is temporarily unable to handle the request
+	ErrUnavailable = errors.New("service unavailable")
+	// ErrNotSupported is returned when the backend, or its configuration, does not support the operation
+	ErrNotSupported = errors.New("operation not supported")
+	// ErrInvalidConfig is returned by the constructors of the backends when their configuration is missing or invalid
+	ErrInvalidConfig = errors.New("invalid backend configuration")
+	// ErrCircuitOpen is returned by a CircuitBreakerBackend when it fails an operation fast because its backend is failing.
+	// It is wrapped in a StorageError of kind ErrUnavailable.
+	ErrCircuitOpen = errors.New("circuit breaker open")
+	// ErrReadOnly is returned by a ReadOnlyBackend when it rejects a mutation. It is wrapped in a StorageError of
+	// kind ErrPermissionDenied.
+	ErrReadOnly = errors.New("backend is read-only")
+	// ErrObjectImmutable is returned by a ReadOnlyBackend in write-once mode when it rejects overwriting or deleting
+	// a write-once object. It is wrapped in a StorageError of kind ErrPermissionDenied.
+	ErrObjectImmutable = errors.New("object is write-once")
+)
+
+// configError describes the missing or invalid configuration which prevents creating a backend
+func configError(backend string, format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %w: %s", backend, ErrInvalidConfig, fmt.Sprintf(format, args...))
+}
+
+// errRangeNotSatisfiable classifies the rejection of a byte range starting past the end of an object.
+// It is not one of the errorKinds: GetObjectRange reads no bytes instead.
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// errorKinds lists the sentinel errors which classify a StorageError
+var errorKinds = []error{ErrObjectNotFound, ErrPermissionDenied, ErrThrottled, ErrPreconditionFailed, ErrUnavailable, ErrNotSupported}
+
+// StorageError records a failed storage operation, the backend and path it was performed on, and its cause.
+// Kind is one of the sentinel errors of this package, or nil if the cause could not be classified.
+// Both Kind and Err can be matched with errors.Is and errors.As.
+type StorageError struct {
+	Backend string
+	Op      string
+	Path    string
+	Kind    error
+	Err     error
+}
+
+func (e *StorageError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %s: %v", e.Backend, e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s: %s %s: %v", e.Backend, e.Op, e.Path, e.Err)
+}
+
+// Unwrap returns the kind and the cause of the error
+func (e *StorageError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// wrapStorageError replaces *err with a StorageError describing the failed operation, using kind to
+// classify the original error unless it already is one of the sentinel errors.
+// Nil errors and errors which already are StorageErrors are left untouched; it reports whether *err was replaced.
+func wrapStorageError(err *error, backend string, op string, path string, kind func(error) error) bool {
+	if *err == nil {
+		return false
+	}
+	var storageErr *StorageError
+	if errors.As(*err, &storageErr) {
+		return false
+	}
+	*err = &StorageError{
+		Backend: backend,
+		Op:      op,
+		Path:    path,
+		Kind:    errorKind(*err, kind),
+		Err:     *err,
+	}
+	return true
+}
+
+// errorKind returns the sentinel error matched by err, or classifies it using kind
+func errorKind(err error, kind func(error) error) error {
+	for _, errKind := range errorKinds {
+		if errors.Is(err, errKind) {
+			return errKind
+		}
+	}
+	return kind(err)
+}
+
+// kindFromStatusCode classifies an HTTP status code returned by a storage service
+func kindFromStatusCode(statusCode int) error {
+	switch statusCode {
+	case http.StatusNotFound:
+		return ErrObjectNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrPermissionDenied
+	case http.StatusTooManyRequests:
+		return ErrThrottled
+	case http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case http.StatusRequestedRangeNotSatisfiable:
+		return errRangeNotSatisfiable
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrUnavailable
+	}
+	return nil
+}
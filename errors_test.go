@@ -0,0 +1,157 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ErrorsTestSuite) TestStorageError() {
+	cause := errors.New("no such key")
+	var err error = &StorageError{
+		Backend: "AmazonS3",
+		Op:      "GetObject",
+		Path:    "mychart-0.1.0.tgz",
+		Kind:    ErrObjectNotFound,
+		Err:     cause,
+	}
+	suite.Equal("AmazonS3: GetObject mychart-0.1.0.tgz: no such key", err.Error(), "error message includes backend, operation and path")
+	suite.True(errors.Is(err, ErrObjectNotFound), "error matches its kind")
+	suite.True(errors.Is(err, cause), "error matches its cause")
+	suite.False(errors.Is(err, ErrPermissionDenied), "error does not match other kinds")
+
+	err = &StorageError{Backend: "AmazonS3", Op: "ListObjects", Err: cause}
+	suite.Equal("AmazonS3: ListObjects: no such key", err.Error(), "error message omits empty path")
+	suite.True(errors.Is(err, cause), "unclassified error matches its cause")
+}
+
+func (suite *ErrorsTestSuite) TestWrapStorageError() {
+	var err error
+	wrapStorageError(&err, "etcd", "GetObject", "test.txt", etcdErrorKind)
+	suite.Nil(err, "nil error is not wrapped")
+
+	err = ErrNotExist
+	wrapStorageError(&err, "etcd", "GetObject", "test.txt", etcdErrorKind)
+	suite.True(errors.Is(err, ErrObjectNotFound), "etcd ErrNotExist is classified as ErrObjectNotFound")
+	suite.True(errors.Is(err, ErrNotExist), "etcd ErrNotExist can still be matched")
+
+	wrapped := err
+	wrapStorageError(&err, "etcd", "GetObjectReader", "test.txt", etcdErrorKind)
+	suite.Equal(wrapped, err, "StorageError is not wrapped twice")
+
+	err = &os.PathError{Op: "open", Path: "test.txt", Err: os.ErrPermission}
+	wrapStorageError(&err, "LocalFilesystem", "GetObject", "test.txt", localFilesystemErrorKind)
+	suite.True(errors.Is(err, ErrPermissionDenied), "permission error is classified as ErrPermissionDenied")
+
+	err = ErrPreconditionFailed
+	wrapStorageError(&err, "LocalFilesystem", "PutObjectIf", "test.txt", localFilesystemErrorKind)
+	var storageErr *StorageError
+	suite.True(errors.As(err, &storageErr), "sentinel error is wrapped in a StorageError")
+	suite.Equal(ErrPreconditionFailed, storageErr.Kind, "sentinel error is its own kind")
+}
+
+func (suite *ErrorsTestSuite) TestKindFromStatusCode() {
+	suite.Equal(ErrObjectNotFound, kindFromStatusCode(http.StatusNotFound))
+	suite.Equal(ErrPermissionDenied, kindFromStatusCode(http.StatusUnauthorized))
+	suite.Equal(ErrPermissionDenied, kindFromStatusCode(http.StatusForbidden))
+	suite.Equal(ErrThrottled, kindFromStatusCode(http.StatusTooManyRequests))
+	suite.Equal(ErrPreconditionFailed, kindFromStatusCode(http.StatusPreconditionFailed))
+	suite.Equal(errRangeNotSatisfiable, kindFromStatusCode(http.StatusRequestedRangeNotSatisfiable))
+	suite.Equal(ErrUnavailable, kindFromStatusCode(http.StatusServiceUnavailable))
+	suite.Nil(kindFromStatusCode(http.StatusBadRequest))
+}
+
+func (suite *ErrorsTestSuite) TestConfigError() {
+	for _, key := range []string{
+		"ALIBABA_CLOUD_ACCESS_KEY_ID", "BAIDU_CLOUD_ACCESS_KEY_ID", "TENCENT_CLOUD_COS_SECRET_ID",
+		"AZURE_STORAGE_ACCOUNT", "ST_USER",
+	} {
+		suite.T().Setenv(key, "")
+	}
+	file, err := os.CreateTemp("", "storage-config")
+	suite.Nil(err)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	constructors := map[string]func() error{
+		"alibaba without credentials": func() error {
+			_, err := NewAlibabaCloudOSSBackendE("mybucket", "", "", "")
+			return err
+		},
+		"baidu without credentials": func() error {
+			_, err := NewBaiDuBOSBackendE("mybucket", "", "")
+			return err
+		},
+		"tencent without credentials": func() error {
+			_, err := NewTencentCloudCOSBackendE("mybucket", "", "")
+			return err
+		},
+		"microsoft without credentials": func() error {
+			_, err := NewMicrosoftBlobBackendE("mycontainer", "")
+			return err
+		},
+		"openstack without container": func() error {
+			_, err := NewOpenstackOSBackendE("", "", "", "")
+			return err
+		},
+		"openstack v1 without credentials": func() error {
+			_, err := NewOpenstackOSBackendV1AuthE("mycontainer", "", "")
+			return err
+		},
+		"google without bucket": func() error {
+			_, err := NewGoogleCSBackendE("", "")
+			return err
+		},
+		"oracle without bucket": func() error {
+			_, err := NewOracleCSBackendE("", "", "", "")
+			return err
+		},
+		"etcd without endpoints": func() error {
+			_, err := NewEtcdCSBackendE("", "", "", "", "")
+			return err
+		},
+		"local with a file as root directory": func() error {
+			_, err := NewLocalFilesystemBackendE(file.Name())
+			return err
+		},
+	}
+	for name, constructor := range constructors {
+		err := constructor()
+		suite.True(errors.Is(err, ErrInvalidConfig), "%s is an invalid configuration: %v", name, err)
+	}
+
+	suite.Panics(func() {
+		NewLocalFilesystemBackend(file.Name())
+	}, "constructor without error result panics on invalid configuration")
+
+	backend, err := Open("azblob://mycontainer")
+	suite.True(errors.Is(err, ErrInvalidConfig), "opening a misconfigured backend fails")
+	suite.Nil(backend, "no backend is returned for an invalid configuration")
+}
+
+func TestErrorsTestSuite(t *testing.T) {
+	suite.Run(t, new(ErrorsTestSuite))
+}
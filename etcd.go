@@ -1,23 +1,39 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"iter"
+	"log/slog"
 	pathutil "path"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/pkg/v3/transport"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const DefaultPrefix = "/chart_backend_bucket"
 
+// etcdDeleteBatchSize deletes three keys per object, the object and its metadata and timestamp keys,
+// within the default limit of 128 operations per transaction
+const etcdDeleteBatchSize = 42
+
 var (
 	DefileDialTimeOut    = "5s"
 	TimeStampKey         = "timestamp"
+	MetadataKey          = "metadata"
 	ErrNotExistEndpoints = fmt.Errorf("endpoints cannot connect !")
 	ErrNotExist          = fmt.Errorf("not exist!")
 )
@@ -36,6 +52,7 @@ type etcdStorage struct {
 	bucket string
 	ctx    context.Context
 	mu     sync.RWMutex
+	logger *slog.Logger
 }
 
 //connection cut off
@@ -66,8 +83,8 @@ func (e *etcdStorage) probe() error {
 	return nil
 }
 
-func (e *etcdStorage) timeStamp(path string) time.Time {
-	ctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+func (e *etcdStorage) timeStamp(ctx context.Context, path string) time.Time {
+	ctx, cancel := context.WithTimeout(ctx, e.opts.dialtimeout)
 	newpath := pathutil.Join(path, TimeStampKey)
 	resps, err := e.c.Get(ctx, newpath)
 	cancel()
@@ -84,66 +101,312 @@ func (e *etcdStorage) timeStamp(path string) time.Time {
 	return time.Unix(times, 0)
 }
 
-func (e *etcdStorage) setTimeStamp(path string, updatetime time.Time) error {
-	ctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+func (e *etcdStorage) delTimeStamp(ctx context.Context, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, e.opts.dialtimeout)
 	newpath := pathutil.Join(path, TimeStampKey)
-	_, err := e.c.Put(ctx, newpath, fmt.Sprintf("%d", updatetime.Unix()))
+	_, err := e.c.Delete(ctx, newpath)
 	cancel()
 	return err
 }
 
-func (e *etcdStorage) delTimeStamp(path string) error {
-	ctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
-	newpath := pathutil.Join(path, TimeStampKey)
-	_, err := e.c.Delete(ctx, newpath)
+// etcdMetadata is the JSON document stored at the metadata key of an object
+type etcdMetadata struct {
+	ContentType  string            `json:"contentType,omitempty"`
+	UserMetadata map[string]string `json:"userMetadata,omitempty"`
+}
+
+// metadata retrieves the content type and user metadata stored along with the object at path, if any
+func (e *etcdStorage) metadata(ctx context.Context, path string) (etcdMetadata, error) {
+	var metadata etcdMetadata
+	ctx, cancel := context.WithTimeout(ctx, e.opts.dialtimeout)
+	newpath := pathutil.Join(path, MetadataKey)
+	resps, err := e.c.Get(ctx, newpath)
 	cancel()
-	return err
+	if err != nil || len(resps.Kvs) != 1 {
+		return metadata, err
+	}
+	err = json.Unmarshal(resps.Kvs[0].Value, &metadata)
+	return metadata, err
+}
+
+// putObject stores content at path, and replaces the metadata and modification time of the object in the same
+// transaction. The transaction is only applied if all cmps hold, otherwise ErrPreconditionFailed is returned.
+func (e *etcdStorage) putObject(ctx context.Context, path string, content []byte, metadata etcdMetadata, cmps ...clientv3.Cmp) error {
+	var (
+		updatetime = time.Now()
+	)
+	newpath := pathutil.Join(e.base, path)
+	ops, err := objectOps(newpath, content, metadata)
+	if err != nil {
+		return err
+	}
+	ops = append(ops, clientv3.OpPut(pathutil.Join(newpath, TimeStampKey), fmt.Sprintf("%d", updatetime.Unix())))
+	putctx, cancel := context.WithTimeout(ctx, e.opts.dialtimeout)
+	resp, err := e.c.Txn(putctx).If(cmps...).Then(ops...).Commit()
+	cancel()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// objectOps returns the operations storing content at the key newpath, and replacing its metadata
+func objectOps(newpath string, content []byte, metadata etcdMetadata) ([]clientv3.Op, error) {
+	metapath := pathutil.Join(newpath, MetadataKey)
+	ops := []clientv3.Op{clientv3.OpPut(newpath, string(content))}
+	if metadata.ContentType == "" && len(metadata.UserMetadata) == 0 {
+		return append(ops, clientv3.OpDelete(metapath)), nil
+	}
+	value, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	return append(ops, clientv3.OpPut(metapath, string(value))), nil
+}
+
+// listedTimeStamps returns the modification times found among listed keys, by the key of their object
+func listedTimeStamps(resps *clientv3.GetResponse) map[string]time.Time {
+	timestamps := make(map[string]time.Time)
+	for _, kv := range resps.Kvs {
+		key := string(kv.Key)
+		if parent := strings.TrimSuffix(key, "/"+TimeStampKey); parent != key {
+			if times, err := strconv.ParseInt(string(kv.Value), 10, 64); err == nil {
+				timestamps[parent] = time.Unix(times, 0)
+			}
+		}
+	}
+	return timestamps
+}
+
+// SetLogger sets the logger of the failed operations on etcd. Nothing is logged without a logger.
+func (e *etcdStorage) SetLogger(logger *slog.Logger) {
+	e.logger = logger
+}
+
+// Capabilities returns the optional features supported by etcd, which lists keys along with their values
+func (e *etcdStorage) Capabilities() CapabilitySet {
+	return NewCapabilitySet(
+		CapabilityContext, CapabilityStream, CapabilityRange, CapabilityMetadata,
+		CapabilityStat, CapabilityHierarchical, CapabilityPaging, CapabilityBatchDelete,
+		CapabilityCopy, CapabilityConditional, CapabilityVersioning, CapabilityListContent,
+		CapabilityWatch,
+	)
 }
 
-//
 func (e *etcdStorage) ListObjects(prefix string) ([]Object, error) {
+	return e.ListObjectsContext(e.ctx, prefix)
+}
+
+// ListObjectsContext lists all keys below prefix, each request bounded by ctx and the dial timeout
+func (e *etcdStorage) ListObjectsContext(ctx context.Context, prefix string) (_ []Object, err error) {
+	defer e.wrapError(&err, "ListObjects", prefix)
 	var (
 		objs []Object
 	)
-	ctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	listctx, cancel := context.WithTimeout(ctx, e.opts.dialtimeout)
 	newpath := pathutil.Join(e.base, prefix)
-	resps, err := e.c.Get(ctx, newpath, clientv3.WithPrefix())
+	resps, err := e.c.Get(listctx, newpath, clientv3.WithPrefix())
 	cancel()
 	if err != nil {
 		return nil, err
 	}
+	timestamps := listedTimeStamps(resps)
 	for _, kv := range resps.Kvs {
-		if kv.Value != nil {
-			path := removePrefixFromObjectPath(newpath, string(kv.Key))
-			if objectPathIsInvalid(path) {
-				continue
-			}
-			//TODO need optimizate
-			if strings.HasSuffix(path, TimeStampKey) {
-				continue
-			}
-			modtime := e.timeStamp(newpath)
-			if modtime.IsZero() {
-				modtime = time.Unix(kv.ModRevision, 0)
-			}
-			objs = append(objs, Object{
-				Path:         path,
-				Content:      kv.Value,
-				LastModified: modtime,
-			})
+		path := removePrefixFromObjectPath(newpath, string(kv.Key))
+		// timestamp and metadata keys are nested below their object, and therefore invalid paths
+		if kv.Value == nil || objectPathIsInvalid(path) {
+			continue
 		}
+		modtime, ok := timestamps[string(kv.Key)]
+		if !ok {
+			modtime = time.Unix(kv.ModRevision, 0)
+		}
+		objs = append(objs, Object{
+			Path:         path,
+			Content:      kv.Value,
+			LastModified: modtime,
+			Size:         int64(len(kv.Value)),
+			ETag:         strconv.FormatInt(kv.ModRevision, 10),
+		})
 	}
 	return objs, nil
 
 }
 
+// ListObjectsPage lists a page of the keys below prefix (depth 1), in key order.
+// The token is the last key, relative to prefix, read for the previous page.
+func (e *etcdStorage) ListObjectsPage(prefix string, token string, limit int) (_ ObjectPage, err error) {
+	defer e.wrapError(&err, "ListObjectsPage", prefix)
+	var (
+		page ObjectPage
+	)
+	newpath := pathutil.Join(e.base, prefix) + "/"
+	listctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	resps, err := e.c.Get(listctx, newpath+token+"\x00",
+		clientv3.WithRange(clientv3.GetPrefixRangeEnd(newpath)),
+		clientv3.WithLimit(int64(pageSize(limit))),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	cancel()
+	if err != nil {
+		return page, err
+	}
+	// the timestamp key of an object directly follows it, so it is usually listed in the same page
+	timestamps := listedTimeStamps(resps)
+	for _, kv := range resps.Kvs {
+		path := strings.TrimPrefix(string(kv.Key), newpath)
+		// timestamp and metadata keys are nested below their object, and therefore invalid paths
+		if kv.Value == nil || objectPathIsInvalid(path) {
+			continue
+		}
+		modtime, ok := timestamps[string(kv.Key)]
+		if !ok {
+			modtime = time.Unix(kv.ModRevision, 0)
+		}
+		page.Objects = append(page.Objects, Object{
+			Path:         path,
+			Content:      kv.Value,
+			LastModified: modtime,
+			Size:         int64(len(kv.Value)),
+			ETag:         strconv.FormatInt(kv.ModRevision, 10),
+		})
+	}
+	if resps.More && len(resps.Kvs) > 0 {
+		page.NextToken = strings.TrimPrefix(string(resps.Kvs[len(resps.Kvs)-1].Key), newpath)
+	}
+	return page, nil
+}
+
+// ListObjectsIter iterates over the keys below prefix (depth 1)
+func (e *etcdStorage) ListObjectsIter(prefix string) iter.Seq2[Object, error] {
+	return listObjectsIter(e, prefix)
+}
+
+// ListObjectsWithOptions lists the keys below prefix, in a single request bounded by the dial timeout.
+// The timestamp and metadata keys stored along with each object are not listed.
+func (e *etcdStorage) ListObjectsWithOptions(prefix string, options ListObjectsOptions) (_ ListObjectsResult, err error) {
+	defer e.wrapError(&err, "ListObjects", prefix)
+	var (
+		objs     []Object
+		internal = make(map[string]bool)
+	)
+	listctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	newpath := pathutil.Join(e.base, prefix) + "/"
+	resps, err := e.c.Get(listctx, newpath, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	cancel()
+	if err != nil {
+		return ListObjectsResult{}, err
+	}
+	timestamps := listedTimeStamps(resps)
+	for _, kv := range resps.Kvs {
+		key := string(kv.Key)
+		for _, suffix := range []string{"/" + TimeStampKey, "/" + MetadataKey} {
+			if parent := strings.TrimSuffix(key, suffix); parent != key {
+				if _, ok := timestamps[parent]; ok {
+					internal[key] = true
+				}
+			}
+		}
+	}
+	for _, kv := range resps.Kvs {
+		key := string(kv.Key)
+		if kv.Value == nil || internal[key] {
+			continue
+		}
+		modtime, ok := timestamps[key]
+		if !ok {
+			modtime = time.Unix(kv.ModRevision, 0)
+		}
+		objs = append(objs, Object{
+			Path:         strings.TrimPrefix(key, newpath),
+			Content:      kv.Value,
+			LastModified: modtime,
+			Size:         int64(len(kv.Value)),
+			ETag:         strconv.FormatInt(kv.ModRevision, 10),
+		})
+	}
+	return groupObjects(objs, options), nil
+}
+
+// Watch watches the keys below prefix (depth 1), sending an event for each key put or deleted until ctx is done.
+// The timestamp and metadata keys stored along with each object are not watched.
+func (e *etcdStorage) Watch(ctx context.Context, prefix string) <-chan WatchEvent {
+	events := make(chan WatchEvent)
+	newpath := pathutil.Join(e.base, prefix) + "/"
+	watch := e.c.Watch(clientv3.WithRequireLeader(ctx), newpath, clientv3.WithPrefix())
+	go func() {
+		defer close(events)
+		send := func(event WatchEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for resp := range watch {
+			if err := resp.Err(); err != nil {
+				if ctx.Err() == nil {
+					e.wrapError(&err, "Watch", prefix)
+					send(WatchEvent{Err: err})
+				}
+				return
+			}
+			// the timestamp key of an object is put in the same transaction, and therefore notified along with it
+			timestamps := make(map[string]time.Time)
+			for _, ev := range resp.Events {
+				key := string(ev.Kv.Key)
+				if parent := strings.TrimSuffix(key, "/"+TimeStampKey); parent != key && ev.Type == clientv3.EventTypePut {
+					if times, err := strconv.ParseInt(string(ev.Kv.Value), 10, 64); err == nil {
+						timestamps[parent] = time.Unix(times, 0)
+					}
+				}
+			}
+			for _, ev := range resp.Events {
+				key := string(ev.Kv.Key)
+				path := strings.TrimPrefix(key, newpath)
+				// timestamp and metadata keys are nested below their object, and therefore invalid paths
+				if objectPathIsInvalid(path) {
+					continue
+				}
+				event := WatchEvent{Type: WatchDelete, Object: Object{Path: path}}
+				if ev.Type == clientv3.EventTypePut {
+					modtime, ok := timestamps[key]
+					if !ok {
+						modtime = time.Unix(ev.Kv.ModRevision, 0)
+					}
+					event = WatchEvent{Type: WatchPut, Object: Object{
+						Path:         path,
+						Content:      ev.Kv.Value,
+						LastModified: modtime,
+						Size:         int64(len(ev.Kv.Value)),
+						ETag:         strconv.FormatInt(ev.Kv.ModRevision, 10),
+					}}
+				}
+				if !send(event) {
+					return
+				}
+			}
+		}
+	}()
+	return events
+}
+
 func (e *etcdStorage) GetObject(path string) (Object, error) {
+	return e.GetObjectContext(e.ctx, path)
+}
+
+// GetObjectContext retrieves the value of a key, each request bounded by ctx and the dial timeout
+func (e *etcdStorage) GetObjectContext(ctx context.Context, path string) (_ Object, err error) {
+	defer e.wrapError(&err, "GetObject", path)
 	var (
 		modifytime time.Time
 	)
-	ctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	getctx, cancel := context.WithTimeout(ctx, e.opts.dialtimeout)
 	newpath := pathutil.Join(e.base, path)
-	resps, err := e.c.Get(ctx, newpath)
+	resps, err := e.c.Get(getctx, newpath)
 	cancel()
 	if err != nil {
 		return Object{}, err
@@ -151,51 +414,401 @@ func (e *etcdStorage) GetObject(path string) (Object, error) {
 	if len(resps.Kvs) != 1 || resps.Kvs[0].Value == nil {
 		return Object{}, ErrNotExist
 	}
-	modifytime = e.timeStamp(newpath)
+	modifytime = e.timeStamp(ctx, newpath)
 	if modifytime.IsZero() {
 		// if timestamp not set , keep old version
 		modifytime = time.Unix(resps.Kvs[0].ModRevision, 0)
 	}
+	metadata, err := e.metadata(ctx, newpath)
+	if err != nil {
+		return Object{}, err
+	}
 	return Object{
+		Meta:         Metadata{Name: path, Version: strconv.FormatInt(resps.Kvs[0].ModRevision, 10)},
 		Path:         path,
 		Content:      resps.Kvs[0].Value,
 		LastModified: modifytime,
+		Size:         int64(len(resps.Kvs[0].Value)),
+		ETag:         strconv.FormatInt(resps.Kvs[0].ModRevision, 10),
+		ContentType:  metadata.ContentType,
+		UserMetadata: metadata.UserMetadata,
+	}, nil
+}
+
+// GetObjectVersion retrieves the value of a key as of the revision versionID, which must be a
+// revision at which the key was modified and which has not been compacted yet
+func (e *etcdStorage) GetObjectVersion(path string, versionID string) (_ Object, err error) {
+	defer e.wrapError(&err, "GetObjectVersion", path)
+	revision, err := strconv.ParseInt(versionID, 10, 64)
+	if err != nil {
+		return Object{}, ErrNotExist
+	}
+	newpath := pathutil.Join(e.base, path)
+	getctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	defer cancel()
+	resps, err := e.c.Txn(getctx).Then(
+		clientv3.OpGet(newpath, clientv3.WithRev(revision)),
+		clientv3.OpGet(pathutil.Join(newpath, MetadataKey), clientv3.WithRev(revision)),
+		clientv3.OpGet(pathutil.Join(newpath, TimeStampKey), clientv3.WithRev(revision)),
+	).Commit()
+	if errors.Is(err, rpctypes.ErrCompacted) {
+		return Object{}, ErrNotExist
+	}
+	if err != nil {
+		return Object{}, err
+	}
+	kvs := resps.Responses[0].GetResponseRange().Kvs
+	if len(kvs) != 1 || kvs[0].ModRevision != revision {
+		return Object{}, ErrNotExist
+	}
+	var metadata etcdMetadata
+	if mkvs := resps.Responses[1].GetResponseRange().Kvs; len(mkvs) == 1 {
+		if err := json.Unmarshal(mkvs[0].Value, &metadata); err != nil {
+			return Object{}, err
+		}
+	}
+	modifytime := storedTimeStamp(resps.Responses[2].GetResponseRange().Kvs)
+	return Object{
+		Meta:         Metadata{Name: path, Version: versionID},
+		Path:         path,
+		Content:      kvs[0].Value,
+		LastModified: modifytime,
+		Size:         int64(len(kvs[0].Value)),
+		ETag:         versionID,
+		ContentType:  metadata.ContentType,
+		UserMetadata: metadata.UserMetadata,
 	}, nil
 }
 
 func (e *etcdStorage) PutObject(path string, content []byte) error {
+	return e.PutObjectContext(e.ctx, path, content)
+}
+
+// PutObjectContext stores content at a key, each request bounded by ctx and the dial timeout
+func (e *etcdStorage) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	defer e.wrapError(&err, "PutObject", path)
+	return e.putObject(ctx, path, content, etcdMetadata{})
+}
+
+// PutObjectWithOptions stores content at a key, along with its content type and user metadata.
+// etcd has no storage classes, so the storage class is ignored.
+func (e *etcdStorage) PutObjectWithOptions(path string, content []byte, options PutObjectOptions) (err error) {
+	defer e.wrapError(&err, "PutObjectWithOptions", path)
+	metadata := etcdMetadata{
+		ContentType:  options.ContentType,
+		UserMetadata: lowerCaseKeys(options.UserMetadata),
+	}
+	return e.putObject(e.ctx, path, content, metadata)
+}
+
+// PutObjectIf stores content at a key if condition is met, comparing the creation or modification
+// revision of the key within the transaction which writes it
+func (e *etcdStorage) PutObjectIf(path string, content []byte, condition Condition) (err error) {
+	defer e.wrapError(&err, "PutObjectIf", path)
+	if err := condition.validate(); err != nil {
+		return err
+	}
 	var (
-		updatetime = time.Now()
+		cmps    []clientv3.Cmp
+		newpath = pathutil.Join(e.base, path)
 	)
-	ctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	if condition.IfNotExists {
+		cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(newpath), "=", 0))
+	}
+	if condition.IfMatch != "" {
+		revision, err := strconv.ParseInt(condition.IfMatch, 10, 64)
+		if err != nil {
+			return ErrPreconditionFailed
+		}
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(newpath), "=", revision))
+	}
+	return e.putObject(e.ctx, path, content, etcdMetadata{}, cmps...)
+}
+
+func (e *etcdStorage) DeleteObject(path string) error {
+	return e.DeleteObjectContext(e.ctx, path)
+}
+
+// DeleteObjectContext removes a key, bounded by ctx and the dial timeout
+func (e *etcdStorage) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	defer e.wrapError(&err, "DeleteObject", path)
+	delctx, cancel := context.WithTimeout(ctx, e.opts.dialtimeout)
 	newpath := pathutil.Join(e.base, path)
-	_, err := e.c.Put(ctx, newpath, string(content))
+	_, err = e.c.Txn(delctx).Then(
+		clientv3.OpDelete(newpath),
+		clientv3.OpDelete(pathutil.Join(newpath, MetadataKey)),
+		clientv3.OpDelete(pathutil.Join(newpath, TimeStampKey)),
+	).Commit()
 	cancel()
 	if err != nil {
 		return err
 	} else {
-		return e.setTimeStamp(newpath, updatetime)
+		return nil
 	}
 }
 
-func (e *etcdStorage) DeleteObject(path string) error {
-	ctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
-	newpath := pathutil.Join(e.base, path)
-	_, err := e.c.Delete(ctx, newpath)
+// DeleteObjects removes keys in transactions of at most etcdDeleteBatchSize objects, so that
+// each transaction stays below the default limit of operations per transaction of the server
+func (e *etcdStorage) DeleteObjects(paths []string) ([]DeleteResult, error) {
+	return deleteObjectsInBatches(paths, etcdDeleteBatchSize, e.deleteBatch)
+}
+
+func (e *etcdStorage) deleteBatch(batch []DeleteResult) (err error) {
+	defer e.wrapError(&err, "DeleteObjects", "")
+	delctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	defer cancel()
+	ops := make([]clientv3.Op, 0, 3*len(batch))
+	for _, result := range batch {
+		newpath := pathutil.Join(e.base, result.Path)
+		ops = append(ops, clientv3.OpDelete(newpath), clientv3.OpDelete(pathutil.Join(newpath, MetadataKey)),
+			clientv3.OpDelete(pathutil.Join(newpath, TimeStampKey)))
+	}
+	_, err = e.c.Txn(delctx).Then(ops...).Commit()
+	return err
+}
+
+// CopyObject copies the value and the metadata of a key to another key
+func (e *etcdStorage) CopyObject(src string, dst string) (err error) {
+	defer e.wrapError(&err, "CopyObject", src)
+	object, err := e.GetObjectContext(e.ctx, src)
+	if err != nil {
+		return err
+	}
+	return e.putObject(e.ctx, dst, object.Content, etcdMetadata{ContentType: object.ContentType, UserMetadata: object.UserMetadata})
+}
+
+// MoveObject moves the value, metadata and timestamp of a key to another key in a single transaction,
+// which fails with ErrPreconditionFailed if the key is modified while being moved
+func (e *etcdStorage) MoveObject(src string, dst string) (err error) {
+	defer e.wrapError(&err, "MoveObject", src)
+	if samePath(src, dst) {
+		return nil
+	}
+	srcpath := pathutil.Join(e.base, src)
+	dstpath := pathutil.Join(e.base, dst)
+	getctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	resps, err := e.c.Get(getctx, srcpath)
 	cancel()
 	if err != nil {
 		return err
+	}
+	if len(resps.Kvs) != 1 || resps.Kvs[0].Value == nil {
+		return ErrNotExist
+	}
+	metadata, err := e.metadata(e.ctx, srcpath)
+	if err != nil {
+		return err
+	}
+	ops, err := objectOps(dstpath, resps.Kvs[0].Value, metadata)
+	if err != nil {
+		return err
+	}
+	ops = append(ops, clientv3.OpDelete(srcpath), clientv3.OpDelete(pathutil.Join(srcpath, MetadataKey)))
+	if updatetime := e.timeStamp(e.ctx, srcpath); updatetime.Unix() != 0 {
+		ops = append(ops,
+			clientv3.OpPut(pathutil.Join(dstpath, TimeStampKey), fmt.Sprintf("%d", updatetime.Unix())),
+			clientv3.OpDelete(pathutil.Join(srcpath, TimeStampKey)),
+		)
 	} else {
+		ops = append(ops, clientv3.OpDelete(pathutil.Join(dstpath, TimeStampKey)))
+	}
+	movectx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	resp, err := e.c.Txn(movectx).
+		If(clientv3.Compare(clientv3.ModRevision(srcpath), "=", resps.Kvs[0].ModRevision)).
+		Then(ops...).
+		Commit()
+	cancel()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// ListObjectVersions lists the revisions at which the keys below prefix (depth 1) were modified,
+// newest first for each key, along with the modification time stored with each revision. Only keys
+// which currently exist are listed, and their history stops at the oldest revision which has not
+// been compacted.
+func (e *etcdStorage) ListObjectVersions(prefix string) (_ []Object, err error) {
+	defer e.wrapError(&err, "ListObjectVersions", prefix)
+	var objs []Object
+	newpath := pathutil.Join(e.base, prefix)
+	listctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	resps, err := e.c.Get(listctx, newpath, clientv3.WithPrefix())
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	timestamps := listedTimeStamps(resps)
+	for _, kv := range resps.Kvs {
+		path := removePrefixFromObjectPath(newpath, string(kv.Key))
+		if kv.Value == nil || objectPathIsInvalid(path) {
+			continue
+		}
+		modifytime, ok := timestamps[string(kv.Key)]
+		if !ok {
+			modifytime = time.Unix(0, 0)
+		}
+		for kv != nil {
+			objs = append(objs, Object{
+				Meta:         Metadata{Name: path, Version: strconv.FormatInt(kv.ModRevision, 10)},
+				Path:         path,
+				Content:      []byte{},
+				LastModified: modifytime,
+				Size:         int64(len(kv.Value)),
+				ETag:         strconv.FormatInt(kv.ModRevision, 10),
+			})
+			if kv, modifytime, err = e.previousRevision(kv); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return objs, nil
+}
+
+// previousRevision returns the key as it was before its revision kv, along with the modification time
+// stored with it, or nil if it did not exist then or if that revision has been compacted
+func (e *etcdStorage) previousRevision(kv *mvccpb.KeyValue) (*mvccpb.KeyValue, time.Time, error) {
+	if kv.ModRevision <= 1 {
+		return nil, time.Time{}, nil
+	}
+	key := string(kv.Key)
+	getctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	resps, err := e.c.Txn(getctx).Then(
+		clientv3.OpGet(key, clientv3.WithRev(kv.ModRevision-1)),
+		clientv3.OpGet(pathutil.Join(key, TimeStampKey), clientv3.WithRev(kv.ModRevision-1)),
+	).Commit()
+	cancel()
+	if errors.Is(err, rpctypes.ErrCompacted) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	kvs := resps.Responses[0].GetResponseRange().Kvs
+	if len(kvs) != 1 {
+		return nil, time.Time{}, nil
+	}
+	return kvs[0], storedTimeStamp(resps.Responses[1].GetResponseRange().Kvs), nil
+}
+
+// storedTimeStamp returns the modification time stored in the timestamp key of an object, got as kvs
+func storedTimeStamp(kvs []*mvccpb.KeyValue) time.Time {
+	if len(kvs) == 1 {
+		if updatetime, err := strconv.ParseInt(string(kvs[0].Value), 10, 64); err == nil {
+			return time.Unix(updatetime, 0)
+		}
+	}
+	return time.Unix(0, 0)
+}
+
+// DeleteObjectVersion removes a key if versionID is its current revision. Older revisions are only
+// removed by compacting the etcd keyspace, so deleting them fails with ErrPreconditionFailed.
+func (e *etcdStorage) DeleteObjectVersion(path string, versionID string) (err error) {
+	defer e.wrapError(&err, "DeleteObjectVersion", path)
+	revision, err := strconv.ParseInt(versionID, 10, 64)
+	if err != nil {
+		return ErrNotExist
+	}
+	newpath := pathutil.Join(e.base, path)
+	delctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	resp, err := e.c.Txn(delctx).
+		If(clientv3.Compare(clientv3.ModRevision(newpath), "=", revision)).
+		Then(
+			clientv3.OpDelete(newpath),
+			clientv3.OpDelete(pathutil.Join(newpath, MetadataKey)),
+			clientv3.OpDelete(pathutil.Join(newpath, TimeStampKey)),
+		).
+		Else(clientv3.OpGet(newpath), clientv3.OpGet(newpath, clientv3.WithRev(revision))).
+		Commit()
+	cancel()
+	if errors.Is(err, rpctypes.ErrCompacted) || errors.Is(err, rpctypes.ErrFutureRev) {
+		return ErrNotExist
+	}
+	if err != nil {
+		return err
+	}
+	if resp.Succeeded {
 		return nil
 	}
+	// the key does not exist anymore, was not modified at revision, or has been modified since
+	if len(resp.Responses[0].GetResponseRange().Kvs) != 1 {
+		return ErrNotExist
+	}
+	kvs := resp.Responses[1].GetResponseRange().Kvs
+	if len(kvs) != 1 || kvs[0].ModRevision != revision {
+		return ErrNotExist
+	}
+	return ErrPreconditionFailed
+}
+
+// GetObjectReader returns a reader over the value of a key; etcd values are always held in memory
+func (e *etcdStorage) GetObjectReader(path string) (_ io.ReadCloser, _ ObjectInfo, err error) {
+	defer e.wrapError(&err, "GetObjectReader", path)
+	object, err := e.GetObject(path)
+	if err != nil {
+		return nil, ObjectInfo{Path: path}, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(object.Content)), object.Info(), nil
+}
+
+// GetObjectRange slices the value of a key; etcd values are always read whole
+func (e *etcdStorage) GetObjectRange(path string, offset int64, length int64) (_ []byte, err error) {
+	defer e.wrapError(&err, "GetObjectRange", path)
+	object, err := e.GetObjectContext(e.ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(object.Content, offset, length)
+}
+
+// PutObjectStream reads the whole stream and stores it as the value of a key
+func (e *etcdStorage) PutObjectStream(path string, content io.Reader, size int64) (err error) {
+	defer e.wrapError(&err, "PutObjectStream", path)
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	return e.PutObject(path, data)
+}
+
+// StatObject checks a key exists without fetching its value. The size of the value is
+// therefore unknown (-1), and the modification revision of the key is used as its ETag.
+func (e *etcdStorage) StatObject(path string) (_ ObjectInfo, err error) {
+	defer e.wrapError(&err, "StatObject", path)
+	info := ObjectInfo{Path: path, Size: -1}
+	ctx, cancel := context.WithTimeout(e.ctx, e.opts.dialtimeout)
+	newpath := pathutil.Join(e.base, path)
+	resps, err := e.c.Get(ctx, newpath, clientv3.WithKeysOnly())
+	cancel()
+	if err != nil {
+		return info, err
+	}
+	if len(resps.Kvs) != 1 {
+		return info, ErrNotExist
+	}
+	info.LastModified = e.timeStamp(e.ctx, newpath)
+	if info.LastModified.IsZero() {
+		info.LastModified = time.Unix(resps.Kvs[0].ModRevision, 0)
+	}
+	info.ETag = strconv.FormatInt(resps.Kvs[0].ModRevision, 10)
+	metadata, err := e.metadata(e.ctx, newpath)
+	if err != nil {
+		return info, err
+	}
+	info.ContentType = metadata.ContentType
+	info.UserMetadata = metadata.UserMetadata
+	return info, nil
 }
 
-func parseConf(endpoints string, cafile, certfile, keyfile string, dialtime time.Duration) clientv3.Config {
+func parseConf(endpoints string, cafile, certfile, keyfile string, dialtime time.Duration) (clientv3.Config, error) {
 	var (
 		es []string
 	)
 	if endpoints == "" {
-		panic(ErrNotExistEndpoints)
+		return clientv3.Config{}, configError("etcd", "%s", ErrNotExistEndpoints)
 	}
 	es = strings.Split(endpoints, ",")
 	tlsInfo := transport.TLSInfo{
@@ -205,23 +818,39 @@ func parseConf(endpoints string, cafile, certfile, keyfile string, dialtime time
 	}
 	tlsConfig, err := tlsInfo.ClientConfig()
 	if err != nil {
-		panic(err)
+		return clientv3.Config{}, configError("etcd", "invalid TLS configuration: %s", err)
 	}
 	return clientv3.Config{
 		Endpoints:   es,
 		DialTimeout: dialtime,
 		TLS:         tlsConfig,
-	}
+	}, nil
 }
 
+// NewEtcdCSBackend creates a new etcd backend.
+// It panics if the backend cannot be created; NewEtcdCSBackendE returns the error instead.
 func NewEtcdCSBackend(endpoints string, cafile, certfile, keyfile string, prefix string) Backend {
+	e, err := NewEtcdCSBackendE(endpoints, cafile, certfile, keyfile, prefix)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// NewEtcdCSBackendE creates a new etcd backend connected to the comma separated endpoints, and
+// checks they can be reached by writing the key of prefix
+func NewEtcdCSBackendE(endpoints string, cafile, certfile, keyfile string, prefix string) (Backend, error) {
 	var (
 		basepath string
 	)
 	DialTimeOut, _ := time.ParseDuration(DefileDialTimeOut)
-	cli, err := clientv3.New(parseConf(endpoints, cafile, certfile, keyfile, DialTimeOut))
+	conf, err := parseConf(endpoints, cafile, certfile, keyfile, DialTimeOut)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	cli, err := clientv3.New(conf)
+	if err != nil {
+		return nil, configError("etcd", "failed to create client: %s", err)
 	}
 
 	if prefix == "" {
@@ -246,8 +875,34 @@ func NewEtcdCSBackend(endpoints string, cafile, certfile, keyfile string, prefix
 		ctx: context.Background(),
 	}
 	if err := e.probe(); err != nil {
-		panic(err)
+		cli.Close()
+		return nil, fmt.Errorf("etcd: failed to reach endpoints %s: %w", endpoints, err)
 	}
-	return e
+	return e, nil
 
 }
+
+func (e *etcdStorage) wrapError(err *error, op string, path string) {
+	if wrapStorageError(err, "etcd", op, path, etcdErrorKind) {
+		logStorageError(e.logger, *err)
+	}
+}
+
+func etcdErrorKind(err error) error {
+	if errors.Is(err, ErrNotExist) {
+		return ErrObjectNotFound
+	}
+	switch status.Code(err) {
+	case codes.NotFound:
+		return ErrObjectNotFound
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return ErrPermissionDenied
+	case codes.ResourceExhausted:
+		return ErrThrottled
+	case codes.FailedPrecondition:
+		return ErrPreconditionFailed
+	case codes.Unavailable:
+		return ErrUnavailable
+	}
+	return nil
+}
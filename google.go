@@ -18,11 +18,24 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"iter"
+	"log/slog"
+	"net/http"
+	"os"
 	pathutil "path"
+	"strconv"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 )
 
 // GoogleCSBackend is a storage backend for Google Cloud Storage
@@ -30,15 +43,33 @@ type GoogleCSBackend struct {
 	Prefix  string
 	Client  *storage.BucketHandle
 	Context context.Context
+	logger  *slog.Logger
 }
 
-// NewGoogleCSBackend creates a new instance of GoogleCSBackend
+// NewGoogleCSBackend creates a new instance of GoogleCSBackend.
+// It panics if the backend cannot be created; NewGoogleCSBackendE returns the error instead.
 func NewGoogleCSBackend(bucket string, prefix string) *GoogleCSBackend {
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
+	b, err := NewGoogleCSBackendE(bucket, prefix)
 	if err != nil {
 		panic(err)
 	}
+	return b
+}
+
+// NewGoogleCSBackendE creates a new instance of GoogleCSBackend, using the application default credentials
+func NewGoogleCSBackendE(bucket string, prefix string) (*GoogleCSBackend, error) {
+	if bucket == "" {
+		return nil, configError("google", "missing bucket")
+	}
+	ctx := context.Background()
+	httpClient, err := googleCSHTTPClient(ctx)
+	if err != nil {
+		return nil, configError("google", "failed to create client: %s", err)
+	}
+	client, err := storage.NewClient(ctx, option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, configError("google", "failed to create client: %s", err)
+	}
 	bucketHandle := client.Bucket(bucket)
 	prefix = cleanPrefix(prefix)
 	b := &GoogleCSBackend{
@@ -46,17 +77,52 @@ func NewGoogleCSBackend(bucket string, prefix string) *GoogleCSBackend {
 		Client:  bucketHandle,
 		Context: ctx,
 	}
-	return b
+	return b, nil
+}
+
+// googleCSHTTPClient returns the HTTP client of the Google Cloud Storage client, which propagates the trace
+// context of the requests, and authenticates them with the application default credentials unless they are
+// sent to the emulator of STORAGE_EMULATOR_HOST
+func googleCSHTTPClient(ctx context.Context) (*http.Client, error) {
+	opts := []option.ClientOption{option.WithScopes(storage.ScopeFullControl, "https://www.googleapis.com/auth/cloud-platform")}
+	if os.Getenv("STORAGE_EMULATOR_HOST") != "" {
+		opts = []option.ClientOption{option.WithoutAuthentication()}
+	}
+	transport, err := htransport.NewTransport(ctx, tracingTransport(http.DefaultTransport), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// SetLogger sets the logger of the failed operations on Google Cloud Storage. Nothing is logged without a logger.
+func (b *GoogleCSBackend) SetLogger(logger *slog.Logger) {
+	b.logger = logger
+}
+
+// Capabilities returns the optional features supported by Google Cloud Storage
+func (b GoogleCSBackend) Capabilities() CapabilitySet {
+	return NewCapabilitySet(
+		CapabilityContext, CapabilityStream, CapabilityRange, CapabilityMetadata,
+		CapabilityStat, CapabilityHierarchical, CapabilityPaging, CapabilityBatchDelete,
+		CapabilityCopy, CapabilityPresign, CapabilityConditional, CapabilityVersioning,
+	)
 }
 
 // ListObjects lists all objects in Google Cloud Storage bucket, at prefix
 func (b GoogleCSBackend) ListObjects(prefix string) ([]Object, error) {
+	return b.ListObjectsContext(b.Context, prefix)
+}
+
+// ListObjectsContext lists all objects in Google Cloud Storage bucket, at prefix, using ctx for the requests
+func (b GoogleCSBackend) ListObjectsContext(ctx context.Context, prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
 	var objects []Object
 	prefix = pathutil.Join(b.Prefix, prefix)
 	listQuery := &storage.Query{
 		Prefix: prefix,
 	}
-	it := b.Client.Objects(b.Context, listQuery)
+	it := b.Client.Objects(ctx, listQuery)
 	for {
 		attrs, err := it.Next()
 		if err == iterator.Done {
@@ -69,27 +135,107 @@ func (b GoogleCSBackend) ListObjects(prefix string) ([]Object, error) {
 		if objectPathIsInvalid(path) {
 			continue
 		}
-		object := Object{
-			Path:         path,
-			Content:      []byte{},
-			LastModified: attrs.Updated,
-		}
+		object := objectWithInfo(googleCSObjectInfo(path, attrs), []byte{})
 		objects = append(objects, object)
 	}
 	return objects, nil
 }
 
+// ListObjectsPage lists a page of the objects in Google Cloud Storage bucket, at prefix.
+// The token is the page token returned by Google Cloud Storage for the next page.
+func (b GoogleCSBackend) ListObjectsPage(prefix string, token string, limit int) (_ ObjectPage, err error) {
+	defer b.wrapError(&err, "ListObjectsPage", prefix)
+	var page ObjectPage
+	prefix = listPrefix(b.Prefix, prefix)
+	listQuery := &storage.Query{
+		Prefix:    prefix,
+		Delimiter: "/",
+	}
+	var attrsPage []*storage.ObjectAttrs
+	pager := iterator.NewPager(b.Client.Objects(b.Context, listQuery), pageSize(limit), token)
+	page.NextToken, err = pager.NextPage(&attrsPage)
+	if err != nil {
+		return page, err
+	}
+	for _, attrs := range attrsPage {
+		path := strings.TrimPrefix(attrs.Name, prefix)
+		if attrs.Prefix != "" || objectPathIsInvalid(path) {
+			continue
+		}
+		page.Objects = append(page.Objects, objectWithInfo(googleCSObjectInfo(path, attrs), []byte{}))
+	}
+	return page, nil
+}
+
+// ListObjectsIter iterates over the objects in Google Cloud Storage bucket, at prefix
+func (b GoogleCSBackend) ListObjectsIter(prefix string) iter.Seq2[Object, error] {
+	return listObjectsIter(b, prefix)
+}
+
+// ListObjectsWithOptions lists the objects and common prefixes below prefix in Google Cloud Storage bucket
+func (b GoogleCSBackend) ListObjectsWithOptions(prefix string, options ListObjectsOptions) (_ ListObjectsResult, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
+	var result ListObjectsResult
+	prefix = listPrefix(b.Prefix, prefix)
+	listQuery := &storage.Query{
+		Prefix:    prefix,
+		Delimiter: options.nativeDelimiter(),
+	}
+	it := b.Client.Objects(b.Context, listQuery)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		if attrs.Prefix != "" {
+			result.CommonPrefixes = append(result.CommonPrefixes, strings.TrimPrefix(attrs.Prefix, prefix))
+			continue
+		}
+		path := strings.TrimPrefix(attrs.Name, prefix)
+		if objectPathIsInvalid(path) {
+			continue
+		}
+		object := objectWithInfo(googleCSObjectInfo(path, attrs), []byte{})
+		result.Objects = append(result.Objects, object)
+	}
+	return result, nil
+}
+
 // GetObject retrieves an object from Google Cloud Storage bucket, at prefix
 func (b GoogleCSBackend) GetObject(path string) (Object, error) {
+	return b.GetObjectContext(b.Context, path)
+}
+
+// GetObjectContext retrieves an object from Google Cloud Storage bucket, at prefix, using ctx for the requests
+func (b GoogleCSBackend) GetObjectContext(ctx context.Context, path string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObject", path)
+	return b.getObject(ctx, b.Client.Object(pathutil.Join(b.Prefix, path)), path)
+}
+
+// GetObjectVersion retrieves a generation of an object from Google Cloud Storage bucket, at prefix
+func (b GoogleCSBackend) GetObjectVersion(path string, versionID string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObjectVersion", path)
+	generation, err := strconv.ParseInt(versionID, 10, 64)
+	if err != nil {
+		return Object{Path: path}, fmt.Errorf("invalid generation %q", versionID)
+	}
+	return b.getObject(b.Context, b.Client.Object(pathutil.Join(b.Prefix, path)).Generation(generation), path)
+}
+
+// getObject retrieves the object of objectHandle, reading the generation it had when its attributes were retrieved
+func (b GoogleCSBackend) getObject(ctx context.Context, objectHandle *storage.ObjectHandle, path string) (Object, error) {
 	var object Object
 	object.Path = path
-	objectHandle := b.Client.Object(pathutil.Join(b.Prefix, path))
-	attrs, err := objectHandle.Attrs(b.Context)
+	attrs, err := objectHandle.Attrs(ctx)
 	if err != nil {
 		return object, err
 	}
-	object.LastModified = attrs.Updated
-	rc, err := objectHandle.NewReader(b.Context)
+	object = objectWithInfo(googleCSObjectInfo(path, attrs), nil)
+	object.Meta = Metadata{Name: path, Version: strconv.FormatInt(attrs.Generation, 10)}
+	rc, err := objectHandle.Generation(attrs.Generation).NewReader(ctx)
 	if err != nil {
 		return object, err
 	}
@@ -104,8 +250,65 @@ func (b GoogleCSBackend) GetObject(path string) (Object, error) {
 
 // PutObject uploads an object to Google Cloud Storage bucket, at prefix
 func (b GoogleCSBackend) PutObject(path string, content []byte) error {
+	return b.PutObjectContext(b.Context, path, content)
+}
+
+// PutObjectContext uploads an object to Google Cloud Storage bucket, at prefix, using ctx for the upload
+func (b GoogleCSBackend) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	defer b.wrapError(&err, "PutObject", path)
+	wc := b.Client.Object(pathutil.Join(b.Prefix, path)).NewWriter(ctx)
+	_, err = wc.Write(content)
+	if err != nil {
+		return err
+	}
+	err = wc.Close()
+	return err
+}
+
+// PutObjectWithOptions uploads an object to Google Cloud Storage bucket, at prefix, along with its content type,
+// storage class and user metadata
+func (b GoogleCSBackend) PutObjectWithOptions(path string, content []byte, options PutObjectOptions) (err error) {
+	defer b.wrapError(&err, "PutObjectWithOptions", path)
 	wc := b.Client.Object(pathutil.Join(b.Prefix, path)).NewWriter(b.Context)
-	_, err := wc.Write(content)
+	wc.ContentType = options.ContentType
+	wc.StorageClass = options.StorageClass
+	wc.Metadata = options.UserMetadata
+	_, err = wc.Write(content)
+	if err != nil {
+		return err
+	}
+	err = wc.Close()
+	return err
+}
+
+// PutObjectIf uploads an object to Google Cloud Storage bucket, at prefix, if condition is met.
+// An ETag condition is checked against the current attributes of the object, and the upload is then
+// made conditional on the generation those attributes belong to.
+func (b GoogleCSBackend) PutObjectIf(path string, content []byte, condition Condition) (err error) {
+	defer b.wrapError(&err, "PutObjectIf", path)
+	err = condition.validate()
+	if err != nil {
+		return err
+	}
+	objectHandle := b.Client.Object(pathutil.Join(b.Prefix, path))
+	switch {
+	case condition.IfNotExists:
+		objectHandle = objectHandle.If(storage.Conditions{DoesNotExist: true})
+	case condition.IfMatch != "":
+		attrs, err := objectHandle.Attrs(b.Context)
+		if err == storage.ErrObjectNotExist {
+			return ErrPreconditionFailed
+		}
+		if err != nil {
+			return err
+		}
+		if attrs.Etag != condition.IfMatch {
+			return ErrPreconditionFailed
+		}
+		objectHandle = objectHandle.If(storage.Conditions{GenerationMatch: attrs.Generation})
+	}
+	wc := objectHandle.NewWriter(b.Context)
+	_, err = wc.Write(content)
 	if err != nil {
 		return err
 	}
@@ -113,8 +316,180 @@ func (b GoogleCSBackend) PutObject(path string, content []byte) error {
 	return err
 }
 
+// GetObjectReader opens an object in Google Cloud Storage bucket, at prefix, for reading.
+// The reader is pinned to the generation whose attributes are returned.
+func (b GoogleCSBackend) GetObjectReader(path string) (_ io.ReadCloser, _ ObjectInfo, err error) {
+	defer b.wrapError(&err, "GetObjectReader", path)
+	info := ObjectInfo{Path: path}
+	objectHandle := b.Client.Object(pathutil.Join(b.Prefix, path))
+	attrs, err := objectHandle.Attrs(b.Context)
+	if err != nil {
+		return nil, info, err
+	}
+	info = googleCSObjectInfo(path, attrs)
+	rc, err := objectHandle.Generation(attrs.Generation).NewReader(b.Context)
+	if err != nil {
+		return nil, info, err
+	}
+	return rc, info, nil
+}
+
+// GetObjectRange reads part of an object from Google Cloud Storage bucket, at prefix, with a range reader
+func (b GoogleCSBackend) GetObjectRange(path string, offset int64, length int64) (_ []byte, err error) {
+	defer b.wrapError(&err, "GetObjectRange", path)
+	return getObjectRange(offset, length, googleCSErrorKind, func(offset int64, length int64) ([]byte, error) {
+		rc, err := b.Client.Object(pathutil.Join(b.Prefix, path)).NewRangeReader(b.Context, offset, length)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	})
+}
+
+// PutObjectStream uploads an object to Google Cloud Storage bucket, at prefix, from a stream
+func (b GoogleCSBackend) PutObjectStream(path string, content io.Reader, size int64) (err error) {
+	defer b.wrapError(&err, "PutObjectStream", path)
+	ctx, cancel := context.WithCancel(b.Context)
+	defer cancel()
+	wc := b.Client.Object(pathutil.Join(b.Prefix, path)).NewWriter(ctx)
+	_, err = io.Copy(wc, content)
+	if err != nil {
+		// cancelling the context aborts the upload instead of committing partial content
+		return err
+	}
+	return wc.Close()
+}
+
+// StatObject retrieves the attributes of an object in Google Cloud Storage bucket, at prefix
+func (b GoogleCSBackend) StatObject(path string) (_ ObjectInfo, err error) {
+	defer b.wrapError(&err, "StatObject", path)
+	attrs, err := b.Client.Object(pathutil.Join(b.Prefix, path)).Attrs(b.Context)
+	if err != nil {
+		return ObjectInfo{Path: path}, err
+	}
+	return googleCSObjectInfo(path, attrs), nil
+}
+
 // DeleteObject removes an object from Google Cloud Storage bucket, at prefix
 func (b GoogleCSBackend) DeleteObject(path string) error {
-	err := b.Client.Object(pathutil.Join(b.Prefix, path)).Delete(b.Context)
+	return b.DeleteObjectContext(b.Context, path)
+}
+
+// DeleteObjectContext removes an object from Google Cloud Storage bucket, at prefix, using ctx for the request
+func (b GoogleCSBackend) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	defer b.wrapError(&err, "DeleteObject", path)
+	err = b.Client.Object(pathutil.Join(b.Prefix, path)).Delete(ctx)
+	return err
+}
+
+// DeleteObjects removes objects from Google Cloud Storage bucket, at prefix. The Go client does not support
+// batch requests, so up to maxParallelDeletes objects are deleted at a time.
+func (b GoogleCSBackend) DeleteObjects(paths []string) ([]DeleteResult, error) {
+	return deleteObjectsInParallel(paths, b.DeleteObject), nil
+}
+
+// CopyObject copies an object within Google Cloud Storage bucket, at prefix, without downloading it
+func (b GoogleCSBackend) CopyObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "CopyObject", src)
+	srcHandle := b.Client.Object(pathutil.Join(b.Prefix, src))
+	_, err = b.Client.Object(pathutil.Join(b.Prefix, dst)).CopierFrom(srcHandle).Run(b.Context)
 	return err
 }
+
+// MoveObject copies an object within Google Cloud Storage bucket, at prefix, and deletes the original
+func (b GoogleCSBackend) MoveObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "MoveObject", src)
+	return moveObject(src, dst, b.CopyObject, b.DeleteObject)
+}
+
+// PresignGet generates a signed URL to download an object from Google Cloud Storage bucket, at prefix, until ttl expires
+func (b GoogleCSBackend) PresignGet(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignGet", path)
+	return b.signedURL(path, http.MethodGet, ttl)
+}
+
+// PresignPut generates a signed URL to upload an object to Google Cloud Storage bucket, at prefix, until ttl expires
+func (b GoogleCSBackend) PresignPut(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignPut", path)
+	return b.signedURL(path, http.MethodPut, ttl)
+}
+
+// signedURL generates a V4 signed URL, using the private key of the service account of the client if it has one,
+// or the IAM signBlob API otherwise
+func (b GoogleCSBackend) signedURL(path string, method string, ttl time.Duration) (string, error) {
+	if err := validatePresignTTL(ttl); err != nil {
+		return "", err
+	}
+	return b.Client.SignedURL(pathutil.Join(b.Prefix, path), &storage.SignedURLOptions{
+		Method:  method,
+		Expires: time.Now().Add(ttl),
+		Scheme:  storage.SigningSchemeV4,
+	})
+}
+
+// ListObjectVersions lists all generations of the objects in Google Cloud Storage bucket, at prefix.
+// Noncurrent generations are only kept by buckets with object versioning enabled.
+func (b GoogleCSBackend) ListObjectVersions(prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjectVersions", prefix)
+	var objects []Object
+	prefix = pathutil.Join(b.Prefix, prefix)
+	it := b.Client.Objects(b.Context, &storage.Query{Prefix: prefix, Versions: true})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return objects, err
+		}
+		path := removePrefixFromObjectPath(prefix, attrs.Name)
+		if objectPathIsInvalid(path) {
+			continue
+		}
+		object := objectWithInfo(googleCSObjectInfo(path, attrs), []byte{})
+		object.Meta = Metadata{Name: path, Version: strconv.FormatInt(attrs.Generation, 10)}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// DeleteObjectVersion permanently removes a generation of an object from Google Cloud Storage bucket, at prefix
+func (b GoogleCSBackend) DeleteObjectVersion(path string, versionID string) (err error) {
+	defer b.wrapError(&err, "DeleteObjectVersion", path)
+	generation, err := strconv.ParseInt(versionID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid generation %q", versionID)
+	}
+	return b.Client.Object(pathutil.Join(b.Prefix, path)).Generation(generation).Delete(b.Context)
+}
+
+// googleCSObjectInfo builds an ObjectInfo from the attributes of an object
+func googleCSObjectInfo(path string, attrs *storage.ObjectAttrs) ObjectInfo {
+	return ObjectInfo{
+		Path:         path,
+		Size:         attrs.Size,
+		LastModified: attrs.Updated,
+		ETag:         attrs.Etag,
+		ContentType:  attrs.ContentType,
+		StorageClass: attrs.StorageClass,
+		UserMetadata: lowerCaseKeys(attrs.Metadata),
+	}
+}
+
+func (b GoogleCSBackend) wrapError(err *error, op string, path string) {
+	if wrapStorageError(err, "GoogleCS", op, path, googleCSErrorKind) {
+		logStorageError(b.logger, *err)
+	}
+}
+
+func googleCSErrorKind(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, storage.ErrBucketNotExist) {
+		return ErrObjectNotFound
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return kindFromStatusCode(apiErr.Code)
+	}
+	return nil
+}
@@ -17,31 +17,83 @@ limitations under the License.
 package storage
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"iter"
+	"log/slog"
+	"mime"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	pathutil "path"
 	"path/filepath"
 )
 
+// localLockTimeout is the time after which the lock taken by a conditional write is considered abandoned
+const localLockTimeout = 30 * time.Second
+
 // LocalFilesystemBackend is a storage backend for local filesystem storage
 type LocalFilesystemBackend struct {
 	RootDirectory string
+	logger        *slog.Logger
 }
 
-// NewLocalFilesystemBackend creates a new instance of LocalFilesystemBackend
+// NewLocalFilesystemBackend creates a new instance of LocalFilesystemBackend.
+// It panics if the backend cannot be created; NewLocalFilesystemBackendE returns the error instead.
 func NewLocalFilesystemBackend(rootDirectory string) *LocalFilesystemBackend {
-	absPath, err := filepath.Abs(rootDirectory)
+	b, err := NewLocalFilesystemBackendE(rootDirectory)
 	if err != nil {
 		panic(err)
 	}
-	b := &LocalFilesystemBackend{RootDirectory: absPath}
 	return b
 }
 
+// NewLocalFilesystemBackendE creates a new instance of LocalFilesystemBackend. The root directory
+// is created on the first write if it does not exist, but must not be a file.
+func NewLocalFilesystemBackendE(rootDirectory string) (*LocalFilesystemBackend, error) {
+	absPath, err := filepath.Abs(rootDirectory)
+	if err != nil {
+		return nil, configError("local", "invalid root directory %q: %s", rootDirectory, err)
+	}
+	if info, err := os.Stat(absPath); err == nil && !info.IsDir() {
+		return nil, configError("local", "root directory %q is not a directory", absPath)
+	}
+	b := &LocalFilesystemBackend{RootDirectory: absPath}
+	return b, nil
+}
+
+// SetLogger sets the logger of the failed operations on the local filesystem. Nothing is logged without a logger.
+func (b *LocalFilesystemBackend) SetLogger(logger *slog.Logger) {
+	b.logger = logger
+}
+
+// Capabilities returns the optional features supported by the local filesystem
+func (b LocalFilesystemBackend) Capabilities() CapabilitySet {
+	return NewCapabilitySet(
+		CapabilityContext, CapabilityStream, CapabilityRange, CapabilityStat,
+		CapabilityHierarchical, CapabilityPaging, CapabilityBatchDelete, CapabilityCopy,
+		CapabilityConditional,
+	)
+}
+
 // ListObjects lists all objects in root directory (depth 1)
 func (b LocalFilesystemBackend) ListObjects(prefix string) ([]Object, error) {
+	return b.ListObjectsContext(context.Background(), prefix)
+}
+
+// ListObjectsContext lists all objects in root directory (depth 1), unless ctx is done
+func (b LocalFilesystemBackend) ListObjectsContext(ctx context.Context, prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
 	var objects []Object
+	if err := ctx.Err(); err != nil {
+		return objects, err
+	}
 	files, err := ioutil.ReadDir(pathutil.Join(b.RootDirectory, prefix))
 	if err != nil {
 		if os.IsNotExist(err) { // OK if the directory doesnt exist yet
@@ -50,37 +102,452 @@ func (b LocalFilesystemBackend) ListObjects(prefix string) ([]Object, error) {
 		return objects, err
 	}
 	for _, f := range files {
-		if f.IsDir() {
+		if f.IsDir() || isLocalWorkFile(f.Name()) {
 			continue
 		}
-		object := Object{Path: f.Name(), Content: []byte{}, LastModified: f.ModTime()}
+		object := objectWithInfo(localObjectInfo(f.Name(), f), []byte{})
 		objects = append(objects, object)
 	}
 	return objects, nil
 }
 
+// ListObjectsPage lists a page of the objects in root directory (depth 1), in the order of their names.
+// The token is the name of the last object of the previous page.
+func (b LocalFilesystemBackend) ListObjectsPage(prefix string, token string, limit int) (_ ObjectPage, err error) {
+	defer b.wrapError(&err, "ListObjectsPage", prefix)
+	var page ObjectPage
+	files, err := ioutil.ReadDir(pathutil.Join(b.RootDirectory, prefix))
+	if err != nil {
+		if os.IsNotExist(err) { // OK if the directory doesnt exist yet
+			err = nil
+		}
+		return page, err
+	}
+	limit = pageSize(limit)
+	for _, f := range files {
+		if f.IsDir() || isLocalWorkFile(f.Name()) || f.Name() <= token {
+			continue
+		}
+		if len(page.Objects) == limit {
+			page.NextToken = page.Objects[limit-1].Path
+			break
+		}
+		page.Objects = append(page.Objects, objectWithInfo(localObjectInfo(f.Name(), f), []byte{}))
+	}
+	return page, nil
+}
+
+// ListObjectsIter iterates over the objects in root directory (depth 1)
+func (b LocalFilesystemBackend) ListObjectsIter(prefix string) iter.Seq2[Object, error] {
+	return listObjectsIter(b, prefix)
+}
+
+// ListObjectsWithOptions lists the objects below prefix in root directory, walking its subdirectories
+func (b LocalFilesystemBackend) ListObjectsWithOptions(prefix string, options ListObjectsOptions) (_ ListObjectsResult, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
+	var objects []Object
+	root := pathutil.Join(b.RootDirectory, prefix)
+	err = filepath.Walk(root, func(fullpath string, f os.FileInfo, err error) error {
+		if err != nil {
+			if fullpath == root && os.IsNotExist(err) { // OK if the directory doesnt exist yet
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if f.IsDir() || isLocalWorkFile(f.Name()) {
+			return nil
+		}
+		path, err := filepath.Rel(root, fullpath)
+		if err != nil {
+			return err
+		}
+		path = filepath.ToSlash(path)
+		objects = append(objects, objectWithInfo(localObjectInfo(path, f), []byte{}))
+		return nil
+	})
+	if err != nil {
+		return ListObjectsResult{}, err
+	}
+	// list in the order of the keys of an object storage, where "a.txt" comes before "a/b.txt"
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Path < objects[j].Path
+	})
+	return groupObjects(objects, options), nil
+}
+
 // GetObject retrieves an object from root directory
 func (b LocalFilesystemBackend) GetObject(path string) (Object, error) {
+	return b.GetObjectContext(context.Background(), path)
+}
+
+// GetObjectContext retrieves an object from root directory, unless ctx is done
+func (b LocalFilesystemBackend) GetObjectContext(ctx context.Context, path string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObject", path)
 	var object Object
 	object.Path = path
+	if err := ctx.Err(); err != nil {
+		return object, err
+	}
 	fullpath := pathutil.Join(b.RootDirectory, path)
 	content, err := ioutil.ReadFile(fullpath)
 	if err != nil {
 		return object, err
 	}
 	object.Content = content
-	info, err := os.Stat(fullpath)
+	stat, err := os.Stat(fullpath)
 	if err != nil {
 		return object, err
 	}
-	object.LastModified = info.ModTime()
+	object = objectWithInfo(localObjectInfo(path, stat), content)
 	return object, err
 }
 
 // PutObject puts an object in root directory
 func (b LocalFilesystemBackend) PutObject(path string, content []byte) error {
+	return b.PutObjectContext(context.Background(), path, content)
+}
+
+// PutObjectContext puts an object in root directory, unless ctx is done
+func (b LocalFilesystemBackend) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	defer b.wrapError(&err, "PutObject", path)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fullpath := pathutil.Join(b.RootDirectory, path)
+	err = createFolder(pathutil.Dir(fullpath))
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(fullpath, content, 0644)
+	return err
+}
+
+// GetObjectReader opens an object in root directory for reading
+func (b LocalFilesystemBackend) GetObjectReader(path string) (_ io.ReadCloser, _ ObjectInfo, err error) {
+	defer b.wrapError(&err, "GetObjectReader", path)
+	info := ObjectInfo{Path: path}
+	file, err := os.Open(pathutil.Join(b.RootDirectory, path))
+	if err != nil {
+		return nil, info, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, info, err
+	}
+	return file, localObjectInfo(path, stat), nil
+}
+
+// GetObjectRange reads part of an object in root directory, without reading the rest of the file
+func (b LocalFilesystemBackend) GetObjectRange(path string, offset int64, length int64) (_ []byte, err error) {
+	defer b.wrapError(&err, "GetObjectRange", path)
+	if err := validateRangeOffset(offset); err != nil {
+		return nil, err
+	}
+	fullpath := pathutil.Join(b.RootDirectory, path)
+	file, err := os.Open(fullpath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: fullpath, Err: os.ErrNotExist}
+	}
+	if offset >= stat.Size() {
+		return []byte{}, nil
+	}
+	if length < 0 || length > stat.Size()-offset {
+		length = stat.Size() - offset
+	}
+	content := make([]byte, length)
+	n, err := file.ReadAt(content, offset)
+	if err == io.EOF {
+		err = nil
+	}
+	return content[:n], err
+}
+
+// PutObjectStream streams an object into root directory. The content is written to a temporary
+// file first, which is then renamed, so readers never observe a partially written object.
+func (b LocalFilesystemBackend) PutObjectStream(path string, content io.Reader, size int64) (err error) {
+	defer b.wrapError(&err, "PutObjectStream", path)
+	fullpath := pathutil.Join(b.RootDirectory, path)
+	err = createFolder(pathutil.Dir(fullpath))
+	if err != nil {
+		return err
+	}
+	tempPath, err := writeTempFile(fullpath, content, size)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+	return os.Rename(tempPath, fullpath)
+}
+
+// PutObjectIf puts an object in root directory if condition is met. An object which must not exist yet is
+// hard linked into place, which fails if the path is already taken; an object which must match an ETag is
+// compared and replaced while holding a lock file next to it. The replacement never gets the ETag of the
+// object it replaces, even when both have the same size and are written within the precision of the clock.
+func (b LocalFilesystemBackend) PutObjectIf(path string, content []byte, condition Condition) (err error) {
+	defer b.wrapError(&err, "PutObjectIf", path)
+	err = condition.validate()
+	if err != nil {
+		return err
+	}
+	fullpath := pathutil.Join(b.RootDirectory, path)
+	err = createFolder(pathutil.Dir(fullpath))
+	if err != nil {
+		return err
+	}
+	var replaced os.FileInfo
+	if condition.IfMatch != "" {
+		unlock, err := b.lockFile(fullpath)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		replaced, err = os.Stat(fullpath)
+		if os.IsNotExist(err) || (err == nil && localETag(replaced) != condition.IfMatch) {
+			return ErrPreconditionFailed
+		}
+		if err != nil {
+			return err
+		}
+	}
+	tempPath, err := writeTempFile(fullpath, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+	if replaced != nil {
+		err = changeETag(tempPath, replaced)
+		if err != nil {
+			return err
+		}
+	}
+	if condition.IfNotExists {
+		err = os.Link(tempPath, fullpath)
+		if os.IsExist(err) {
+			return ErrPreconditionFailed
+		}
+		return err
+	}
+	return os.Rename(tempPath, fullpath)
+}
+
+// StatObject retrieves the information of an object in root directory
+func (b LocalFilesystemBackend) StatObject(path string) (_ ObjectInfo, err error) {
+	defer b.wrapError(&err, "StatObject", path)
+	info := ObjectInfo{Path: path}
+	fullpath := pathutil.Join(b.RootDirectory, path)
+	stat, err := os.Stat(fullpath)
+	if err != nil {
+		return info, err
+	}
+	if stat.IsDir() {
+		return info, &os.PathError{Op: "stat", Path: fullpath, Err: os.ErrNotExist}
+	}
+	return localObjectInfo(path, stat), nil
+}
+
+// DeleteObject removes an object from root directory
+func (b LocalFilesystemBackend) DeleteObject(path string) error {
+	return b.DeleteObjectContext(context.Background(), path)
+}
+
+// DeleteObjectContext removes an object from root directory, unless ctx is done
+func (b LocalFilesystemBackend) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	defer b.wrapError(&err, "DeleteObject", path)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	fullpath := pathutil.Join(b.RootDirectory, path)
-	folderPath := pathutil.Dir(fullpath)
+	err = os.Remove(fullpath)
+	return err
+}
+
+// DeleteObjects removes objects from root directory, removing up to maxParallelDeletes files at a time
+func (b LocalFilesystemBackend) DeleteObjects(paths []string) ([]DeleteResult, error) {
+	return deleteObjectsInParallel(paths, b.DeleteObject), nil
+}
+
+// CopyObject copies an object within root directory. The copy is written to a temporary file
+// first, which is then renamed, so readers never observe a partially copied object.
+func (b LocalFilesystemBackend) CopyObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "CopyObject", src)
+	srcpath := pathutil.Join(b.RootDirectory, src)
+	file, err := os.Open(srcpath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return &os.PathError{Op: "open", Path: srcpath, Err: os.ErrNotExist}
+	}
+	fullpath := pathutil.Join(b.RootDirectory, dst)
+	err = createFolder(pathutil.Dir(fullpath))
+	if err != nil {
+		return err
+	}
+	tempPath, err := writeTempFile(fullpath, file, -1)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+	return os.Rename(tempPath, fullpath)
+}
+
+// MoveObject renames an object within root directory, atomically replacing any object at dst
+func (b LocalFilesystemBackend) MoveObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "MoveObject", src)
+	srcpath := pathutil.Join(b.RootDirectory, src)
+	stat, err := os.Stat(srcpath)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return &os.PathError{Op: "rename", Path: srcpath, Err: os.ErrNotExist}
+	}
+	fullpath := pathutil.Join(b.RootDirectory, dst)
+	err = createFolder(pathutil.Dir(fullpath))
+	if err != nil {
+		return err
+	}
+	return os.Rename(srcpath, fullpath)
+}
+
+// localObjectInfo builds an ObjectInfo from the file information of an object, guessing its content type from its extension
+func localObjectInfo(path string, stat os.FileInfo) ObjectInfo {
+	return ObjectInfo{
+		Path:         path,
+		Size:         stat.Size(),
+		LastModified: stat.ModTime(),
+		ETag:         localETag(stat),
+		ContentType:  mime.TypeByExtension(filepath.Ext(path)),
+	}
+}
+
+// localETag derives a weak entity tag from the modification time and size of a file,
+// which changes whenever the file is rewritten without having to read its content
+func localETag(stat os.FileInfo) string {
+	return fmt.Sprintf("%x-%x", stat.ModTime().UnixNano(), stat.Size())
+}
+
+// changeETag moves the modification time of the file at path past that of replaced if both files
+// would otherwise have the same ETag, as when a file is rewritten within the precision of the clock
+func changeETag(path string, replaced os.FileInfo) error {
+	stat, err := os.Stat(path)
+	if err != nil || localETag(stat) != localETag(replaced) {
+		return err
+	}
+	modTime := replaced.ModTime().Add(time.Second)
+	return os.Chtimes(path, modTime, modTime)
+}
+
+// isLocalWorkFile determines whether or not a file is a temporary file written by writeTempFile, or a lock file
+// taken by lockFile, next to an object. These files are not objects, and are left out of listings.
+func isLocalWorkFile(name string) bool {
+	if !strings.HasPrefix(name, ".") {
+		return false
+	}
+	if strings.HasSuffix(name, ".lock") {
+		return true
+	}
+	i := strings.LastIndex(name, ".tmp-")
+	if i < 0 {
+		return false
+	}
+	_, err := strconv.ParseUint(name[i+len(".tmp-"):], 10, 64)
+	return err == nil
+}
+
+// writeTempFile writes content to a new temporary file next to fullpath, and returns the path of the temporary file.
+// The temporary file is removed if content cannot be read, or is not size bytes long when size is not negative.
+func writeTempFile(fullpath string, content io.Reader, size int64) (string, error) {
+	file, err := ioutil.TempFile(pathutil.Dir(fullpath), "."+pathutil.Base(fullpath)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	written, err := io.Copy(file, content)
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("wrote %d bytes to %s, expected %d", written, fullpath, size)
+	}
+	if err == nil {
+		err = file.Chmod(0644)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// lockFile takes an exclusive lock on fullpath by creating a lock file next to it, waiting until other writers
+// release it. Lock files older than localLockTimeout are left over by crashed writers and are broken.
+// The returned function releases the lock.
+func (b LocalFilesystemBackend) lockFile(fullpath string) (func(), error) {
+	lockPath := pathutil.Join(pathutil.Dir(fullpath), "."+pathutil.Base(fullpath)+".lock")
+	for {
+		file, err := os.OpenFile(lockPath, os.O_RDONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if stat, err := os.Stat(lockPath); err == nil && time.Since(stat.ModTime()) > localLockTimeout {
+			if b.logger != nil {
+				b.logger.Warn("breaking abandoned lock", "backend", "LocalFilesystem", "lock", lockPath,
+					"age", time.Since(stat.ModTime()))
+			}
+			if err := breakLock(lockPath, stat); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// breakLock removes the abandoned lock file at lockPath, described by stale. Another writer may break the
+// same lock and take it again meanwhile, so the lock file is first renamed, and only removed if it is still
+// the abandoned one; otherwise it is given back to its writer, and breaking the lock fails with ErrUnavailable.
+func breakLock(lockPath string, stale os.FileInfo) error {
+	broken, err := ioutil.TempFile(pathutil.Dir(lockPath), strings.TrimSuffix(pathutil.Base(lockPath), ".lock")+".*.lock")
+	if err != nil {
+		return err
+	}
+	broken.Close()
+	defer os.Remove(broken.Name())
+	err = os.Rename(lockPath, broken.Name())
+	if os.IsNotExist(err) { // already broken by another writer
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	renamed, err := os.Stat(broken.Name())
+	if err == nil && os.SameFile(stale, renamed) && time.Since(renamed.ModTime()) > localLockTimeout {
+		return nil
+	}
+	os.Link(broken.Name(), lockPath) // fails if yet another writer took the lock, which then holds it
+	return fmt.Errorf("%w: lock %s was taken while breaking it", ErrUnavailable, lockPath)
+}
+
+// createFolder creates folderPath, and any missing parents, with permissions 774
+func createFolder(folderPath string) error {
 	_, err := os.Stat(folderPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -99,13 +566,21 @@ func (b LocalFilesystemBackend) PutObject(path string, content []byte) error {
 			return err
 		}
 	}
-	err = ioutil.WriteFile(fullpath, content, 0644)
-	return err
+	return nil
 }
 
-// DeleteObject removes an object from root directory
-func (b LocalFilesystemBackend) DeleteObject(path string) error {
-	fullpath := pathutil.Join(b.RootDirectory, path)
-	err := os.Remove(fullpath)
-	return err
+func (b LocalFilesystemBackend) wrapError(err *error, op string, path string) {
+	if wrapStorageError(err, "LocalFilesystem", op, path, localFilesystemErrorKind) {
+		logStorageError(b.logger, *err)
+	}
+}
+
+func localFilesystemErrorKind(err error) error {
+	switch {
+	case os.IsNotExist(err):
+		return ErrObjectNotFound
+	case os.IsPermission(err):
+		return ErrPermissionDenied
+	}
+	return nil
 }
@@ -17,8 +17,13 @@ limitations under the License.
 package storage
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	pathutil "path"
+	"sync"
 	"testing"
 	"time"
 
@@ -54,6 +59,167 @@ func (suite *LocalTestSuite) TestPutObjectWithNonExistentPath() {
 	suite.Nil(err)
 }
 
+func (suite *LocalTestSuite) TestGetObjectReader() {
+	_, _, err := suite.LocalFilesystemBackend.GetObjectReader("this-file-cannot-possibly-exist.tgz")
+	suite.NotNil(err, "cannot open objects with bad path")
+}
+
+func (suite *LocalTestSuite) TestPutObjectStreamWithWrongSize() {
+	err := suite.LocalFilesystemBackend.PutObjectStream("testdir/short.tgz", bytes.NewReader([]byte("short")), 10)
+	suite.NotNil(err, "cannot stream objects shorter than the given size")
+	_, err = suite.LocalFilesystemBackend.GetObject("testdir/short.tgz")
+	suite.NotNil(err, "partially streamed object is not stored")
+}
+
+func (suite *LocalTestSuite) TestStatObjectOfDirectory() {
+	err := suite.LocalFilesystemBackend.PutObject("statdir/test.tgz", []byte("test content"))
+	suite.Nil(err)
+	_, err = suite.LocalFilesystemBackend.StatObject("statdir")
+	suite.True(errors.Is(err, ErrObjectNotFound), "directories are not objects")
+}
+
+func (suite *LocalTestSuite) TestConcurrentPutObjectIf() {
+	path := "testdir/index-cache.yaml"
+	err := suite.LocalFilesystemBackend.PutObject(path, []byte("initial"))
+	suite.Nil(err, "no error putting initial object")
+	info, err := suite.LocalFilesystemBackend.StatObject(path)
+	suite.Nil(err, "no error getting info of initial object")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			content := []byte(fmt.Sprintf("written by writer %d", i))
+			errs[i] = suite.LocalFilesystemBackend.PutObjectIf(path, content, Condition{IfMatch: info.ETag})
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			suite.True(errors.Is(err, ErrPreconditionFailed), "concurrent writers fail with ErrPreconditionFailed")
+		}
+	}
+	suite.Equal(1, succeeded, "only one concurrent writer succeeds")
+}
+
+func (suite *LocalTestSuite) TestETagOfRewrite() {
+	backend := suite.LocalFilesystemBackend
+	path := "testdir/rewritten.yaml"
+	fullpath := pathutil.Join(backend.RootDirectory, path)
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	suite.Nil(backend.PutObject(path, []byte("version 1")))
+	suite.Nil(os.Chtimes(fullpath, modTime, modTime))
+	info, err := backend.StatObject(path)
+	suite.Nil(err)
+
+	suite.Nil(backend.PutObjectIf(path, []byte("version 2"), Condition{IfMatch: info.ETag}))
+	rewritten, err := backend.StatObject(path)
+	suite.Nil(err)
+	suite.NotEqual(info.ETag, rewritten.ETag, "ETag changes when the object is rewritten")
+
+	err = backend.PutObjectIf(path, []byte("version 3"), Condition{IfMatch: info.ETag})
+	suite.True(errors.Is(err, ErrPreconditionFailed), "stale ETag does not match the rewritten object")
+	object, err := backend.GetObject(path)
+	suite.Nil(err)
+	suite.Equal(rewritten.ETag, object.ETag, "ETag of an object is the same when got and stat")
+	objects, err := backend.ListObjects("testdir")
+	suite.Nil(err)
+	for _, listed := range objects {
+		if listed.Path == "rewritten.yaml" {
+			suite.Equal(rewritten.ETag, listed.ETag, "ETag of an object is the same when listed and stat")
+		}
+	}
+
+	// a replacement of the same size written within the precision of the clock gets another ETag
+	replacement := fullpath + ".replacement"
+	suite.Nil(os.WriteFile(replacement, []byte("version 4"), 0644))
+	defer os.Remove(replacement)
+	replaced, err := os.Stat(fullpath)
+	suite.Nil(err)
+	suite.Nil(os.Chtimes(replacement, replaced.ModTime(), replaced.ModTime()))
+	suite.Nil(changeETag(replacement, replaced))
+	stat, err := os.Stat(replacement)
+	suite.Nil(err)
+	suite.NotEqual(localETag(replaced), localETag(stat), "replacement does not get the ETag of the replaced object")
+}
+
+func (suite *LocalTestSuite) TestBreakLock() {
+	backend := suite.LocalFilesystemBackend
+	path := "locking/mychart-0.1.0.tgz"
+	suite.Nil(backend.PutObject(path, []byte("mychart")))
+	info, err := backend.StatObject(path)
+	suite.Nil(err)
+	lockPath := pathutil.Join(backend.RootDirectory, "locking/.mychart-0.1.0.tgz.lock")
+	abandoned := time.Now().Add(-2 * localLockTimeout)
+	suite.Nil(os.WriteFile(lockPath, nil, 0644))
+	suite.Nil(os.Chtimes(lockPath, abandoned, abandoned))
+
+	err = backend.PutObjectIf(path, []byte("mychart changed"), Condition{IfMatch: info.ETag})
+	suite.Nil(err, "abandoned lock is broken")
+	files, err := os.ReadDir(pathutil.Join(backend.RootDirectory, "locking"))
+	suite.Nil(err)
+	suite.Len(files, 1, "broken lock is removed")
+
+	// the lock is taken again by another writer between finding it abandoned and breaking it
+	suite.Nil(os.WriteFile(lockPath, nil, 0644))
+	suite.Nil(os.Chtimes(lockPath, abandoned, abandoned))
+	stale, err := os.Stat(lockPath)
+	suite.Nil(err)
+	suite.Nil(os.Remove(lockPath))
+	suite.Nil(os.WriteFile(lockPath, nil, 0644))
+	err = breakLock(lockPath, stale)
+	suite.True(errors.Is(err, ErrUnavailable), "lock taken while breaking it is not broken")
+	_, err = os.Stat(lockPath)
+	suite.Nil(err, "lock taken while breaking it is given back")
+	files, err = os.ReadDir(pathutil.Join(backend.RootDirectory, "locking"))
+	suite.Nil(err)
+	suite.Len(files, 2, "only the object and its lock are left")
+	suite.Nil(os.Remove(lockPath))
+}
+
+func (suite *LocalTestSuite) TestListObjectsDuringWrite() {
+	backend := suite.LocalFilesystemBackend
+	suite.Nil(backend.PutObject("writing/mychart-0.1.0.tgz", []byte("mychart")))
+	suite.Nil(os.WriteFile(pathutil.Join(backend.RootDirectory, "writing/.mychart-0.1.0.tgz.lock"), nil, 0644))
+
+	// the upload blocks after writing part of the object to its temporary file
+	reader, writer := io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- backend.PutObjectStream("writing/mychart-0.2.0.tgz", reader, -1)
+	}()
+	_, err := writer.Write([]byte("half"))
+	suite.Nil(err)
+	files, err := os.ReadDir(pathutil.Join(backend.RootDirectory, "writing"))
+	suite.Nil(err)
+	suite.Len(files, 3, "temporary file and lock file are written next to the object")
+
+	objects, err := backend.ListObjects("writing")
+	suite.Nil(err)
+	suite.Len(objects, 1, "temporary file and lock file are not listed")
+	page, err := backend.ListObjectsPage("writing", "", 10)
+	suite.Nil(err)
+	suite.Len(page.Objects, 1, "temporary file and lock file are not listed in pages")
+	result, err := backend.ListObjectsWithOptions("writing", ListObjectsOptions{})
+	suite.Nil(err)
+	suite.Len(result.Objects, 1, "temporary file and lock file are not listed recursively")
+	for _, object := range append(append(objects, page.Objects...), result.Objects...) {
+		suite.Equal("mychart-0.1.0.tgz", object.Path)
+	}
+
+	writer.Close()
+	suite.Nil(<-done)
+	objects, err = backend.ListObjects("writing")
+	suite.Nil(err)
+	suite.Len(objects, 2, "written object is listed")
+}
+
 func TestLocalStorageTestSuite(t *testing.T) {
 	suite.Run(t, new(LocalTestSuite))
 }
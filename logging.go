@@ -0,0 +1,213 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"time"
+)
+
+type (
+	// LoggingOptions configures a LoggingBackend
+	LoggingOptions struct {
+		// Logger receives the records of the operations, slog.Default() if nil
+		Logger *slog.Logger
+		// Level is the level of the records of successful operations, slog.LevelDebug if nil
+		Level slog.Leveler
+		// ErrorLevel is the level of the records of failed operations, slog.LevelError if nil
+		ErrorLevel slog.Leveler
+		// OutcomeLevels overrides the level of the records of failed operations by their Outcome, such as
+		// OutcomeNotFound. Missing objects and canceled operations are logged at slog.LevelDebug unless overridden.
+		OutcomeLevels map[string]slog.Leveler
+	}
+
+	// LoggingBackend is a Backend which logs the operations made on another Backend, with their path,
+	// duration, size and error. Credentials and the query strings of URLs, which hold the signatures of
+	// presigned URLs, are redacted from the paths and errors logged.
+	LoggingBackend struct {
+		backend        Backend
+		contextBackend ContextBackend
+		name           string
+		options        LoggingOptions
+	}
+)
+
+// defaultOutcomeLevels are the levels of the records of the failed operations which are usually expected
+var defaultOutcomeLevels = map[string]slog.Leveler{
+	OutcomeNotFound: slog.LevelDebug,
+	OutcomeCanceled: slog.LevelDebug,
+}
+
+// NewLoggingBackend creates a new instance of LoggingBackend logging the operations made on backend
+func NewLoggingBackend(backend Backend, options LoggingOptions) *LoggingBackend {
+	if options.Logger == nil {
+		options.Logger = slog.Default()
+	}
+	if options.Level == nil {
+		options.Level = slog.LevelDebug
+	}
+	if options.ErrorLevel == nil {
+		options.ErrorLevel = slog.LevelError
+	}
+	return &LoggingBackend{
+		backend:        backend,
+		contextBackend: NewContextBackend(backend),
+		name:           backendName(backend),
+		options:        options,
+	}
+}
+
+// Capabilities returns the optional features supported by the LoggingBackend, which accepts contexts
+// and lists content if the logged backend does
+func (l *LoggingBackend) Capabilities() CapabilitySet {
+	return CapabilitiesOf(l.backend)&NewCapabilitySet(CapabilityListContent) | NewCapabilitySet(CapabilityContext)
+}
+
+// ListObjects lists all objects at prefix, logging the operation and the number of objects listed
+func (l *LoggingBackend) ListObjects(prefix string) ([]Object, error) {
+	return l.ListObjectsContext(context.Background(), prefix)
+}
+
+// GetObject retrieves an object at path, logging the operation and the size of its content
+func (l *LoggingBackend) GetObject(path string) (Object, error) {
+	return l.GetObjectContext(context.Background(), path)
+}
+
+// PutObject uploads an object to path, logging the operation and the size of its content
+func (l *LoggingBackend) PutObject(path string, content []byte) error {
+	return l.PutObjectContext(context.Background(), path, content)
+}
+
+// DeleteObject removes an object at path, logging the operation
+func (l *LoggingBackend) DeleteObject(path string) error {
+	return l.DeleteObjectContext(context.Background(), path)
+}
+
+// ListObjectsContext lists all objects at prefix, logging the operation and the number of objects listed
+func (l *LoggingBackend) ListObjectsContext(ctx context.Context, prefix string) (objects []Object, err error) {
+	start := time.Now()
+	objects, err = l.contextBackend.ListObjectsContext(ctx, prefix)
+	l.log(ctx, "ListObjects", start, err, slog.String("prefix", redact(prefix)), slog.Int("objects", len(objects)))
+	return objects, err
+}
+
+// GetObjectContext retrieves an object at path, logging the operation and the size of its content
+func (l *LoggingBackend) GetObjectContext(ctx context.Context, path string) (object Object, err error) {
+	start := time.Now()
+	object, err = l.contextBackend.GetObjectContext(ctx, path)
+	l.log(ctx, "GetObject", start, err, slog.String("path", redact(path)), slog.Int("bytes", len(object.Content)))
+	return object, err
+}
+
+// PutObjectContext uploads an object to path, logging the operation and the size of its content
+func (l *LoggingBackend) PutObjectContext(ctx context.Context, path string, content []byte) error {
+	start := time.Now()
+	err := l.contextBackend.PutObjectContext(ctx, path, content)
+	l.log(ctx, "PutObject", start, err, slog.String("path", redact(path)), slog.Int("bytes", len(content)))
+	return err
+}
+
+// DeleteObjectContext removes an object at path, logging the operation
+func (l *LoggingBackend) DeleteObjectContext(ctx context.Context, path string) error {
+	start := time.Now()
+	err := l.contextBackend.DeleteObjectContext(ctx, path)
+	l.log(ctx, "DeleteObject", start, err, slog.String("path", redact(path)))
+	return err
+}
+
+// log logs an operation started at start which returned err, at the level of its outcome
+func (l *LoggingBackend) log(ctx context.Context, op string, start time.Time, err error, attrs ...slog.Attr) {
+	duration := time.Since(start)
+	level := l.level(err)
+	if !l.options.Logger.Enabled(ctx, level) {
+		return
+	}
+	attrs = append([]slog.Attr{
+		slog.String("backend", l.name),
+		slog.String("op", op),
+	}, attrs...)
+	attrs = append(attrs, slog.Duration("duration", duration), slog.String("outcome", Outcome(err)))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", redact(err.Error())))
+	}
+	l.options.Logger.LogAttrs(ctx, level, "storage operation", attrs...)
+}
+
+// level returns the level of the record of an operation which returned err
+func (l *LoggingBackend) level(err error) slog.Level {
+	if err == nil {
+		return l.options.Level.Level()
+	}
+	outcome := Outcome(err)
+	if level, ok := l.options.OutcomeLevels[outcome]; ok {
+		return level.Level()
+	}
+	if level, ok := defaultOutcomeLevels[outcome]; ok {
+		return level.Level()
+	}
+	return l.options.ErrorLevel.Level()
+}
+
+// logStorageError logs the failed operation described by a StorageError a backend just returned, to its
+// logger if it has one. Failures which are usually expected are logged at debug level, others as warnings.
+func logStorageError(logger *slog.Logger, err error) {
+	var storageErr *StorageError
+	if logger == nil || !errors.As(err, &storageErr) {
+		return
+	}
+	level := slog.LevelWarn
+	switch Outcome(err) {
+	case OutcomeNotFound, OutcomePreconditionFailed, OutcomeCanceled:
+		level = slog.LevelDebug
+	}
+	logger.LogAttrs(context.Background(), level, "storage operation failed",
+		slog.String("backend", storageErr.Backend),
+		slog.String("op", storageErr.Op),
+		slog.String("path", redact(storageErr.Path)),
+		slog.String("outcome", Outcome(err)),
+		slog.String("error", redact(storageErr.Err.Error())),
+	)
+}
+
+// redacted replaces the secrets removed by redact
+const redacted = "REDACTED"
+
+var (
+	// urlUserinfo matches the user and password of URLs
+	urlUserinfo = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^\s/?#@"']+@`)
+	// urlQuery matches the query string of URLs, which holds the signature and credentials of presigned URLs
+	urlQuery = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^\s?#"']*)\?[^\s#"']*`)
+	// authorizationHeader matches the value of Authorization headers
+	authorizationHeader = regexp.MustCompile(`(?i)(\bauthorization\s*[:=]\s*)[^\r\n"]*`)
+	// secretParameter matches credentials written as key=value or key: value
+	secretParameter = regexp.MustCompile(`(?i)(\b(?:x-amz-security-token|x-amz-credential|x-amz-signature|signature|credential|` +
+		`access[_-]?key(?:[_-]?id)?|secret(?:[_-]?access)?[_-]?key|password|passwd|token|sig)\s*[:=]\s*)("[^"]*"|[^\s,;&"']+)`)
+	// awsAccessKeyID matches the identifiers of AWS access keys
+	awsAccessKeyID = regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)
+)
+
+// redact replaces the credentials, and the query strings of URLs, found in s
+func redact(s string) string {
+	s = urlUserinfo.ReplaceAllString(s, "${1}"+redacted+"@")
+	s = urlQuery.ReplaceAllString(s, "${1}?"+redacted)
+	s = authorizationHeader.ReplaceAllString(s, "${1}"+redacted)
+	s = secretParameter.ReplaceAllString(s, "${1}"+redacted)
+	return awsAccessKeyID.ReplaceAllString(s, redacted)
+}
@@ -0,0 +1,201 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Outcomes of the operations recorded by an InstrumentedBackend
+const (
+	OutcomeSuccess            = "success"
+	OutcomeNotFound           = "not_found"
+	OutcomePermissionDenied   = "permission_denied"
+	OutcomeThrottled          = "throttled"
+	OutcomePreconditionFailed = "precondition_failed"
+	OutcomeUnavailable        = "unavailable"
+	OutcomeNotSupported       = "not_supported"
+	OutcomeCanceled           = "canceled"
+	OutcomeError              = "error"
+)
+
+type (
+	// MetricsSink receives the measurements of an InstrumentedBackend. backend is the label of the
+	// instrumented backend, such as "AmazonS3", and op the name of the Backend method, such as "GetObject".
+	// Implementations must be safe for concurrent use; the prometheus package exports them as Prometheus metrics.
+	MetricsSink interface {
+		// ObserveOperation records a completed operation, its outcome and how long it took
+		ObserveOperation(backend string, op string, outcome string, duration time.Duration)
+		// ObserveBytes records the size of the content read or written by a successful operation
+		ObserveBytes(backend string, op string, bytes int64)
+		// ObserveListing records the number of objects returned by a successful listing
+		ObserveListing(backend string, objects int)
+	}
+
+	// InstrumentedBackend is a Backend which reports the operations made on another Backend, their
+	// latencies, outcomes and the bytes they transfer, to a MetricsSink
+	InstrumentedBackend struct {
+		backend        Backend
+		contextBackend ContextBackend
+		name           string
+		sink           MetricsSink
+	}
+)
+
+// NewInstrumentedBackend creates a new instance of InstrumentedBackend reporting the operations made on
+// backend to sink, labelled with name, such as "AmazonS3". Backends wrapped in other wrappers cannot be told
+// apart from their type, so name should be the storage service behind them; if name is empty, the type of
+// backend is used, which is only the storage service when backend is not wrapped.
+func NewInstrumentedBackend(backend Backend, name string, sink MetricsSink) *InstrumentedBackend {
+	if name == "" {
+		name = backendName(backend)
+	}
+	return &InstrumentedBackend{
+		backend:        backend,
+		contextBackend: NewContextBackend(backend),
+		name:           name,
+		sink:           sink,
+	}
+}
+
+// Capabilities returns the optional features supported by the InstrumentedBackend, which accepts contexts
+// and lists content if the instrumented backend does
+func (i *InstrumentedBackend) Capabilities() CapabilitySet {
+	return CapabilitiesOf(i.backend)&NewCapabilitySet(CapabilityListContent) | NewCapabilitySet(CapabilityContext)
+}
+
+// ListObjects lists all objects at prefix, recording the operation and the number of objects listed
+func (i *InstrumentedBackend) ListObjects(prefix string) ([]Object, error) {
+	return i.ListObjectsContext(context.Background(), prefix)
+}
+
+// GetObject retrieves an object at path, recording the operation and the size of its content
+func (i *InstrumentedBackend) GetObject(path string) (Object, error) {
+	return i.GetObjectContext(context.Background(), path)
+}
+
+// PutObject uploads an object to path, recording the operation and the size of its content
+func (i *InstrumentedBackend) PutObject(path string, content []byte) error {
+	return i.PutObjectContext(context.Background(), path, content)
+}
+
+// DeleteObject removes an object at path, recording the operation
+func (i *InstrumentedBackend) DeleteObject(path string) error {
+	return i.DeleteObjectContext(context.Background(), path)
+}
+
+// ListObjectsContext lists all objects at prefix, recording the operation and the number of objects listed
+func (i *InstrumentedBackend) ListObjectsContext(ctx context.Context, prefix string) (objects []Object, err error) {
+	defer i.observe("ListObjects", time.Now(), &err)
+	objects, err = i.contextBackend.ListObjectsContext(ctx, prefix)
+	if err == nil {
+		i.sink.ObserveListing(i.name, len(objects))
+	}
+	return objects, err
+}
+
+// GetObjectContext retrieves an object at path, recording the operation and the size of its content
+func (i *InstrumentedBackend) GetObjectContext(ctx context.Context, path string) (object Object, err error) {
+	defer i.observe("GetObject", time.Now(), &err)
+	object, err = i.contextBackend.GetObjectContext(ctx, path)
+	if err == nil {
+		i.sink.ObserveBytes(i.name, "GetObject", int64(len(object.Content)))
+	}
+	return object, err
+}
+
+// PutObjectContext uploads an object to path, recording the operation and the size of its content
+func (i *InstrumentedBackend) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	defer i.observe("PutObject", time.Now(), &err)
+	err = i.contextBackend.PutObjectContext(ctx, path, content)
+	if err == nil {
+		i.sink.ObserveBytes(i.name, "PutObject", int64(len(content)))
+	}
+	return err
+}
+
+// DeleteObjectContext removes an object at path, recording the operation
+func (i *InstrumentedBackend) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	defer i.observe("DeleteObject", time.Now(), &err)
+	return i.contextBackend.DeleteObjectContext(ctx, path)
+}
+
+// observe records an operation started at start, once it returned *err
+func (i *InstrumentedBackend) observe(op string, start time.Time, err *error) {
+	i.sink.ObserveOperation(i.name, op, Outcome(*err), time.Since(start))
+}
+
+// outcomes maps the sentinel errors of this package to the outcome of the operations failing with them
+var outcomes = map[error]string{
+	ErrObjectNotFound:     OutcomeNotFound,
+	ErrPermissionDenied:   OutcomePermissionDenied,
+	ErrThrottled:          OutcomeThrottled,
+	ErrPreconditionFailed: OutcomePreconditionFailed,
+	ErrUnavailable:        OutcomeUnavailable,
+	ErrNotSupported:       OutcomeNotSupported,
+}
+
+// Outcome classifies the error returned by an operation into one of a few outcomes, suitable as a metric label
+func Outcome(err error) string {
+	if err == nil {
+		return OutcomeSuccess
+	}
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			return outcomes[kind]
+		}
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return OutcomeCanceled
+	}
+	return OutcomeError
+}
+
+// backendName returns the type of a backend, named as in the StorageErrors it returns
+func backendName(backend Backend) string {
+	switch backend.(type) {
+	case *AmazonS3Backend:
+		return "AmazonS3"
+	case *GoogleCSBackend:
+		return "GoogleCS"
+	case *MicrosoftBlobBackend:
+		return "MicrosoftBlob"
+	case *AlibabaCloudOSSBackend:
+		return "AlibabaCloudOSS"
+	case *TencentCloudCOSBackend:
+		return "TencentCloudCOS"
+	case *BaiduBOSBackend:
+		return "BaiduCloudBOS"
+	case *OpenstackOSBackend:
+		return "OpenStackOS"
+	case *OracleCSBackend:
+		return "OracleCS"
+	case *etcdStorage:
+		return "etcd"
+	case *LocalFilesystemBackend:
+		return "LocalFilesystem"
+	}
+	t := reflect.TypeOf(backend)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return strings.TrimSuffix(t.Name(), "Backend")
+}
@@ -0,0 +1,120 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// recordingSink is a MetricsSink remembering the measurements it receives
+type recordingSink struct {
+	mu         sync.Mutex
+	operations []string
+	bytes      map[string]int64
+	listings   []int
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{bytes: make(map[string]int64)}
+}
+
+func (s *recordingSink) ObserveOperation(backend string, op string, outcome string, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operations = append(s.operations, fmt.Sprintf("%s %s %s", backend, op, outcome))
+}
+
+func (s *recordingSink) ObserveBytes(backend string, op string, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytes[op] += bytes
+}
+
+func (s *recordingSink) ObserveListing(backend string, objects int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listings = append(s.listings, objects)
+}
+
+type MetricsTestSuite struct {
+	suite.Suite
+	TempDirectory string
+	Local         *LocalFilesystemBackend
+}
+
+func (suite *MetricsTestSuite) SetupTest() {
+	timestamp := time.Now().Format("20060102150405.000000")
+	suite.TempDirectory = fmt.Sprintf("../../.test/storage-metrics/%s", timestamp)
+	suite.Local = NewLocalFilesystemBackend(suite.TempDirectory)
+}
+
+func (suite *MetricsTestSuite) TearDownTest() {
+	os.RemoveAll(suite.TempDirectory)
+}
+
+func (suite *MetricsTestSuite) TestInstrumentedBackend() {
+	sink := newRecordingSink()
+	instrumented := NewInstrumentedBackend(suite.Local, "", sink)
+
+	suite.Nil(instrumented.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+	object, err := instrumented.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err, "no error getting object through instrumented backend")
+	suite.Equal([]byte("mychart"), object.Content)
+	_, err = instrumented.GetObject("missing.tgz")
+	suite.True(errors.Is(err, ErrObjectNotFound))
+	objects, err := instrumented.ListObjects("")
+	suite.Nil(err)
+	suite.Len(objects, 1)
+	suite.Nil(instrumented.DeleteObject("mychart-0.1.0.tgz"))
+
+	suite.Equal([]string{
+		"LocalFilesystem PutObject success",
+		"LocalFilesystem GetObject success",
+		"LocalFilesystem GetObject not_found",
+		"LocalFilesystem ListObjects success",
+		"LocalFilesystem DeleteObject success",
+	}, sink.operations, "operations are recorded with their backend and outcome")
+	suite.Equal(int64(len("mychart")), sink.bytes["PutObject"], "bytes written are recorded")
+	suite.Equal(int64(len("mychart")), sink.bytes["GetObject"], "bytes read are recorded")
+	suite.Equal([]int{1}, sink.listings, "listing sizes are recorded")
+
+	wrapped := NewInstrumentedBackend(NewRetryingBackend(NewCachingBackend(suite.Local, CachingOptions{}), RetryOptions{}), "LocalFilesystem", sink)
+	suite.Nil(wrapped.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+	suite.Equal("LocalFilesystem PutObject success", sink.operations[len(sink.operations)-1],
+		"operations on wrapped backends are labelled with the given name")
+	suite.Equal("Caching", NewInstrumentedBackend(NewCachingBackend(suite.Local, CachingOptions{}), "", sink).name,
+		"backends without a name are labelled with their type")
+}
+
+func (suite *MetricsTestSuite) TestOutcome() {
+	suite.Equal(OutcomeSuccess, Outcome(nil))
+	suite.Equal(OutcomeThrottled, Outcome(&StorageError{Backend: "test", Op: "test", Kind: ErrThrottled, Err: errors.New("429")}))
+	suite.Equal(OutcomeCanceled, Outcome(fmt.Errorf("list: %w", context.Canceled)))
+	suite.Equal(OutcomeError, Outcome(errors.New("unknown")))
+}
+
+func TestMetricsTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
@@ -17,9 +17,17 @@ limitations under the License.
 package storage
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"iter"
+	"log/slog"
+	"net/http"
 	pathutil "path"
+	"strings"
 	"time"
 
 	"os"
@@ -31,14 +39,39 @@ const (
 	maxChunkSize = 4 * 1024 * 1024
 )
 
+var errBlobNotExist = errors.New("Object does not exist.")
+
 // MicrosoftBlobBackend is a storage backend for Microsoft Azure Blob Storage
 type MicrosoftBlobBackend struct {
 	Prefix    string
 	Container *microsoft_storage.Container
+	logger    *slog.Logger
+	client    microsoft_storage.Client
+}
+
+// microsoftContextSender sends the requests of the Azure storage client, which does not accept a context,
+// with a context, so that they are aborted when it is done
+type microsoftContextSender struct {
+	ctx    context.Context
+	sender microsoft_storage.Sender
 }
 
-// NewMicrosoftBlobBackend creates a new instance of MicrosoftBlobBackend
+// NewMicrosoftBlobBackend creates a new instance of MicrosoftBlobBackend.
+// It panics if the backend cannot be created; NewMicrosoftBlobBackendE returns the error instead.
 func NewMicrosoftBlobBackend(container string, prefix string) *MicrosoftBlobBackend {
+	b, err := NewMicrosoftBlobBackendE(container, prefix)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// NewMicrosoftBlobBackendE creates a new instance of MicrosoftBlobBackend, using the account of the
+// AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_ACCESS_KEY environment variables
+func NewMicrosoftBlobBackendE(container string, prefix string) (*MicrosoftBlobBackend, error) {
+	if container == "" {
+		return nil, configError("microsoft", "missing container")
+	}
 
 	// From the Azure portal, get your storage account name and key and set environment variables.
 	accountName, accountKey := os.Getenv("AZURE_STORAGE_ACCOUNT"), os.Getenv("AZURE_STORAGE_ACCESS_KEY")
@@ -50,13 +83,14 @@ func NewMicrosoftBlobBackend(container string, prefix string) *MicrosoftBlobBack
 		apiVersion = microsoft_storage.DefaultAPIVersion
 	}
 	if len(accountName) == 0 || len(accountKey) == 0 {
-		panic("Either the AZURE_STORAGE_ACCOUNT or AZURE_STORAGE_ACCESS_KEY environment variable is not set")
+		return nil, configError("microsoft", "either the AZURE_STORAGE_ACCOUNT or AZURE_STORAGE_ACCESS_KEY environment variable is not set")
 	}
 
 	client, err := microsoft_storage.NewClient(accountName, accountKey, serviceBaseURL, apiVersion, true)
 	if err != nil {
-		panic(err)
+		return nil, configError("microsoft", "failed to create client: %s", err)
 	}
+	client.HTTPClient = &http.Client{Transport: tracingTransport(http.DefaultTransport)}
 
 	blobClient := client.GetBlobService()
 	containerRef := blobClient.GetContainerReference(container)
@@ -64,25 +98,51 @@ func NewMicrosoftBlobBackend(container string, prefix string) *MicrosoftBlobBack
 	b := &MicrosoftBlobBackend{
 		Prefix:    prefix,
 		Container: containerRef,
+		client:    client,
 	}
 
-	return b
+	return b, nil
+}
+
+// SetLogger sets the logger of the failed operations on Microsoft Azure Blob Storage. Nothing is logged without a logger.
+func (b *MicrosoftBlobBackend) SetLogger(logger *slog.Logger) {
+	b.logger = logger
+}
+
+// Capabilities returns the optional features supported by Microsoft Azure Blob Storage
+func (b MicrosoftBlobBackend) Capabilities() CapabilitySet {
+	return NewCapabilitySet(
+		CapabilityContext, CapabilityStream, CapabilityRange, CapabilityMetadata,
+		CapabilityStat, CapabilityHierarchical, CapabilityPaging, CapabilityBatchDelete,
+		CapabilityCopy, CapabilityPresign, CapabilityConditional,
+	)
 }
 
 // ListObjects lists all objects in Microsoft Azure Blob Storage container
 func (b MicrosoftBlobBackend) ListObjects(prefix string) ([]Object, error) {
+	return b.ListObjectsContext(context.Background(), prefix)
+}
+
+// ListObjectsContext lists all objects in Microsoft Azure Blob Storage container, aborting when ctx is done
+func (b MicrosoftBlobBackend) ListObjectsContext(ctx context.Context, prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
 	var objects []Object
 
 	if b.Container == nil {
 		return objects, errors.New("Unable to obtain a container reference.")
 	}
+	container := b.containerWithContext(ctx)
 
 	var params microsoft_storage.ListBlobsParameters
 	prefix = pathutil.Join(b.Prefix, prefix)
 	params.Prefix = prefix
+	params.Include = &microsoft_storage.IncludeBlobDataset{Metadata: true}
 
 	for {
-		response, err := b.Container.ListBlobs(params)
+		if err := ctx.Err(); err != nil {
+			return objects, err
+		}
+		response, err := container.ListBlobs(params)
 		if err != nil {
 			return objects, err
 		}
@@ -93,11 +153,7 @@ func (b MicrosoftBlobBackend) ListObjects(prefix string) ([]Object, error) {
 				continue
 			}
 
-			object := Object{
-				Path:         path,
-				Content:      []byte{},
-				LastModified: time.Time(blob.Properties.LastModified),
-			}
+			object := objectWithInfo(microsoftBlobObjectInfo(path, &blob), []byte{})
 
 			objects = append(objects, object)
 		}
@@ -112,8 +168,96 @@ func (b MicrosoftBlobBackend) ListObjects(prefix string) ([]Object, error) {
 	return objects, nil
 }
 
+// ListObjectsPage lists a page of the objects in Microsoft Azure Blob Storage container, at prefix.
+// The token is the marker returned by Azure for the next page.
+func (b MicrosoftBlobBackend) ListObjectsPage(prefix string, token string, limit int) (_ ObjectPage, err error) {
+	defer b.wrapError(&err, "ListObjectsPage", prefix)
+	var page ObjectPage
+
+	if b.Container == nil {
+		return page, errors.New("Unable to obtain a container reference.")
+	}
+
+	prefix = listPrefix(b.Prefix, prefix)
+	params := microsoft_storage.ListBlobsParameters{
+		Prefix:     prefix,
+		Delimiter:  "/",
+		Marker:     token,
+		MaxResults: uint(pageSize(limit)),
+		Include:    &microsoft_storage.IncludeBlobDataset{Metadata: true},
+	}
+	response, err := b.Container.ListBlobs(params)
+	if err != nil {
+		return page, err
+	}
+
+	for _, blob := range response.Blobs {
+		path := strings.TrimPrefix(blob.Name, prefix)
+		if objectPathIsInvalid(path) {
+			continue
+		}
+		page.Objects = append(page.Objects, objectWithInfo(microsoftBlobObjectInfo(path, &blob), []byte{}))
+	}
+	page.NextToken = response.NextMarker
+	return page, nil
+}
+
+// ListObjectsIter iterates over the objects in Microsoft Azure Blob Storage container, at prefix
+func (b MicrosoftBlobBackend) ListObjectsIter(prefix string) iter.Seq2[Object, error] {
+	return listObjectsIter(b, prefix)
+}
+
+// ListObjectsWithOptions lists the objects and common prefixes below prefix in Microsoft Azure Blob Storage container
+func (b MicrosoftBlobBackend) ListObjectsWithOptions(prefix string, options ListObjectsOptions) (_ ListObjectsResult, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
+	var result ListObjectsResult
+
+	if b.Container == nil {
+		return result, errors.New("Unable to obtain a container reference.")
+	}
+
+	prefix = listPrefix(b.Prefix, prefix)
+	params := microsoft_storage.ListBlobsParameters{
+		Prefix:    prefix,
+		Delimiter: options.nativeDelimiter(),
+		Include:   &microsoft_storage.IncludeBlobDataset{Metadata: true},
+	}
+
+	for {
+		response, err := b.Container.ListBlobs(params)
+		if err != nil {
+			return result, err
+		}
+
+		for _, blob := range response.Blobs {
+			path := strings.TrimPrefix(blob.Name, prefix)
+			if objectPathIsInvalid(path) {
+				continue
+			}
+			result.Objects = append(result.Objects, objectWithInfo(microsoftBlobObjectInfo(path, &blob), []byte{}))
+		}
+		for _, blobPrefix := range response.BlobPrefixes {
+			result.CommonPrefixes = append(result.CommonPrefixes, strings.TrimPrefix(blobPrefix, prefix))
+		}
+
+		if response.NextMarker == "" {
+			break
+		}
+
+		params.Marker = response.NextMarker
+	}
+
+	return result, nil
+}
+
 // GetObject retrieves an object from Microsoft Azure Blob Storage, at path
 func (b MicrosoftBlobBackend) GetObject(path string) (Object, error) {
+	return b.GetObjectContext(context.Background(), path)
+}
+
+// GetObjectContext retrieves an object from Microsoft Azure Blob Storage, at path, aborting when ctx is done
+func (b MicrosoftBlobBackend) GetObjectContext(ctx context.Context, path string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObject", path)
 	var object Object
 	object.Path = path
 
@@ -121,52 +265,138 @@ func (b MicrosoftBlobBackend) GetObject(path string) (Object, error) {
 		return object, errors.New("Unable to obtain a container reference.")
 	}
 
+	if err := ctx.Err(); err != nil {
+		return object, err
+	}
+
 	var content []byte
 
-	blobReference := b.Container.GetBlobReference(pathutil.Join(b.Prefix, path))
+	blobReference := b.containerWithContext(ctx).GetBlobReference(pathutil.Join(b.Prefix, path))
 	exists, err := blobReference.Exists()
 	if err != nil {
 		return object, err
 	}
 
 	if !exists {
-		return object, errors.New("Object does not exist.")
+		return object, errBlobNotExist
+	}
+
+	if err := ctx.Err(); err != nil {
+		return object, err
 	}
 
 	readCloser, err := blobReference.Get(nil)
 	if err != nil {
 		return object, err
 	}
+	defer readCloser.Close()
 
 	content, err = ioutil.ReadAll(readCloser)
 	if err != nil {
 		return object, err
 	}
 
-	object.Content = content
-	err = blobReference.GetProperties(nil)
-	object.LastModified = time.Time(blobReference.Properties.LastModified)
-	return object, nil
+	// Get fills in the blob properties and metadata from the response headers
+	return objectWithInfo(microsoftBlobObjectInfo(path, blobReference), content), nil
 }
 
 // PutObject uploads an object to Microsoft Azure Blob Storage container, at path
 func (b MicrosoftBlobBackend) PutObject(path string, content []byte) error {
+	return b.PutObjectContext(context.Background(), path, content)
+}
+
+// PutObjectContext uploads an object to Microsoft Azure Blob Storage container, at path, aborting when ctx is done
+func (b MicrosoftBlobBackend) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	defer b.wrapError(&err, "PutObject", path)
+	if b.Container == nil {
+		return errors.New("Unable to obtain a container reference.")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	blobReference := b.containerWithContext(ctx).GetBlobReference(pathutil.Join(b.Prefix, path))
+
+	err = blobReference.PutAppendBlob(nil)
+	if err == nil {
+		err = writeToBlob(ctx, content, blobReference)
+	}
+
+	return err
+}
+
+// PutObjectWithOptions uploads an object to Microsoft Azure Blob Storage container, at path, along with its
+// content type and user metadata. Access tiers are not supported by this client, so the storage class is ignored.
+func (b MicrosoftBlobBackend) PutObjectWithOptions(path string, content []byte, options PutObjectOptions) (err error) {
+	defer b.wrapError(&err, "PutObjectWithOptions", path)
 	if b.Container == nil {
 		return errors.New("Unable to obtain a container reference.")
 	}
 
 	blobReference := b.Container.GetBlobReference(pathutil.Join(b.Prefix, path))
+	blobReference.Properties.ContentType = options.ContentType
+	blobReference.Metadata = lowerCaseKeys(options.UserMetadata)
 
-	err := blobReference.PutAppendBlob(nil)
+	err = blobReference.PutAppendBlob(nil)
 	if err == nil {
-		err = writeToBlob(content, blobReference)
+		err = writeToBlob(context.Background(), content, blobReference)
 	}
 
 	return err
 }
 
-func writeToBlob(content []byte, blobRef *microsoft_storage.Blob) error {
+// PutObjectIf uploads an object to Microsoft Azure Blob Storage container, at path, if condition is met.
+// The object is written as a block blob in a single request, so that the ETag access condition
+// covers the whole content.
+func (b MicrosoftBlobBackend) PutObjectIf(path string, content []byte, condition Condition) (err error) {
+	defer b.wrapError(&err, "PutObjectIf", path)
+	if b.Container == nil {
+		return errors.New("Unable to obtain a container reference.")
+	}
+
+	err = condition.validate()
+	if err != nil {
+		return err
+	}
+
+	options := &microsoft_storage.PutBlobOptions{}
+	if condition.IfNotExists {
+		options.IfNoneMatch = "*"
+	}
+	if condition.IfMatch != "" {
+		options.IfMatch = fmt.Sprintf("%q", condition.IfMatch)
+	}
+
+	blobReference := b.Container.GetBlobReference(pathutil.Join(b.Prefix, path))
+	err = blobReference.CreateBlockBlobFromReader(bytes.NewReader(content), options)
+	return err
+}
+
+// containerWithContext returns a reference to the container whose requests are sent with ctx
+func (b MicrosoftBlobBackend) containerWithContext(ctx context.Context) *microsoft_storage.Container {
+	if b.client.Sender == nil || ctx.Done() == nil {
+		return b.Container
+	}
+	client := b.client
+	client.Sender = microsoftContextSender{ctx: ctx, sender: b.client.Sender}
+	blobClient := client.GetBlobService()
+	return blobClient.GetContainerReference(b.Container.Name)
+}
+
+// Send sends a request with the context of the sender. The cancel channel of the request is also set, as the
+// default sender of the client only stops waiting between its retries on this channel.
+func (s microsoftContextSender) Send(client *microsoft_storage.Client, req *http.Request) (*http.Response, error) {
+	req = req.WithContext(s.ctx)
+	req.Cancel = s.ctx.Done()
+	return s.sender.Send(client, req)
+}
+
+func writeToBlob(ctx context.Context, content []byte, blobRef *microsoft_storage.Blob) error {
 	for offset := 0; offset < len(content); offset += maxChunkSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		chunkSize := maxChunkSize
 		if offset+chunkSize > len(content) {
 			chunkSize = len(content) - offset
@@ -178,13 +408,218 @@ func writeToBlob(content []byte, blobRef *microsoft_storage.Blob) error {
 	return nil
 }
 
+// GetObjectReader opens an object in Microsoft Azure Blob Storage, at path, for reading
+func (b MicrosoftBlobBackend) GetObjectReader(path string) (_ io.ReadCloser, _ ObjectInfo, err error) {
+	defer b.wrapError(&err, "GetObjectReader", path)
+	info := ObjectInfo{Path: path}
+
+	if b.Container == nil {
+		return nil, info, errors.New("Unable to obtain a container reference.")
+	}
+
+	blobReference := b.Container.GetBlobReference(pathutil.Join(b.Prefix, path))
+	exists, err := blobReference.Exists()
+	if err != nil {
+		return nil, info, err
+	}
+
+	if !exists {
+		return nil, info, errBlobNotExist
+	}
+
+	readCloser, err := blobReference.Get(nil)
+	if err != nil {
+		return nil, info, err
+	}
+
+	// Get fills in the blob properties and metadata from the response headers
+	return readCloser, microsoftBlobObjectInfo(path, blobReference), nil
+}
+
+// GetObjectRange reads part of a blob from Microsoft Azure Blob Storage container, at prefix, with an HTTP range request.
+// The client cannot request the first byte alone, so the whole blob is requested then.
+func (b MicrosoftBlobBackend) GetObjectRange(path string, offset int64, length int64) (_ []byte, err error) {
+	defer b.wrapError(&err, "GetObjectRange", path)
+	if b.Container == nil {
+		return nil, errors.New("Unable to obtain a container reference.")
+	}
+	blobReference := b.Container.GetBlobReference(pathutil.Join(b.Prefix, path))
+	return getObjectRange(offset, length, microsoftBlobErrorKind, func(offset int64, length int64) ([]byte, error) {
+		blobRange := &microsoft_storage.BlobRange{Start: uint64(offset)}
+		if length > 0 {
+			blobRange.End = uint64(offset + length - 1)
+		}
+		readCloser, err := blobReference.GetRange(&microsoft_storage.GetBlobRangeOptions{Range: blobRange})
+		if err != nil {
+			return nil, err
+		}
+		defer readCloser.Close()
+		return io.ReadAll(readCloser)
+	})
+}
+
+// PutObjectStream uploads an object to Microsoft Azure Blob Storage container, at path, from a stream.
+// The content is read and appended to the blob in blocks of at most maxChunkSize bytes.
+func (b MicrosoftBlobBackend) PutObjectStream(path string, content io.Reader, size int64) (err error) {
+	defer b.wrapError(&err, "PutObjectStream", path)
+	if b.Container == nil {
+		return errors.New("Unable to obtain a container reference.")
+	}
+
+	blobReference := b.Container.GetBlobReference(pathutil.Join(b.Prefix, path))
+
+	err = blobReference.PutAppendBlob(nil)
+	if err == nil {
+		err = streamToBlob(content, blobReference)
+	}
+
+	return err
+}
+
+func streamToBlob(content io.Reader, blobRef *microsoft_storage.Blob) error {
+	chunk := make([]byte, maxChunkSize)
+	for {
+		n, err := io.ReadFull(content, chunk)
+		if n > 0 {
+			if err := blobRef.AppendBlock(chunk[:n], nil); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// StatObject retrieves the properties of an object in Microsoft Azure Blob Storage container, at path
+func (b MicrosoftBlobBackend) StatObject(path string) (_ ObjectInfo, err error) {
+	defer b.wrapError(&err, "StatObject", path)
+	info := ObjectInfo{Path: path}
+
+	if b.Container == nil {
+		return info, errors.New("Unable to obtain a container reference.")
+	}
+
+	blobReference := b.Container.GetBlobReference(pathutil.Join(b.Prefix, path))
+	err = blobReference.GetProperties(nil)
+	if err != nil {
+		return info, err
+	}
+
+	return microsoftBlobObjectInfo(path, blobReference), nil
+}
+
 // DeleteObject removes an object from Microsoft Azure Blob Storage container, at path
 func (b MicrosoftBlobBackend) DeleteObject(path string) error {
+	return b.DeleteObjectContext(context.Background(), path)
+}
+
+// DeleteObjectContext removes an object from Microsoft Azure Blob Storage container, at path, aborting when ctx is done
+func (b MicrosoftBlobBackend) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	defer b.wrapError(&err, "DeleteObject", path)
 	if b.Container == nil {
 		return errors.New("Unable to obtain a container reference.")
 	}
 
-	blobReference := b.Container.GetBlobReference(pathutil.Join(b.Prefix, path))
-	_, err := blobReference.DeleteIfExists(nil)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	blobReference := b.containerWithContext(ctx).GetBlobReference(pathutil.Join(b.Prefix, path))
+	_, err = blobReference.DeleteIfExists(nil)
 	return err
 }
+
+// DeleteObjects removes objects from Microsoft Azure Blob Storage container, at path, deleting up to
+// maxParallelDeletes blobs at a time
+func (b MicrosoftBlobBackend) DeleteObjects(paths []string) ([]DeleteResult, error) {
+	return deleteObjectsInParallel(paths, b.DeleteObject), nil
+}
+
+// CopyObject copies a blob within Microsoft Azure Blob Storage container, at prefix, and waits
+// until the copy, performed by the storage service, is complete
+func (b MicrosoftBlobBackend) CopyObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "CopyObject", src)
+	if b.Container == nil {
+		return errors.New("Unable to obtain a container reference.")
+	}
+	srcReference := b.Container.GetBlobReference(pathutil.Join(b.Prefix, src))
+	dstReference := b.Container.GetBlobReference(pathutil.Join(b.Prefix, dst))
+	return dstReference.Copy(srcReference.GetURL(), nil)
+}
+
+// MoveObject copies a blob within Microsoft Azure Blob Storage container, at prefix, and deletes the original
+func (b MicrosoftBlobBackend) MoveObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "MoveObject", src)
+	return moveObject(src, dst, b.CopyObject, b.DeleteObject)
+}
+
+// PresignGet generates a shared access signature URL to download a blob from Microsoft Azure Blob Storage
+// container, at prefix, until ttl expires
+func (b MicrosoftBlobBackend) PresignGet(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignGet", path)
+	return b.sasURI(path, microsoft_storage.BlobServiceSASPermissions{Read: true}, ttl)
+}
+
+// PresignPut generates a shared access signature URL to upload a blob to Microsoft Azure Blob Storage
+// container, at prefix, until ttl expires. Uploads must set the x-ms-blob-type header to BlockBlob.
+func (b MicrosoftBlobBackend) PresignPut(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignPut", path)
+	return b.sasURI(path, microsoft_storage.BlobServiceSASPermissions{Create: true, Write: true}, ttl)
+}
+
+// sasURI generates a shared access signature URL granting permissions on a blob, signed with the account key
+func (b MicrosoftBlobBackend) sasURI(path string, permissions microsoft_storage.BlobServiceSASPermissions, ttl time.Duration) (string, error) {
+	if b.Container == nil {
+		return "", errors.New("Unable to obtain a container reference.")
+	}
+	if err := validatePresignTTL(ttl); err != nil {
+		return "", err
+	}
+	blobReference := b.Container.GetBlobReference(pathutil.Join(b.Prefix, path))
+	return blobReference.GetSASURI(microsoft_storage.BlobSASOptions{
+		BlobServiceSASPermissions: permissions,
+		SASOptions: microsoft_storage.SASOptions{
+			Expiry:   time.Now().Add(ttl),
+			UseHTTPS: true,
+		},
+	})
+}
+
+// microsoftBlobObjectInfo builds an ObjectInfo from the properties and metadata of a blob
+func microsoftBlobObjectInfo(path string, blob *microsoft_storage.Blob) ObjectInfo {
+	return ObjectInfo{
+		Path:         path,
+		Size:         blob.Properties.ContentLength,
+		LastModified: time.Time(blob.Properties.LastModified),
+		ETag:         cleanETag(blob.Properties.Etag),
+		ContentType:  blob.Properties.ContentType,
+		UserMetadata: lowerCaseKeys(blob.Metadata),
+	}
+}
+
+func (b MicrosoftBlobBackend) wrapError(err *error, op string, path string) {
+	if wrapStorageError(err, "MicrosoftBlob", op, path, microsoftBlobErrorKind) {
+		logStorageError(b.logger, *err)
+	}
+}
+
+func microsoftBlobErrorKind(err error) error {
+	if errors.Is(err, errBlobNotExist) {
+		return ErrObjectNotFound
+	}
+	var serviceErr microsoft_storage.AzureStorageServiceError
+	if errors.As(err, &serviceErr) {
+		switch serviceErr.Code {
+		case "ServerBusy":
+			return ErrThrottled
+		case "BlobAlreadyExists", "ConditionNotMet":
+			return ErrPreconditionFailed
+		}
+		return kindFromStatusCode(serviceErr.StatusCode)
+	}
+	return nil
+}
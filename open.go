@@ -0,0 +1,275 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Opener creates a Backend from a URL of the scheme it was registered for
+type Opener func(u *url.URL) (Backend, error)
+
+var (
+	openersMu sync.RWMutex
+	openers   = make(map[string]Opener)
+)
+
+func init() {
+	RegisterScheme("s3", openAmazonS3)
+	RegisterScheme("gs", openGoogleCS)
+	RegisterScheme("azblob", openMicrosoftBlob)
+	RegisterScheme("oss", openAlibabaCloudOSS)
+	RegisterScheme("cos", openTencentCloudCOS)
+	RegisterScheme("bos", openBaiduBOS)
+	RegisterScheme("swift", openOpenstackOS)
+	RegisterScheme("oci", openOracleCS)
+	RegisterScheme("etcd", openEtcd)
+	RegisterScheme("file", openLocalFilesystem)
+}
+
+// RegisterScheme makes a Backend available to Open for the URLs of scheme.
+// It panics if opener is nil or if a Backend is already registered for scheme.
+func RegisterScheme(scheme string, opener Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	scheme = strings.ToLower(scheme)
+	if opener == nil {
+		panic("storage: RegisterScheme opener is nil")
+	}
+	if _, dup := openers[scheme]; dup {
+		panic("storage: RegisterScheme called twice for scheme " + scheme)
+	}
+	openers[scheme] = opener
+}
+
+// Schemes returns the sorted list of the schemes Open accepts
+func Schemes() []string {
+	openersMu.RLock()
+	defer openersMu.RUnlock()
+	schemes := make([]string, 0, len(openers))
+	for scheme := range openers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open creates a Backend from a URL, such as s3://bucket/prefix?region=us-east-1&sse=AES256.
+// The host of the URL is the bucket (or container), its path the prefix, and its query the options
+// of the backend. Credentials are still read from the environment, as by the backend constructors.
+// Backends which cannot be created return an error, matching ErrInvalidConfig if they are misconfigured
+// or if the URL has options the backend does not know, such as a misspelled one.
+// Open returns an error matching ErrNotSupported if no Backend is registered for the scheme of the URL.
+func Open(rawURL string) (Backend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	openersMu.RLock()
+	opener, ok := openers[u.Scheme]
+	openersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: no backend registered for scheme %q", ErrNotSupported, u.Scheme)
+	}
+	return opener(u)
+}
+
+// opened returns the backend created by a constructor, or a nil Backend if it failed
+func opened[B Backend](backend B, err error) (Backend, error) {
+	if err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
+
+// bucketAndPrefix returns the bucket and the prefix designated by a URL, failing if the bucket is missing
+func bucketAndPrefix(u *url.URL) (string, string, error) {
+	if u.Host == "" {
+		return "", "", configError(u.Scheme, "missing bucket in URL")
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// queryOptions returns the query of a URL, failing if it has options other than names
+func queryOptions(u *url.URL, names ...string) (url.Values, error) {
+	query := u.Query()
+	var unknown []string
+	for name := range query {
+		if !slices.Contains(names, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, configError(u.Scheme, "unknown option %s in URL", strings.Join(unknown, ", "))
+	}
+	return query, nil
+}
+
+// openAmazonS3 opens s3://bucket/prefix?region=&endpoint=&sse=&s3ForcePathStyle=
+func openAmazonS3(u *url.URL) (Backend, error) {
+	bucket, prefix, err := bucketAndPrefix(u)
+	if err != nil {
+		return nil, err
+	}
+	query, err := queryOptions(u, "region", "endpoint", "sse", "s3ForcePathStyle")
+	if err != nil {
+		return nil, err
+	}
+	if value := query.Get("s3ForcePathStyle"); value != "" {
+		forcePathStyle, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, configError("s3", "invalid s3ForcePathStyle %q in URL", value)
+		}
+		options := &AmazonS3Options{S3ForcePathStyle: &forcePathStyle}
+		return NewAmazonS3BackendWithOptions(bucket, prefix, query.Get("region"), query.Get("endpoint"), query.Get("sse"), options), nil
+	}
+	return NewAmazonS3Backend(bucket, prefix, query.Get("region"), query.Get("endpoint"), query.Get("sse")), nil
+}
+
+// openGoogleCS opens gs://bucket/prefix
+func openGoogleCS(u *url.URL) (Backend, error) {
+	bucket, prefix, err := bucketAndPrefix(u)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := queryOptions(u); err != nil {
+		return nil, err
+	}
+	return opened(NewGoogleCSBackendE(bucket, prefix))
+}
+
+// openMicrosoftBlob opens azblob://container/prefix
+func openMicrosoftBlob(u *url.URL) (Backend, error) {
+	container, prefix, err := bucketAndPrefix(u)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := queryOptions(u); err != nil {
+		return nil, err
+	}
+	return opened(NewMicrosoftBlobBackendE(container, prefix))
+}
+
+// openAlibabaCloudOSS opens oss://bucket/prefix?endpoint=&sse=
+func openAlibabaCloudOSS(u *url.URL) (Backend, error) {
+	bucket, prefix, err := bucketAndPrefix(u)
+	if err != nil {
+		return nil, err
+	}
+	query, err := queryOptions(u, "endpoint", "sse")
+	if err != nil {
+		return nil, err
+	}
+	return opened(NewAlibabaCloudOSSBackendE(bucket, prefix, query.Get("endpoint"), query.Get("sse")))
+}
+
+// openTencentCloudCOS opens cos://bucket/prefix?endpoint=
+func openTencentCloudCOS(u *url.URL) (Backend, error) {
+	bucket, prefix, err := bucketAndPrefix(u)
+	if err != nil {
+		return nil, err
+	}
+	query, err := queryOptions(u, "endpoint")
+	if err != nil {
+		return nil, err
+	}
+	return opened(NewTencentCloudCOSBackendE(bucket, prefix, query.Get("endpoint")))
+}
+
+// openBaiduBOS opens bos://bucket/prefix?endpoint=
+func openBaiduBOS(u *url.URL) (Backend, error) {
+	bucket, prefix, err := bucketAndPrefix(u)
+	if err != nil {
+		return nil, err
+	}
+	query, err := queryOptions(u, "endpoint")
+	if err != nil {
+		return nil, err
+	}
+	return opened(NewBaiDuBOSBackendE(bucket, prefix, query.Get("endpoint")))
+}
+
+// openOpenstackOS opens swift://container/prefix?region=&cacert=&auth=v1
+func openOpenstackOS(u *url.URL) (Backend, error) {
+	container, prefix, err := bucketAndPrefix(u)
+	if err != nil {
+		return nil, err
+	}
+	query, err := queryOptions(u, "region", "cacert", "auth")
+	if err != nil {
+		return nil, err
+	}
+	switch auth := query.Get("auth"); auth {
+	case "", "v2", "v3":
+		return opened(NewOpenstackOSBackendE(container, prefix, query.Get("region"), query.Get("cacert")))
+	case "v1":
+		return opened(NewOpenstackOSBackendV1AuthE(container, prefix, query.Get("cacert")))
+	default:
+		return nil, configError("swift", "invalid auth %q in URL", auth)
+	}
+}
+
+// openOracleCS opens oci://bucket/prefix?region=&compartment=
+func openOracleCS(u *url.URL) (Backend, error) {
+	bucket, prefix, err := bucketAndPrefix(u)
+	if err != nil {
+		return nil, err
+	}
+	query, err := queryOptions(u, "region", "compartment")
+	if err != nil {
+		return nil, err
+	}
+	return opened(NewOracleCSBackendE(bucket, prefix, query.Get("region"), query.Get("compartment")))
+}
+
+// openEtcd opens etcd://host1:2379,host2:2379/prefix?cafile=&certfile=&keyfile=
+func openEtcd(u *url.URL) (Backend, error) {
+	endpoints, prefix, err := bucketAndPrefix(u)
+	if err != nil {
+		return nil, err
+	}
+	query, err := queryOptions(u, "cafile", "certfile", "keyfile")
+	if err != nil {
+		return nil, err
+	}
+	return NewEtcdCSBackendE(endpoints, query.Get("cafile"), query.Get("certfile"), query.Get("keyfile"), prefix)
+}
+
+// openLocalFilesystem opens file:///absolute/path, or file:relative/path
+func openLocalFilesystem(u *url.URL) (Backend, error) {
+	rootDirectory := u.Path
+	switch {
+	case u.Opaque != "":
+		rootDirectory = u.Opaque
+	case u.Host != "" && u.Host != "localhost":
+		rootDirectory = u.Host + u.Path
+	}
+	if rootDirectory == "" {
+		return nil, configError("file", "missing path in URL")
+	}
+	if _, err := queryOptions(u); err != nil {
+		return nil, err
+	}
+	return opened(NewLocalFilesystemBackendE(rootDirectory))
+}
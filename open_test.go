@@ -0,0 +1,164 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type OpenTestSuite struct {
+	suite.Suite
+}
+
+func (suite *OpenTestSuite) TestOpenAmazonS3() {
+	backend, err := Open("s3://mybucket/charts/stable?region=us-east-1&sse=AES256")
+	suite.Nil(err, "no error opening s3 URL")
+	s3Backend, ok := backend.(*AmazonS3Backend)
+	suite.True(ok, "s3 URL opens an AmazonS3Backend")
+	suite.Equal("mybucket", s3Backend.Bucket, "bucket is the host of the URL")
+	suite.Equal("charts/stable", s3Backend.Prefix, "prefix is the path of the URL")
+	suite.Equal("AES256", s3Backend.SSE, "sse is read from the query")
+	suite.Equal("us-east-1", *s3Backend.Client.Config.Region, "region is read from the query")
+
+	backend, err = Open("s3://mybucket?endpoint=http://localhost:9000&s3ForcePathStyle=false")
+	suite.Nil(err, "no error opening s3 URL with options")
+	s3Backend = backend.(*AmazonS3Backend)
+	suite.Equal("", s3Backend.Prefix, "prefix is empty without a path")
+	suite.False(*s3Backend.Client.Config.S3ForcePathStyle, "s3ForcePathStyle is read from the query")
+
+	_, err = Open("s3://mybucket?s3ForcePathStyle=maybe")
+	suite.True(errors.Is(err, ErrInvalidConfig), "cannot open s3 URL with invalid s3ForcePathStyle")
+
+	_, err = Open("s3:///charts")
+	suite.True(errors.Is(err, ErrInvalidConfig), "cannot open s3 URL without bucket")
+}
+
+func (suite *OpenTestSuite) TestOpenUnknownOption() {
+	for _, rawURL := range []string{
+		"s3://mybucket?regoin=us-east-1",
+		"s3://mybucket?region=us-east-1&SSE=AES256",
+		"gs://mybucket?region=us",
+		"azblob://mycontainer?endpoint=",
+		"oss://mybucket?sse=&region=",
+		"cos://mybucket?sse=AES256",
+		"bos://mybucket?region=",
+		"swift://mycontainer?auth=v1&key=",
+		"oci://mybucket?compartement=",
+		"etcd://host1:2379?cafiel=ca.pem",
+		"file:///tmp/charts?mode=0700",
+	} {
+		_, err := Open(rawURL)
+		suite.True(errors.Is(err, ErrInvalidConfig), "cannot open %s with an unknown option", rawURL)
+		suite.ErrorContains(err, "unknown option", "%s is rejected for its option", rawURL)
+	}
+
+	_, err := Open("s3://mybucket?regoin=us-east-1&endpoint=&colour=")
+	suite.ErrorContains(err, "unknown option colour, regoin", "unknown options are named")
+}
+
+func (suite *OpenTestSuite) TestOpenOpenstack() {
+	for _, name := range []string{"ST_USER", "ST_KEY", "ST_AUTH", "OS_AUTH_URL"} {
+		suite.T().Setenv(name, "")
+	}
+
+	_, err := Open("swift://mycontainer/charts?auth=v1")
+	suite.True(errors.Is(err, ErrInvalidConfig), "cannot open swift URL with v1 auth without credentials")
+	suite.ErrorContains(err, "ST_USER", "auth=v1 reads the credentials of v1 authentication")
+
+	_, err = Open("swift://mycontainer/charts")
+	suite.True(errors.Is(err, ErrInvalidConfig), "cannot open swift URL without credentials")
+	suite.NotContains(err.Error(), "ST_USER", "v1 authentication is only used with auth=v1")
+
+	_, err = Open("swift://mycontainer?auth=v4")
+	suite.True(errors.Is(err, ErrInvalidConfig), "cannot open swift URL with invalid auth")
+
+	_, err = Open("swift:///charts?auth=v1")
+	suite.True(errors.Is(err, ErrInvalidConfig), "cannot open swift URL without container")
+}
+
+func (suite *OpenTestSuite) TestOpenEtcd() {
+	u, err := url.Parse("etcd://host1:2379,host2:2379/prefix")
+	suite.Nil(err, "no error parsing etcd URL with several endpoints")
+	endpoints, prefix, err := bucketAndPrefix(u)
+	suite.Nil(err)
+	suite.Equal("prefix", prefix, "prefix is the path of the URL")
+	conf, err := parseConf(endpoints, "", "", "", time.Second)
+	suite.Nil(err)
+	suite.Equal([]string{"host1:2379", "host2:2379"}, conf.Endpoints, "endpoints are the hosts of the URL")
+
+	_, err = Open("etcd://host1:2379,host2:2379/prefix?cafile=" + filepath.Join(suite.T().TempDir(), "missing.pem"))
+	suite.True(errors.Is(err, ErrInvalidConfig), "cannot open etcd URL with missing cafile")
+
+	_, err = Open("etcd:///prefix")
+	suite.True(errors.Is(err, ErrInvalidConfig), "cannot open etcd URL without endpoints")
+}
+
+func (suite *OpenTestSuite) TestOpenLocalFilesystem() {
+	for rawURL, rootDirectory := range map[string]string{
+		"file:///tmp/charts":          "/tmp/charts",
+		"file://localhost/tmp/charts": "/tmp/charts",
+		"file:charts":                 "charts",
+		"file://./charts":             "./charts",
+	} {
+		backend, err := Open(rawURL)
+		suite.Nil(err, "no error opening %s", rawURL)
+		absPath, _ := filepath.Abs(rootDirectory)
+		suite.Equal(absPath, backend.(*LocalFilesystemBackend).RootDirectory, "root directory of %s", rawURL)
+	}
+
+	_, err := Open("file://")
+	suite.True(errors.Is(err, ErrInvalidConfig), "cannot open file URL without path")
+}
+
+func (suite *OpenTestSuite) TestOpenUnknownScheme() {
+	_, err := Open("ftp://example.com/charts")
+	suite.True(errors.Is(err, ErrNotSupported), "unknown scheme is not supported")
+
+	_, err = Open("://charts")
+	suite.NotNil(err, "cannot open invalid URL")
+}
+
+func (suite *OpenTestSuite) TestRegisterScheme() {
+	var opened *url.URL
+	RegisterScheme("Mem", func(u *url.URL) (Backend, error) {
+		opened = u
+		return NewLocalFilesystemBackend(filepath.Join("/tmp", u.Host)), nil
+	})
+	suite.Contains(Schemes(), "mem", "registered scheme is listed in lower case")
+
+	backend, err := Open("mem://charts/stable")
+	suite.Nil(err, "no error opening registered scheme")
+	suite.NotNil(backend)
+	suite.Equal("charts", opened.Host, "opener receives the URL")
+
+	suite.Panics(func() {
+		RegisterScheme("mem", openLocalFilesystem)
+	}, "registering a scheme twice panics")
+	suite.Panics(func() {
+		RegisterScheme("nil", nil)
+	}, "registering a nil opener panics")
+}
+
+func TestOpenTestSuite(t *testing.T) {
+	suite.Run(t, new(OpenTestSuite))
+}
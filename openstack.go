@@ -18,14 +18,20 @@ package storage
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"iter"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	pathutil "path"
+	"strings"
 	"time"
 
 	"github.com/gophercloud/gophercloud"
@@ -35,6 +41,9 @@ import (
 	"github.com/gophercloud/gophercloud/pagination"
 )
 
+// openstackMetadataPrefix is the header prefix of the user metadata of an object
+const openstackMetadataPrefix = "X-Object-Meta-"
+
 // ReauthRoundTripper satisfies the http.RoundTripper interface and is used to
 // limit the number of consecutive re-auth attempts (infinite by default)
 type ReauthRoundTripper struct {
@@ -68,13 +77,28 @@ type OpenstackOSBackend struct {
 	Region    string
 	CACert    string
 	Client    *gophercloud.ServiceClient
+	logger    *slog.Logger
 }
 
-// NewOpenstackOSBackend creates a new instance of OpenstackOSBackend
+// NewOpenstackOSBackend creates a new instance of OpenstackOSBackend.
+// It panics if the backend cannot be created; NewOpenstackOSBackendE returns the error instead.
 func NewOpenstackOSBackend(container string, prefix string, region string, caCert string) *OpenstackOSBackend {
+	b, err := NewOpenstackOSBackendE(container, prefix, region, caCert)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// NewOpenstackOSBackendE creates a new instance of OpenstackOSBackend, authenticating against the
+// identity service configured by the OS_* environment variables
+func NewOpenstackOSBackendE(container string, prefix string, region string, caCert string) (*OpenstackOSBackend, error) {
+	if container == "" {
+		return nil, configError("Openstack", "missing container")
+	}
 	authOptions, err := openstack.AuthOptionsFromEnv()
 	if err != nil {
-		panic(fmt.Sprintf("Openstack (environment): %s", err))
+		return nil, configError("Openstack (environment)", "%s", err)
 	}
 	authOptions.AllowReauth = true
 
@@ -90,31 +114,15 @@ func NewOpenstackOSBackend(container string, prefix string, region string, caCer
 	}
 
 	// Create a custom HTTP client to handle reauth retry and custom CACERT if needed
-	roundTripper := ReauthRoundTripper{}
-	if caCert != "" {
-		caCert, err := ioutil.ReadFile(caCert)
-		if err != nil {
-			panic(fmt.Sprintf("Openstack (ca certificates): %s", err))
-		}
-
-		caCertPool := x509.NewCertPool()
-		if !caCertPool.AppendCertsFromPEM(caCert) {
-			panic(fmt.Sprintf("Openstack (ca certificates): unable to read certificate bundle"))
-		}
-
-		transport := &http.Transport{
-			TLSClientConfig: &tls.Config{
-				RootCAs: caCertPool,
-			},
-		}
-		roundTripper.rt = transport
-	} else {
-		roundTripper.rt = http.DefaultTransport
+	transport, err := openstackTransport(caCert)
+	if err != nil {
+		return nil, err
 	}
+	roundTripper := ReauthRoundTripper{rt: transport}
 
 	provider, err := openstack.NewClient(authOptions.IdentityEndpoint)
 	if err != nil {
-		panic(fmt.Sprintf("Openstack (client): %s", err))
+		return nil, configError("Openstack (client)", "%s", err)
 	}
 
 	provider.HTTPClient = http.Client{
@@ -123,14 +131,14 @@ func NewOpenstackOSBackend(container string, prefix string, region string, caCer
 
 	err = openstack.Authenticate(provider, authOptions)
 	if err != nil {
-		panic(fmt.Sprintf("Openstack (authenticate): %s", err))
+		return nil, fmt.Errorf("Openstack (authenticate): %w", err)
 	}
 
 	client, err := openstack.NewObjectStorageV1(provider, gophercloud.EndpointOpts{
 		Region: region,
 	})
 	if err != nil {
-		panic(fmt.Sprintf("Openstack (object storage): %s", err))
+		return nil, fmt.Errorf("Openstack (object storage): %w", err)
 	}
 
 	b := &OpenstackOSBackend{
@@ -140,14 +148,28 @@ func NewOpenstackOSBackend(container string, prefix string, region string, caCer
 		Client:    client,
 	}
 
-	return b
+	return b, nil
 }
 
-// NewOpenstackOSBackendV1Auth creates a new instance of OpenstackOSBackend using Swift V1 Auth
+// NewOpenstackOSBackendV1Auth creates a new instance of OpenstackOSBackend using Swift V1 Auth.
+// It panics if the backend cannot be created; NewOpenstackOSBackendV1AuthE returns the error instead.
 func NewOpenstackOSBackendV1Auth(container string, prefix string, caCert string) *OpenstackOSBackend {
+	b, err := NewOpenstackOSBackendV1AuthE(container, prefix, caCert)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// NewOpenstackOSBackendV1AuthE creates a new instance of OpenstackOSBackend using Swift V1 Auth,
+// configured by the ST_USER, ST_KEY and ST_AUTH environment variables
+func NewOpenstackOSBackendV1AuthE(container string, prefix string, caCert string) (*OpenstackOSBackend, error) {
+	if container == "" {
+		return nil, configError("Openstack", "missing container")
+	}
 	for _, e := range []string{"ST_USER", "ST_KEY", "ST_AUTH"} {
 		if os.Getenv(e) == "" {
-			panic(fmt.Sprintf("Openstack (object storage): missing environment variable %s", e))
+			return nil, configError("Openstack (object storage)", "missing environment variable %s", e)
 		}
 	}
 
@@ -158,28 +180,14 @@ func NewOpenstackOSBackendV1Auth(container string, prefix string, caCert string)
 	identityEndpoint := os.Getenv("ST_AUTH")
 
 	// Create a custom HTTP client to handle custom CACERT if needed
-	httpTransport := http.DefaultTransport
-	if caCert != "" {
-		caCert, err := ioutil.ReadFile(caCert)
-		if err != nil {
-			panic(fmt.Sprintf("Openstack (ca certificates): %s", err))
-		}
-
-		caCertPool := x509.NewCertPool()
-		if !caCertPool.AppendCertsFromPEM(caCert) {
-			panic(fmt.Sprintf("Openstack (ca certificates): unable to read certificate bundle"))
-		}
-
-		httpTransport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				RootCAs: caCertPool,
-			},
-		}
+	httpTransport, err := openstackTransport(caCert)
+	if err != nil {
+		return nil, err
 	}
 
 	provider, err := openstack.NewClient(identityEndpoint)
 	if err != nil {
-		panic(fmt.Sprintf("Openstack (client): %s", err))
+		return nil, configError("Openstack (client)", "%s", err)
 	}
 
 	provider.HTTPClient = http.Client{
@@ -208,7 +216,7 @@ func NewOpenstackOSBackendV1Auth(container string, prefix string, caCert string)
 
 	client, err := swauth.NewObjectStorageV1(provider, authOpts)
 	if err != nil {
-		panic(fmt.Sprintf("Openstack (object storage): %s", err))
+		return nil, fmt.Errorf("Openstack (object storage): %w", err)
 	}
 
 	b := &OpenstackOSBackend{
@@ -217,11 +225,63 @@ func NewOpenstackOSBackendV1Auth(container string, prefix string, caCert string)
 		Client:    client,
 	}
 
-	return b
+	return b, nil
+}
+
+// openstackTransport returns the default transport, or a transport trusting the certificates of the caCert bundle,
+// instrumented to propagate the trace context of the requests
+func openstackTransport(caCert string) (http.RoundTripper, error) {
+	if caCert == "" {
+		return tracingTransport(http.DefaultTransport), nil
+	}
+	pem, err := ioutil.ReadFile(caCert)
+	if err != nil {
+		return nil, configError("Openstack (ca certificates)", "%s", err)
+	}
+
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(pem) {
+		return nil, configError("Openstack (ca certificates)", "unable to read certificate bundle")
+	}
+
+	return tracingTransport(&http.Transport{
+		TLSClientConfig: &tls.Config{
+			RootCAs: caCertPool,
+		},
+	}), nil
+}
+
+// clientWithContext returns a shallow copy of the service client whose requests carry ctx
+func (b OpenstackOSBackend) clientWithContext(ctx context.Context) *gophercloud.ServiceClient {
+	provider := *b.Client.ProviderClient
+	provider.Context = ctx
+	client := *b.Client
+	client.ProviderClient = &provider
+	return &client
+}
+
+// SetLogger sets the logger of the failed operations on Openstack Object Storage. Nothing is logged without a logger.
+func (b *OpenstackOSBackend) SetLogger(logger *slog.Logger) {
+	b.logger = logger
+}
+
+// Capabilities returns the optional features supported by Openstack Object Storage
+func (b OpenstackOSBackend) Capabilities() CapabilitySet {
+	return NewCapabilitySet(
+		CapabilityContext, CapabilityStream, CapabilityRange, CapabilityMetadata,
+		CapabilityStat, CapabilityHierarchical, CapabilityPaging, CapabilityBatchDelete,
+		CapabilityCopy, CapabilityPresign,
+	)
 }
 
 // ListObjects lists all objects in an Openstack container, at prefix
 func (b OpenstackOSBackend) ListObjects(prefix string) ([]Object, error) {
+	return b.ListObjectsContext(context.Background(), prefix)
+}
+
+// ListObjectsContext lists all objects in an Openstack container, at prefix, using ctx for the requests
+func (b OpenstackOSBackend) ListObjectsContext(ctx context.Context, prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
 	var objects []Object
 
 	prefix = pathutil.Join(b.Prefix, prefix)
@@ -230,8 +290,8 @@ func (b OpenstackOSBackend) ListObjects(prefix string) ([]Object, error) {
 		Prefix: prefix,
 	}
 
-	pager := osObjects.List(b.Client, b.Container, opts)
-	err := pager.EachPage(func(page pagination.Page) (bool, error) {
+	pager := osObjects.List(b.clientWithContext(ctx), b.Container, opts)
+	err = pager.EachPage(func(page pagination.Page) (bool, error) {
 		objectList, err := osObjects.ExtractInfo(page)
 		if err != nil {
 			return false, err
@@ -243,19 +303,13 @@ func (b OpenstackOSBackend) ListObjects(prefix string) ([]Object, error) {
 				continue
 			}
 
-			// This is a patch so that LastModified match between the List and GetObject function
-			// Openstack seems to send a rounded up time when getting the LastModified date from an object show versus an object list
-			var lastModified time.Time
-			if openStackObject.LastModified.Nanosecond()/int(time.Microsecond) == 0 {
-				lastModified = openStackObject.LastModified
-			} else {
-				lastModified = openStackObject.LastModified.Truncate(time.Second).Add(time.Second)
-			}
-
 			object := Object{
 				Path:         path,
 				Content:      []byte{},
-				LastModified: lastModified,
+				LastModified: listedLastModified(openStackObject.LastModified),
+				Size:         openStackObject.Bytes,
+				ETag:         openStackObject.Hash,
+				ContentType:  openStackObject.ContentType,
 			}
 			objects = append(objects, object)
 		}
@@ -265,17 +319,131 @@ func (b OpenstackOSBackend) ListObjects(prefix string) ([]Object, error) {
 	return objects, err
 }
 
+// ListObjectsPage lists a page of the objects in an Openstack container, at prefix.
+// The token is the name of the last object or subdirectory of the previous page.
+func (b OpenstackOSBackend) ListObjectsPage(prefix string, token string, limit int) (_ ObjectPage, err error) {
+	defer b.wrapError(&err, "ListObjectsPage", prefix)
+	var page ObjectPage
+
+	prefix = listPrefix(b.Prefix, prefix)
+	limit = pageSize(limit)
+	opts := &osObjects.ListOpts{
+		Full:      true,
+		Prefix:    prefix,
+		Delimiter: "/",
+		Marker:    token,
+		Limit:     limit,
+	}
+
+	pager := osObjects.List(b.Client, b.Container, opts)
+	err = pager.EachPage(func(p pagination.Page) (bool, error) {
+		objectList, err := osObjects.ExtractInfo(p)
+		if err != nil {
+			return false, err
+		}
+
+		for _, openStackObject := range objectList {
+			if openStackObject.Subdir != "" {
+				continue
+			}
+			path := strings.TrimPrefix(openStackObject.Name, prefix)
+			if objectPathIsInvalid(path) {
+				continue
+			}
+			object := Object{
+				Path:         path,
+				Content:      []byte{},
+				LastModified: listedLastModified(openStackObject.LastModified),
+				Size:         openStackObject.Bytes,
+				ETag:         openStackObject.Hash,
+				ContentType:  openStackObject.ContentType,
+			}
+			page.Objects = append(page.Objects, object)
+		}
+		// Swift returns fewer than limit entries on the last page
+		if len(objectList) == limit {
+			last := objectList[len(objectList)-1]
+			page.NextToken = last.Name
+			if last.Subdir != "" {
+				page.NextToken = last.Subdir
+			}
+		}
+		return false, nil
+	})
+
+	return page, err
+}
+
+// ListObjectsIter iterates over the objects in an Openstack container, at prefix
+func (b OpenstackOSBackend) ListObjectsIter(prefix string) iter.Seq2[Object, error] {
+	return listObjectsIter(b, prefix)
+}
+
+// ListObjectsWithOptions lists the objects and common prefixes below prefix in an Openstack container
+func (b OpenstackOSBackend) ListObjectsWithOptions(prefix string, options ListObjectsOptions) (_ ListObjectsResult, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
+	var result ListObjectsResult
+
+	prefix = listPrefix(b.Prefix, prefix)
+	opts := &osObjects.ListOpts{
+		Full:      true,
+		Prefix:    prefix,
+		Delimiter: options.nativeDelimiter(),
+	}
+
+	pager := osObjects.List(b.Client, b.Container, opts)
+	err = pager.EachPage(func(page pagination.Page) (bool, error) {
+		objectList, err := osObjects.ExtractInfo(page)
+		if err != nil {
+			return false, err
+		}
+
+		for _, openStackObject := range objectList {
+			if openStackObject.Subdir != "" {
+				result.CommonPrefixes = append(result.CommonPrefixes, strings.TrimPrefix(openStackObject.Subdir, prefix))
+				continue
+			}
+			path := strings.TrimPrefix(openStackObject.Name, prefix)
+			if objectPathIsInvalid(path) {
+				continue
+			}
+			object := Object{
+				Path:         path,
+				Content:      []byte{},
+				LastModified: listedLastModified(openStackObject.LastModified),
+				Size:         openStackObject.Bytes,
+				ETag:         openStackObject.Hash,
+				ContentType:  openStackObject.ContentType,
+			}
+			result.Objects = append(result.Objects, object)
+		}
+		return true, nil
+	})
+
+	return result, err
+}
+
 // GetObject retrieves an object from an Openstack container, at prefix
 func (b OpenstackOSBackend) GetObject(path string) (Object, error) {
+	return b.GetObjectContext(context.Background(), path)
+}
+
+// GetObjectContext retrieves an object from an Openstack container, at prefix, using ctx for the request
+func (b OpenstackOSBackend) GetObjectContext(ctx context.Context, path string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObject", path)
 	var object Object
 	object.Path = path
 
-	result := osObjects.Download(b.Client, b.Container, pathutil.Join(b.Prefix, path), nil)
+	result := osObjects.Download(b.clientWithContext(ctx), b.Container, pathutil.Join(b.Prefix, path), nil)
 	headers, err := result.Extract()
 	if err != nil {
 		return object, err
 	}
 	object.LastModified = headers.LastModified
+	object.Size = headers.ContentLength
+	object.ETag = headers.ETag
+	object.ContentType = headers.ContentType
+	object.UserMetadata = userMetadataFromHeader(result.Header, openstackMetadataPrefix)
 
 	content, err := result.ExtractContent()
 	if err != nil {
@@ -287,20 +455,192 @@ func (b OpenstackOSBackend) GetObject(path string) (Object, error) {
 
 // PutObject uploads an object to Openstack container, at prefix
 func (b OpenstackOSBackend) PutObject(path string, content []byte) error {
+	return b.PutObjectContext(context.Background(), path, content)
+}
+
+// PutObjectContext uploads an object to Openstack container, at prefix, using ctx for the request
+func (b OpenstackOSBackend) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	defer b.wrapError(&err, "PutObject", path)
 	reader := bytes.NewReader(content)
 	createOpts := osObjects.CreateOpts{
 		Content: reader,
 	}
-	_, err := osObjects.Create(b.Client, b.Container, pathutil.Join(b.Prefix, path), createOpts).Extract()
+	_, err = osObjects.Create(b.clientWithContext(ctx), b.Container, pathutil.Join(b.Prefix, path), createOpts).Extract()
+	return err
+}
+
+// PutObjectWithOptions uploads an object to Openstack container, at prefix, along with its content type
+// and user metadata. Swift has no per-object storage class, so it is ignored.
+func (b OpenstackOSBackend) PutObjectWithOptions(path string, content []byte, options PutObjectOptions) (err error) {
+	defer b.wrapError(&err, "PutObjectWithOptions", path)
+	createOpts := osObjects.CreateOpts{
+		Content:     bytes.NewReader(content),
+		ContentType: options.ContentType,
+		Metadata:    options.UserMetadata,
+	}
+	_, err = osObjects.Create(b.Client, b.Container, pathutil.Join(b.Prefix, path), createOpts).Extract()
+	return err
+}
+
+// GetObjectReader opens an object in an Openstack container, at prefix, for reading
+func (b OpenstackOSBackend) GetObjectReader(path string) (_ io.ReadCloser, _ ObjectInfo, err error) {
+	defer b.wrapError(&err, "GetObjectReader", path)
+	info := ObjectInfo{Path: path}
+
+	result := osObjects.Download(b.Client, b.Container, pathutil.Join(b.Prefix, path), nil)
+	headers, err := result.Extract()
+	if err != nil {
+		if result.Body != nil {
+			result.Body.Close()
+		}
+		return nil, info, err
+	}
+	info.Size = headers.ContentLength
+	info.LastModified = headers.LastModified
+	info.ETag = headers.ETag
+	info.ContentType = headers.ContentType
+	info.UserMetadata = userMetadataFromHeader(result.Header, openstackMetadataPrefix)
+	return result.Body, info, nil
+}
+
+// GetObjectRange reads part of an object from Openstack container, at prefix, with an HTTP range request
+func (b OpenstackOSBackend) GetObjectRange(path string, offset int64, length int64) (_ []byte, err error) {
+	defer b.wrapError(&err, "GetObjectRange", path)
+	return getObjectRange(offset, length, openstackOSErrorKind, func(offset int64, length int64) ([]byte, error) {
+		result := osObjects.Download(b.Client, b.Container, pathutil.Join(b.Prefix, path), osObjects.DownloadOpts{Range: httpRange(offset, length)})
+		return result.ExtractContent()
+	})
+}
+
+// PutObjectStream uploads an object to Openstack container, at prefix, from a stream.
+// The ETag checksum is only sent when content can be rewound, so streams are never buffered.
+func (b OpenstackOSBackend) PutObjectStream(path string, content io.Reader, size int64) (err error) {
+	defer b.wrapError(&err, "PutObjectStream", path)
+	createOpts := osObjects.CreateOpts{
+		Content: content,
+	}
+	if _, ok := content.(io.ReadSeeker); !ok {
+		createOpts.NoETag = true
+	}
+	if size >= 0 {
+		createOpts.ContentLength = size
+	}
+	_, err = osObjects.Create(b.Client, b.Container, pathutil.Join(b.Prefix, path), createOpts).Extract()
 	return err
 }
 
+// StatObject retrieves the headers of an object in an Openstack container, at prefix
+func (b OpenstackOSBackend) StatObject(path string) (_ ObjectInfo, err error) {
+	defer b.wrapError(&err, "StatObject", path)
+	info := ObjectInfo{Path: path}
+	result := osObjects.Get(b.Client, b.Container, pathutil.Join(b.Prefix, path), nil)
+	headers, err := result.Extract()
+	if err != nil {
+		return info, err
+	}
+	info.Size = headers.ContentLength
+	info.LastModified = headers.LastModified
+	info.ETag = headers.ETag
+	info.ContentType = headers.ContentType
+	info.UserMetadata = userMetadataFromHeader(result.Header, openstackMetadataPrefix)
+	return info, nil
+}
+
 // DeleteObject removes an object from an Openstack container, at prefix
 func (b OpenstackOSBackend) DeleteObject(path string) error {
-	_, err := osObjects.Delete(b.Client, b.Container, pathutil.Join(b.Prefix, path), nil).Extract()
+	return b.DeleteObjectContext(context.Background(), path)
+}
+
+// DeleteObjectContext removes an object from an Openstack container, at prefix, using ctx for the request
+func (b OpenstackOSBackend) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	defer b.wrapError(&err, "DeleteObject", path)
+	_, err = osObjects.Delete(b.clientWithContext(ctx), b.Container, pathutil.Join(b.Prefix, path), nil).Extract()
+	return err
+}
+
+// DeleteObjects removes objects from an Openstack container, at prefix, using the bulk-delete middleware
+func (b OpenstackOSBackend) DeleteObjects(paths []string) ([]DeleteResult, error) {
+	return deleteObjectsInBatches(paths, maxDeleteBatchSize, b.deleteBatch)
+}
+
+// deleteBatch removes the objects of a batch from an Openstack container, at prefix, with a single request.
+// Failed objects are reported by the middleware as their URL-encoded "container/object" name and a status
+func (b OpenstackOSBackend) deleteBatch(batch []DeleteResult) (err error) {
+	defer b.wrapError(&err, "DeleteObjects", "")
+	results := make(map[string]*DeleteResult, len(batch))
+	names := make([]string, len(batch))
+	for i := range batch {
+		names[i] = pathutil.Join(b.Prefix, batch[i].Path)
+		results[names[i]] = &batch[i]
+	}
+	resp, err := osObjects.BulkDelete(b.Client, b.Container, names).Extract()
+	if err != nil {
+		return err
+	}
+	for _, failure := range resp.Errors {
+		if len(failure) != 2 {
+			continue
+		}
+		name, err := url.PathUnescape(strings.TrimPrefix(failure[0], "/"))
+		if err != nil {
+			continue
+		}
+		if result, ok := results[strings.TrimPrefix(name, b.Container+"/")]; ok {
+			result.Err = errors.New(failure[1])
+			b.wrapError(&result.Err, "DeleteObjects", result.Path)
+		}
+	}
+	return nil
+}
+
+// CopyObject copies an object within an Openstack container, at prefix, without downloading it
+func (b OpenstackOSBackend) CopyObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "CopyObject", src)
+	opts := osObjects.CopyOpts{Destination: "/" + pathutil.Join(b.Container, b.Prefix, dst)}
+	_, err = osObjects.Copy(b.Client, b.Container, pathutil.Join(b.Prefix, src), opts).Extract()
 	return err
 }
 
+// MoveObject copies an object within an Openstack container, at prefix, and deletes the original
+func (b OpenstackOSBackend) MoveObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "MoveObject", src)
+	return moveObject(src, dst, b.CopyObject, b.DeleteObject)
+}
+
+// PresignGet generates a TempURL to download an object from an Openstack container, at prefix, until ttl expires.
+// The container or the account must have a Temp-URL-Key.
+func (b OpenstackOSBackend) PresignGet(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignGet", path)
+	return b.tempURL(path, osObjects.GET, ttl)
+}
+
+// PresignPut generates a TempURL to upload an object to an Openstack container, at prefix, until ttl expires.
+// The container or the account must have a Temp-URL-Key.
+func (b OpenstackOSBackend) PresignPut(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignPut", path)
+	return b.tempURL(path, osObjects.HTTPMethod(http.MethodPut), ttl)
+}
+
+func (b OpenstackOSBackend) tempURL(path string, method osObjects.HTTPMethod, ttl time.Duration) (string, error) {
+	if err := validatePresignTTL(ttl); err != nil {
+		return "", err
+	}
+	return osObjects.CreateTempURL(b.Client, b.Container, pathutil.Join(b.Prefix, path), osObjects.CreateTempURLOpts{
+		Method: method,
+		TTL:    int(ttl.Seconds()),
+	})
+}
+
+// listedLastModified rounds up the LastModified time of a listed object, so that it matches the time returned
+// by the GetObject function. This is a patch: Openstack seems to send a rounded up time when getting the
+// LastModified date from an object show versus an object list
+func listedLastModified(lastModified time.Time) time.Time {
+	if lastModified.Nanosecond()/int(time.Microsecond) == 0 {
+		return lastModified
+	}
+	return lastModified.Truncate(time.Second).Add(time.Second)
+}
+
 func getAuthScope() *gophercloud.AuthScope {
 	scope := &gophercloud.AuthScope{}
 
@@ -330,3 +670,17 @@ func getAuthScope() *gophercloud.AuthScope {
 	scope.DomainID = projectDomainID
 	return scope
 }
+
+func (b OpenstackOSBackend) wrapError(err *error, op string, path string) {
+	if wrapStorageError(err, "OpenStackOS", op, path, openstackOSErrorKind) {
+		logStorageError(b.logger, *err)
+	}
+}
+
+func openstackOSErrorKind(err error) error {
+	var statusCodeErr gophercloud.StatusCodeError
+	if errors.As(err, &statusCodeErr) {
+		return kindFromStatusCode(statusCodeErr.GetStatusCode())
+	}
+	return nil
+}
@@ -21,57 +21,86 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"iter"
+	"log/slog"
 	"os"
 	pathutil "path"
+	"strings"
 	"time"
 
 	"github.com/oracle/oci-go-sdk/common"
 	"github.com/oracle/oci-go-sdk/common/auth"
 	"github.com/oracle/oci-go-sdk/objectstorage"
+	"github.com/oracle/oci-go-sdk/objectstorage/transfer"
 )
 
+// oracleWorkRequestPollInterval is the delay between two checks of the status of a copy, which OCI performs asynchronously
+const oracleWorkRequestPollInterval = 500 * time.Millisecond
+
 // OracleCSBackend is a storage backend for Oracle Cloud Infrastructure Object Storage
 type OracleCSBackend struct {
 	Bucket        string
 	Prefix        string
 	Namespace     string
 	CompartmentId string
+	Region        string
 	Client        objectstorage.ObjectStorageClient
 	Context       context.Context
+	logger        *slog.Logger
 }
 
-// NewOracleCSBackend creates a new instance of OracleCSBackend
+// NewOracleCSBackend creates a new instance of OracleCSBackend.
+// It panics if the backend cannot be created; NewOracleCSBackendE returns the error instead.
 func NewOracleCSBackend(bucket string, prefix string, region string, compartmentId string) *OracleCSBackend {
+	b, err := NewOracleCSBackendE(bucket, prefix, region, compartmentId)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// NewOracleCSBackendE creates a new instance of OracleCSBackend, creating the bucket in compartmentId
+// if it does not exist yet. The credentials are read from the OCI configuration file, or obtained as
+// an instance principal if the ORACLE_AUTH_METHOD environment variable is InstancePrincipal.
+func NewOracleCSBackendE(bucket string, prefix string, region string, compartmentId string) (*OracleCSBackend, error) {
 
 	var config common.ConfigurationProvider
 	var err error
 
+	if len(bucket) == 0 {
+		return nil, configError("oracle", "missing bucket")
+	}
+
 	authMethod := os.Getenv("ORACLE_AUTH_METHOD")
 
 	if authMethod == "InstancePrincipal" {
 		config, err = auth.InstancePrincipalConfigurationProvider()
 		if err != nil {
-			panic(err)
+			return nil, configError("oracle", "failed to get instance principal configuration: %s", err)
 		}
 	} else {
 		config = common.DefaultConfigProvider()
 	}
 
 	c, err := objectstorage.NewObjectStorageClientWithConfigurationProvider(config)
+	if err != nil {
+		return nil, configError("oracle", "failed to create client: %s", err)
+	}
 
 	if len(region) > 0 {
 		c.SetRegion(region)
-	}
-
-	if err != nil {
-		panic(err)
+	} else if configRegion, err := config.Region(); err == nil {
+		region = configRegion
 	}
 
 	ctx := context.Background()
 	namespace, err := getNamespace(ctx, c)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("oracle: failed to get namespace: %w", err)
 	}
 
 	// Check if the bucket already exists
@@ -85,7 +114,7 @@ func NewOracleCSBackend(bucket string, prefix string, region string, compartment
 		// Create the bucket if it does not exist
 		_, err = createBucket(ctx, c, namespace, bucket, compartmentId)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("oracle: failed to create bucket %q: %w", bucket, err)
 		}
 	}
 
@@ -95,10 +124,11 @@ func NewOracleCSBackend(bucket string, prefix string, region string, compartment
 		Prefix:        prefix,
 		Namespace:     namespace,
 		CompartmentId: compartmentId,
+		Region:        region,
 		Client:        c,
 		Context:       ctx,
 	}
-	return b
+	return b, nil
 }
 
 func createBucket(ctx context.Context, c objectstorage.ObjectStorageClient, namespace string, bucket string, compartmentId string) (string, error) {
@@ -128,8 +158,28 @@ func getNamespace(ctx context.Context, c objectstorage.ObjectStorageClient) (str
 	return *r.Value, nil
 }
 
+// SetLogger sets the logger of the failed operations on OCI Object Storage. Nothing is logged without a logger.
+func (b *OracleCSBackend) SetLogger(logger *slog.Logger) {
+	b.logger = logger
+}
+
+// Capabilities returns the optional features supported by OCI Object Storage
+func (b OracleCSBackend) Capabilities() CapabilitySet {
+	return NewCapabilitySet(
+		CapabilityContext, CapabilityStream, CapabilityRange, CapabilityMetadata,
+		CapabilityStat, CapabilityHierarchical, CapabilityPaging, CapabilityBatchDelete,
+		CapabilityCopy, CapabilityPresign, CapabilityConditional, CapabilityVersioning,
+	)
+}
+
 // ListObjects lists all objects in OCI Object Storage bucket, at prefix
 func (b OracleCSBackend) ListObjects(prefix string) ([]Object, error) {
+	return b.ListObjectsContext(b.Context, prefix)
+}
+
+// ListObjectsContext lists all objects in OCI Object Storage bucket, at prefix, using ctx for the request
+func (b OracleCSBackend) ListObjectsContext(ctx context.Context, prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
 	var objects []Object
 	prefix = pathutil.Join(b.Prefix, prefix)
 
@@ -137,9 +187,10 @@ func (b OracleCSBackend) ListObjects(prefix string) ([]Object, error) {
 		NamespaceName: &b.Namespace,
 		BucketName:    &b.Bucket,
 		Prefix:        &prefix,
+		Fields:        common.String("name,size,etag,timeCreated"),
 	}
 
-	rc, err := b.Client.ListObjects(b.Context, request)
+	rc, err := b.Client.ListObjects(ctx, request)
 	if err != nil {
 		return objects, err
 	}
@@ -162,13 +213,146 @@ func (b OracleCSBackend) ListObjects(prefix string) ([]Object, error) {
 			Content:      []byte{},
 			LastModified: t,
 		}
+		if attrs.Size != nil {
+			object.Size = *attrs.Size
+		}
+		if attrs.Etag != nil {
+			object.ETag = *attrs.Etag
+		}
 		objects = append(objects, object)
 	}
 	return objects, nil
 }
 
+// ListObjectsPage lists a page of the objects in OCI Object Storage bucket, at prefix.
+// The token is the name of the first object of the next page, as returned by OCI.
+func (b OracleCSBackend) ListObjectsPage(prefix string, token string, limit int) (_ ObjectPage, err error) {
+	defer b.wrapError(&err, "ListObjectsPage", prefix)
+	var page ObjectPage
+	prefix = listPrefix(b.Prefix, prefix)
+
+	request := objectstorage.ListObjectsRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		Prefix:        &prefix,
+		Delimiter:     common.String("/"),
+		Limit:         common.Int(pageSize(limit)),
+		Fields:        common.String("name,size,etag,timeCreated"),
+	}
+	if token != "" {
+		request.Start = &token
+	}
+
+	rc, err := b.Client.ListObjects(b.Context, request)
+	if err != nil {
+		return page, err
+	}
+
+	for _, attrs := range rc.ListObjects.Objects {
+		path := strings.TrimPrefix(*attrs.Name, prefix)
+		if objectPathIsInvalid(path) {
+			continue
+		}
+		object := Object{
+			Path:    path,
+			Content: []byte{},
+		}
+		if attrs.TimeCreated != nil {
+			object.LastModified = attrs.TimeCreated.Time
+		}
+		if attrs.Size != nil {
+			object.Size = *attrs.Size
+		}
+		if attrs.Etag != nil {
+			object.ETag = *attrs.Etag
+		}
+		page.Objects = append(page.Objects, object)
+	}
+	if rc.ListObjects.NextStartWith != nil {
+		page.NextToken = *rc.ListObjects.NextStartWith
+	}
+	return page, nil
+}
+
+// ListObjectsIter iterates over the objects in OCI Object Storage bucket, at prefix
+func (b OracleCSBackend) ListObjectsIter(prefix string) iter.Seq2[Object, error] {
+	return listObjectsIter(b, prefix)
+}
+
+// ListObjectsWithOptions lists the objects and common prefixes below prefix in OCI Object Storage bucket.
+// OCI only supports "/" as the delimiter.
+func (b OracleCSBackend) ListObjectsWithOptions(prefix string, options ListObjectsOptions) (_ ListObjectsResult, err error) {
+	defer b.wrapError(&err, "ListObjects", prefix)
+	var result ListObjectsResult
+	prefix = listPrefix(b.Prefix, prefix)
+
+	request := objectstorage.ListObjectsRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		Prefix:        &prefix,
+		Fields:        common.String("name,size,etag,timeCreated"),
+	}
+	if delimiter := options.nativeDelimiter(); delimiter != "" {
+		request.Delimiter = &delimiter
+	}
+
+	for {
+		rc, err := b.Client.ListObjects(b.Context, request)
+		if err != nil {
+			return result, err
+		}
+
+		for _, attrs := range rc.ListObjects.Objects {
+			path := strings.TrimPrefix(*attrs.Name, prefix)
+			if objectPathIsInvalid(path) {
+				continue
+			}
+			object := Object{
+				Path:    path,
+				Content: []byte{},
+			}
+			if attrs.TimeCreated != nil {
+				object.LastModified = attrs.TimeCreated.Time
+			}
+			if attrs.Size != nil {
+				object.Size = *attrs.Size
+			}
+			if attrs.Etag != nil {
+				object.ETag = *attrs.Etag
+			}
+			result.Objects = append(result.Objects, object)
+		}
+		for _, commonPrefix := range rc.ListObjects.Prefixes {
+			result.CommonPrefixes = append(result.CommonPrefixes, strings.TrimPrefix(commonPrefix, prefix))
+		}
+
+		if rc.ListObjects.NextStartWith == nil {
+			break
+		}
+		request.Start = rc.ListObjects.NextStartWith
+	}
+	return result, nil
+}
+
 // GetObject retrieves an object from OCI Object Storage bucket, at prefix
 func (b OracleCSBackend) GetObject(path string) (Object, error) {
+	return b.GetObjectContext(b.Context, path)
+}
+
+// GetObjectContext retrieves an object from OCI Object Storage bucket, at prefix, using ctx for the request
+func (b OracleCSBackend) GetObjectContext(ctx context.Context, path string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObject", path)
+	return b.getObject(ctx, path, nil)
+}
+
+// GetObjectVersion retrieves a version of an object from OCI Object Storage bucket, at prefix
+func (b OracleCSBackend) GetObjectVersion(path string, versionID string) (_ Object, err error) {
+	defer b.wrapError(&err, "GetObjectVersion", path)
+	return b.getObject(b.Context, path, &versionID)
+}
+
+// getObject retrieves the version versionID of an object, or its current version if versionID is nil
+func (b OracleCSBackend) getObject(ctx context.Context, path string, versionID *string) (Object, error) {
 	var object Object
 	object.Path = path
 
@@ -178,15 +362,29 @@ func (b OracleCSBackend) GetObject(path string) (Object, error) {
 		NamespaceName: &b.Namespace,
 		BucketName:    &b.Bucket,
 		ObjectName:    &objectname,
+		VersionId:     versionID,
 	}
 
-	rc, err := b.Client.GetObject(b.Context, request)
+	rc, err := b.Client.GetObject(ctx, request)
 
 	if err != nil {
 		return object, err
 	}
 
 	object.LastModified = rc.LastModified.Time
+	if rc.ContentLength != nil {
+		object.Size = *rc.ContentLength
+	}
+	if rc.ETag != nil {
+		object.ETag = *rc.ETag
+	}
+	if rc.ContentType != nil {
+		object.ContentType = *rc.ContentType
+	}
+	object.UserMetadata = lowerCaseKeys(rc.OpcMeta)
+	if rc.VersionId != nil {
+		object.Meta = Metadata{Name: path, Version: *rc.VersionId}
+	}
 	content, err := ioutil.ReadAll(rc.Content)
 
 	if err != nil {
@@ -198,6 +396,12 @@ func (b OracleCSBackend) GetObject(path string) (Object, error) {
 
 // PutObject uploads an object to OCI Object Storage bucket, at prefix
 func (b OracleCSBackend) PutObject(path string, content []byte) error {
+	return b.PutObjectContext(b.Context, path, content)
+}
+
+// PutObjectContext uploads an object to OCI Object Storage bucket, at prefix, using ctx for the request
+func (b OracleCSBackend) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	defer b.wrapError(&err, "PutObject", path)
 
 	objectname := pathutil.Join(b.Prefix, path)
 	metadata := make(map[string]string)
@@ -213,12 +417,198 @@ func (b OracleCSBackend) PutObject(path string, content []byte) error {
 		OpcMeta:       metadata,
 	}
 
-	_, err := b.Client.PutObject(b.Context, request)
+	_, err = b.Client.PutObject(ctx, request)
+	return err
+}
+
+// PutObjectWithOptions uploads an object to OCI Object Storage bucket, at prefix, along with its content type
+// and user metadata. The storage tier of an object is inherited from its bucket, so the storage class is ignored.
+func (b OracleCSBackend) PutObjectWithOptions(path string, content []byte, options PutObjectOptions) (err error) {
+	defer b.wrapError(&err, "PutObjectWithOptions", path)
+
+	objectname := pathutil.Join(b.Prefix, path)
+	metadata := make(map[string]string)
+	for key, value := range options.UserMetadata {
+		metadata[key] = value
+	}
+	contentLen := int64(len(content))
+
+	request := objectstorage.PutObjectRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		ObjectName:    &objectname,
+		PutObjectBody: ioutil.NopCloser(bytes.NewReader(content)),
+		ContentLength: &contentLen,
+		OpcMeta:       metadata,
+	}
+	if options.ContentType != "" {
+		request.ContentType = &options.ContentType
+	}
+
+	_, err = b.Client.PutObject(b.Context, request)
+	return err
+}
+
+// PutObjectIf uploads an object to OCI Object Storage bucket, at prefix, if condition is met
+func (b OracleCSBackend) PutObjectIf(path string, content []byte, condition Condition) (err error) {
+	defer b.wrapError(&err, "PutObjectIf", path)
+	err = condition.validate()
+	if err != nil {
+		return err
+	}
+
+	objectname := pathutil.Join(b.Prefix, path)
+	contentLen := int64(len(content))
+
+	request := objectstorage.PutObjectRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		ObjectName:    &objectname,
+		PutObjectBody: ioutil.NopCloser(bytes.NewReader(content)),
+		ContentLength: &contentLen,
+		OpcMeta:       make(map[string]string),
+	}
+	if condition.IfNotExists {
+		request.IfNoneMatch = common.String("*")
+	}
+	if condition.IfMatch != "" {
+		request.IfMatch = &condition.IfMatch
+	}
+
+	_, err = b.Client.PutObject(b.Context, request)
+	return err
+}
+
+// GetObjectReader opens an object in OCI Object Storage bucket, at prefix, for reading
+func (b OracleCSBackend) GetObjectReader(path string) (_ io.ReadCloser, _ ObjectInfo, err error) {
+	defer b.wrapError(&err, "GetObjectReader", path)
+	info := ObjectInfo{Path: path}
+
+	objectname := pathutil.Join(b.Prefix, path)
+
+	request := objectstorage.GetObjectRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		ObjectName:    &objectname,
+	}
+
+	rc, err := b.Client.GetObject(b.Context, request)
+	if err != nil {
+		return nil, info, err
+	}
+
+	if rc.ContentLength != nil {
+		info.Size = *rc.ContentLength
+	}
+	if rc.LastModified != nil {
+		info.LastModified = rc.LastModified.Time
+	}
+	if rc.ETag != nil {
+		info.ETag = *rc.ETag
+	}
+	if rc.ContentType != nil {
+		info.ContentType = *rc.ContentType
+	}
+	info.UserMetadata = lowerCaseKeys(rc.OpcMeta)
+	return rc.Content, info, nil
+}
+
+// GetObjectRange reads part of an object from OCI Object Storage bucket, at prefix, with an HTTP range request
+func (b OracleCSBackend) GetObjectRange(path string, offset int64, length int64) (_ []byte, err error) {
+	defer b.wrapError(&err, "GetObjectRange", path)
+	objectname := pathutil.Join(b.Prefix, path)
+	return getObjectRange(offset, length, oracleCSErrorKind, func(offset int64, length int64) ([]byte, error) {
+		request := objectstorage.GetObjectRequest{
+			NamespaceName: &b.Namespace,
+			BucketName:    &b.Bucket,
+			ObjectName:    &objectname,
+			Range:         common.String(httpRange(offset, length)),
+		}
+		rc, err := b.Client.GetObject(b.Context, request)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Content.Close()
+		return io.ReadAll(rc.Content)
+	})
+}
+
+// PutObjectStream uploads an object to OCI Object Storage bucket, at prefix, from a stream.
+// Content of unknown size is sent as a multipart upload by the OCI upload manager.
+func (b OracleCSBackend) PutObjectStream(path string, content io.Reader, size int64) (err error) {
+	defer b.wrapError(&err, "PutObjectStream", path)
+
+	objectname := pathutil.Join(b.Prefix, path)
+
+	if size < 0 {
+		request := transfer.UploadStreamRequest{
+			UploadRequest: transfer.UploadRequest{
+				NamespaceName:       &b.Namespace,
+				BucketName:          &b.Bucket,
+				ObjectName:          &objectname,
+				ObjectStorageClient: &b.Client,
+			},
+			StreamReader: content,
+		}
+		_, err := transfer.NewUploadManager().UploadStream(b.Context, request)
+		return err
+	}
+
+	request := objectstorage.PutObjectRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		ObjectName:    &objectname,
+		PutObjectBody: ioutil.NopCloser(content),
+		ContentLength: &size,
+		OpcMeta:       make(map[string]string),
+	}
+
+	_, err = b.Client.PutObject(b.Context, request)
 	return err
 }
 
+// StatObject retrieves the information of an object in OCI Object Storage bucket, at prefix, with a HEAD request
+func (b OracleCSBackend) StatObject(path string) (_ ObjectInfo, err error) {
+	defer b.wrapError(&err, "StatObject", path)
+	info := ObjectInfo{Path: path}
+
+	objectname := pathutil.Join(b.Prefix, path)
+
+	request := objectstorage.HeadObjectRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		ObjectName:    &objectname,
+	}
+
+	rc, err := b.Client.HeadObject(b.Context, request)
+	if err != nil {
+		return info, err
+	}
+
+	if rc.ContentLength != nil {
+		info.Size = *rc.ContentLength
+	}
+	if rc.LastModified != nil {
+		info.LastModified = rc.LastModified.Time
+	}
+	if rc.ETag != nil {
+		info.ETag = *rc.ETag
+	}
+	if rc.ContentType != nil {
+		info.ContentType = *rc.ContentType
+	}
+	info.UserMetadata = lowerCaseKeys(rc.OpcMeta)
+	return info, nil
+}
+
 // DeleteObject removes an object from OCI Object Storage bucket, at prefix
 func (b OracleCSBackend) DeleteObject(path string) error {
+	return b.DeleteObjectContext(b.Context, path)
+}
+
+// DeleteObjectContext removes an object from OCI Object Storage bucket, at prefix, using ctx for the request
+func (b OracleCSBackend) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	defer b.wrapError(&err, "DeleteObject", path)
 
 	objectname := pathutil.Join(b.Prefix, path)
 
@@ -228,6 +618,226 @@ func (b OracleCSBackend) DeleteObject(path string) error {
 		ObjectName:    &objectname,
 	}
 
-	_, err := b.Client.DeleteObject(b.Context, request)
+	_, err = b.Client.DeleteObject(ctx, request)
 	return err
 }
+
+// DeleteObjects removes objects from OCI Object Storage bucket, at prefix, deleting up to
+// maxParallelDeletes objects at a time
+func (b OracleCSBackend) DeleteObjects(paths []string) ([]DeleteResult, error) {
+	return deleteObjectsInParallel(paths, b.DeleteObject), nil
+}
+
+// CopyObject copies an object within OCI Object Storage bucket, at prefix, and waits until the copy,
+// performed asynchronously by the storage service, is complete
+func (b OracleCSBackend) CopyObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "CopyObject", src)
+	request := objectstorage.CopyObjectRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		CopyObjectDetails: objectstorage.CopyObjectDetails{
+			SourceObjectName:      common.String(pathutil.Join(b.Prefix, src)),
+			DestinationRegion:     &b.Region,
+			DestinationNamespace:  &b.Namespace,
+			DestinationBucket:     &b.Bucket,
+			DestinationObjectName: common.String(pathutil.Join(b.Prefix, dst)),
+		},
+	}
+	response, err := b.Client.CopyObject(b.Context, request)
+	if err != nil {
+		return err
+	}
+	return b.waitForWorkRequest(*response.OpcWorkRequestId)
+}
+
+// waitForWorkRequest polls the status of a work request until it is finished
+func (b OracleCSBackend) waitForWorkRequest(id string) error {
+	for {
+		response, err := b.Client.GetWorkRequest(b.Context, objectstorage.GetWorkRequestRequest{WorkRequestId: &id})
+		if err != nil {
+			return err
+		}
+		switch response.Status {
+		case objectstorage.WorkRequestStatusCompleted:
+			return nil
+		case objectstorage.WorkRequestStatusFailed, objectstorage.WorkRequestStatusCanceled:
+			return fmt.Errorf("work request %s %s", id, strings.ToLower(string(response.Status)))
+		}
+		select {
+		case <-b.Context.Done():
+			return b.Context.Err()
+		case <-time.After(oracleWorkRequestPollInterval):
+		}
+	}
+}
+
+// MoveObject renames an object within OCI Object Storage bucket, at prefix, in a single atomic request
+func (b OracleCSBackend) MoveObject(src string, dst string) (err error) {
+	defer b.wrapError(&err, "MoveObject", src)
+	if samePath(src, dst) {
+		return nil
+	}
+	request := objectstorage.RenameObjectRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		RenameObjectDetails: objectstorage.RenameObjectDetails{
+			SourceName: common.String(pathutil.Join(b.Prefix, src)),
+			NewName:    common.String(pathutil.Join(b.Prefix, dst)),
+		},
+	}
+	_, err = b.Client.RenameObject(b.Context, request)
+	return err
+}
+
+// PresignGet creates a pre-authenticated request to download an object from OCI Object Storage bucket, at prefix,
+// until ttl expires, and returns its URL.
+// Unlike signed URLs, each pre-authenticated request is a resource of the bucket, see preauthenticatedRequest.
+func (b OracleCSBackend) PresignGet(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignGet", path)
+	return b.preauthenticatedRequest(path, objectstorage.CreatePreauthenticatedRequestDetailsAccessTypeObjectread, ttl)
+}
+
+// PresignPut creates a pre-authenticated request to upload an object to OCI Object Storage bucket, at prefix,
+// until ttl expires, and returns its URL.
+// Unlike signed URLs, each pre-authenticated request is a resource of the bucket, see preauthenticatedRequest.
+func (b OracleCSBackend) PresignPut(path string, ttl time.Duration) (_ string, err error) {
+	defer b.wrapError(&err, "PresignPut", path)
+	return b.preauthenticatedRequest(path, objectstorage.CreatePreauthenticatedRequestDetailsAccessTypeObjectwrite, ttl)
+}
+
+// preauthenticatedRequest creates a pre-authenticated request granting accessType to an object until ttl expires.
+// Pre-authenticated requests are persisted in the bucket: expired ones are still listed until they are deleted,
+// and a bucket only holds a limited number of them. Each call therefore first deletes the expired requests
+// this backend created for the same object and access type, so they do not pile up for objects presigned repeatedly.
+// The cleanup is best effort; requests of objects that are never presigned again must be deleted out of band,
+// e.g. with `oci os preauth-request list` and `oci os preauth-request delete`.
+func (b OracleCSBackend) preauthenticatedRequest(path string, accessType objectstorage.CreatePreauthenticatedRequestDetailsAccessTypeEnum, ttl time.Duration) (string, error) {
+	if err := validatePresignTTL(ttl); err != nil {
+		return "", err
+	}
+	objectname := pathutil.Join(b.Prefix, path)
+	name := fmt.Sprintf("%s-%s-", accessType, objectname)
+	b.deleteExpiredPreauthenticatedRequests(objectname, name)
+	expires := time.Now().Add(ttl)
+	request := objectstorage.CreatePreauthenticatedRequestRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		CreatePreauthenticatedRequestDetails: objectstorage.CreatePreauthenticatedRequestDetails{
+			Name:        common.String(fmt.Sprintf("%s%d", name, expires.UnixNano())),
+			AccessType:  accessType,
+			TimeExpires: &common.SDKTime{Time: expires},
+			ObjectName:  &objectname,
+		},
+	}
+	response, err := b.Client.CreatePreauthenticatedRequest(b.Context, request)
+	if err != nil {
+		return "", err
+	}
+	return b.Client.Host + *response.AccessUri, nil
+}
+
+// deleteExpiredPreauthenticatedRequests deletes the expired pre-authenticated requests of objectname
+// whose name starts with name, ignoring errors
+func (b OracleCSBackend) deleteExpiredPreauthenticatedRequests(objectname string, name string) {
+	request := objectstorage.ListPreauthenticatedRequestsRequest{
+		NamespaceName:    &b.Namespace,
+		BucketName:       &b.Bucket,
+		ObjectNamePrefix: &objectname,
+	}
+	now := time.Now()
+	for {
+		response, err := b.Client.ListPreauthenticatedRequests(b.Context, request)
+		if err != nil {
+			return
+		}
+		for _, par := range response.Items {
+			if par.Id == nil || par.Name == nil || par.TimeExpires == nil ||
+				!strings.HasPrefix(*par.Name, name) || par.TimeExpires.Time.After(now) {
+				continue
+			}
+			b.Client.DeletePreauthenticatedRequest(b.Context, objectstorage.DeletePreauthenticatedRequestRequest{
+				NamespaceName: &b.Namespace,
+				BucketName:    &b.Bucket,
+				ParId:         par.Id,
+			})
+		}
+		if response.OpcNextPage == nil {
+			return
+		}
+		request.Page = response.OpcNextPage
+	}
+}
+
+// ListObjectVersions lists all versions of the objects in OCI Object Storage bucket, at prefix.
+// Versions are only kept by buckets with object versioning enabled; delete markers are not listed.
+func (b OracleCSBackend) ListObjectVersions(prefix string) (_ []Object, err error) {
+	defer b.wrapError(&err, "ListObjectVersions", prefix)
+	var objects []Object
+	prefix = pathutil.Join(b.Prefix, prefix)
+	request := objectstorage.ListObjectVersionsRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		Prefix:        &prefix,
+		Fields:        objectstorage.ListObjectVersionsFieldsEnum("name,size,etag,timeModified"),
+	}
+	for {
+		response, err := b.Client.ListObjectVersions(b.Context, request)
+		if err != nil {
+			return objects, err
+		}
+		for _, version := range response.Items {
+			path := removePrefixFromObjectPath(prefix, *version.Name)
+			if objectPathIsInvalid(path) || (version.IsDeleteMarker != nil && *version.IsDeleteMarker) {
+				continue
+			}
+			object := Object{
+				Meta:    Metadata{Name: path, Version: *version.VersionId},
+				Path:    path,
+				Content: []byte{},
+			}
+			if version.TimeModified != nil {
+				object.LastModified = version.TimeModified.Time
+			}
+			if version.Size != nil {
+				object.Size = *version.Size
+			}
+			if version.Etag != nil {
+				object.ETag = *version.Etag
+			}
+			objects = append(objects, object)
+		}
+		if response.OpcNextPage == nil {
+			break
+		}
+		request.Page = response.OpcNextPage
+	}
+	return objects, nil
+}
+
+// DeleteObjectVersion permanently removes a version of an object from OCI Object Storage bucket, at prefix
+func (b OracleCSBackend) DeleteObjectVersion(path string, versionID string) (err error) {
+	defer b.wrapError(&err, "DeleteObjectVersion", path)
+	objectname := pathutil.Join(b.Prefix, path)
+	request := objectstorage.DeleteObjectRequest{
+		NamespaceName: &b.Namespace,
+		BucketName:    &b.Bucket,
+		ObjectName:    &objectname,
+		VersionId:     &versionID,
+	}
+	_, err = b.Client.DeleteObject(b.Context, request)
+	return err
+}
+
+func (b OracleCSBackend) wrapError(err *error, op string, path string) {
+	if wrapStorageError(err, "OracleCS", op, path, oracleCSErrorKind) {
+		logStorageError(b.logger, *err)
+	}
+}
+
+func oracleCSErrorKind(err error) error {
+	var serviceErr common.ServiceError
+	if errors.As(err, &serviceErr) {
+		return kindFromStatusCode(serviceErr.GetHTTPStatusCode())
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package prometheus exports the measurements of a storage.InstrumentedBackend as Prometheus metrics.
+// It is kept apart from the storage package, so that only its importers depend on the Prometheus client.
+package prometheus
+
+import (
+	"errors"
+	"time"
+
+	"github.com/chartmuseum/storage"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsSink is a storage.MetricsSink exporting Prometheus metrics:
+//
+//   - storage_operations_total, a counter labelled by backend, operation and outcome
+//   - storage_operation_duration_seconds, a histogram labelled by backend, operation and outcome
+//   - storage_bytes_total, a counter labelled by backend and operation
+//   - storage_listed_objects, a histogram labelled by backend
+type MetricsSink struct {
+	operations *prometheus.CounterVec
+	durations  *prometheus.HistogramVec
+	bytes      *prometheus.CounterVec
+	listings   *prometheus.HistogramVec
+}
+
+var _ storage.MetricsSink = (*MetricsSink)(nil)
+
+// NewMetricsSink creates a new instance of MetricsSink, registering its metrics with
+// registerer under namespace, which may be empty. The metrics already registered by another
+// MetricsSink with the same namespace are shared.
+func NewMetricsSink(registerer prometheus.Registerer, namespace string) (*MetricsSink, error) {
+	sink := &MetricsSink{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operations_total",
+			Help:      "Number of storage operations, by backend, operation and outcome.",
+		}, []string{"backend", "operation", "outcome"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of storage operations, by backend, operation and outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"backend", "operation", "outcome"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "bytes_total",
+			Help:      "Size of the content read and written by storage operations, by backend and operation.",
+		}, []string{"backend", "operation"}),
+		listings: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "listed_objects",
+			Help:      "Number of objects returned by storage listings, by backend.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		}, []string{"backend"}),
+	}
+	var err error
+	if sink.operations, err = register(registerer, sink.operations); err != nil {
+		return nil, err
+	}
+	if sink.durations, err = register(registerer, sink.durations); err != nil {
+		return nil, err
+	}
+	if sink.bytes, err = register(registerer, sink.bytes); err != nil {
+		return nil, err
+	}
+	if sink.listings, err = register(registerer, sink.listings); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// register registers a collector, or returns the identical collector already registered
+func register[C prometheus.Collector](registerer prometheus.Registerer, collector C) (C, error) {
+	err := registerer.Register(collector)
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		if existing, ok := registered.ExistingCollector.(C); ok {
+			return existing, nil
+		}
+	}
+	return collector, err
+}
+
+// ObserveOperation increments storage_operations_total and observes storage_operation_duration_seconds
+func (s *MetricsSink) ObserveOperation(backend string, op string, outcome string, duration time.Duration) {
+	s.operations.WithLabelValues(backend, op, outcome).Inc()
+	s.durations.WithLabelValues(backend, op, outcome).Observe(duration.Seconds())
+}
+
+// ObserveBytes adds to storage_bytes_total
+func (s *MetricsSink) ObserveBytes(backend string, op string, bytes int64) {
+	s.bytes.WithLabelValues(backend, op).Add(float64(bytes))
+}
+
+// ObserveListing observes storage_listed_objects
+func (s *MetricsSink) ObserveListing(backend string, objects int) {
+	s.listings.WithLabelValues(backend).Observe(float64(objects))
+}
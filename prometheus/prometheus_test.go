@@ -0,0 +1,74 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prometheus
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chartmuseum/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsSinkTestSuite struct {
+	suite.Suite
+	TempDirectory string
+	Local         *storage.LocalFilesystemBackend
+}
+
+func (suite *MetricsSinkTestSuite) SetupTest() {
+	timestamp := time.Now().Format("20060102150405.000000")
+	suite.TempDirectory = fmt.Sprintf("../../../.test/storage-prometheus/%s", timestamp)
+	suite.Local = storage.NewLocalFilesystemBackend(suite.TempDirectory)
+}
+
+func (suite *MetricsSinkTestSuite) TearDownTest() {
+	os.RemoveAll(suite.TempDirectory)
+}
+
+func (suite *MetricsSinkTestSuite) TestMetricsSink() {
+	registry := prometheus.NewRegistry()
+	sink, err := NewMetricsSink(registry, "chartmuseum")
+	suite.Nil(err, "no error creating prometheus sink")
+	instrumented := storage.NewInstrumentedBackend(suite.Local, "LocalFilesystem", sink)
+
+	suite.Nil(instrumented.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+	_, err = instrumented.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err)
+	_, err = instrumented.GetObject("missing.tgz")
+	suite.NotNil(err)
+	_, err = instrumented.ListObjects("")
+	suite.Nil(err)
+
+	suite.Equal(1.0, testutil.ToFloat64(sink.operations.WithLabelValues("LocalFilesystem", "GetObject", storage.OutcomeSuccess)))
+	suite.Equal(1.0, testutil.ToFloat64(sink.operations.WithLabelValues("LocalFilesystem", "GetObject", storage.OutcomeNotFound)))
+	suite.Equal(float64(len("mychart")), testutil.ToFloat64(sink.bytes.WithLabelValues("LocalFilesystem", "PutObject")))
+	suite.Equal(4, testutil.CollectAndCount(sink.durations, "chartmuseum_storage_operation_duration_seconds"))
+	suite.Equal(1, testutil.CollectAndCount(sink.listings, "chartmuseum_storage_listed_objects"))
+
+	shared, err := NewMetricsSink(registry, "chartmuseum")
+	suite.Nil(err, "metrics already registered are shared")
+	suite.Equal(sink.operations, shared.operations)
+}
+
+func TestMetricsSinkTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsSinkTestSuite))
+}
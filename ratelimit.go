@@ -0,0 +1,291 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitedOps lists the operations limited by a RateLimitedBackend, by the name of their Backend method
+var rateLimitedOps = []string{"ListObjects", "GetObject", "PutObject", "DeleteObject"}
+
+type (
+	// RateLimit is a token bucket limiting the rate of an operation
+	RateLimit struct {
+		// PerSecond is the number of requests allowed per second on average; zero means unlimited
+		PerSecond float64
+		// Burst is the number of requests allowed at once. The integer above PerSecond, and at least 1,
+		// is used if Burst is not positive.
+		Burst int
+	}
+
+	// RateLimitOptions configures a RateLimitedBackend
+	RateLimitOptions struct {
+		// Limits holds the rate limits of the operations, keyed by the name of the Backend method,
+		// such as "GetObject". Operations without a limit are unlimited.
+		Limits map[string]RateLimit
+		// MaxConcurrency bounds the number of operations in progress at once; zero means unlimited
+		MaxConcurrency int
+		// BytesPerSecond bounds the bandwidth used by the content of the objects read and written;
+		// zero means unlimited
+		BytesPerSecond int64
+	}
+
+	// RateLimitedBackend is a Backend which limits the rate, concurrency and bandwidth of the operations
+	// made on another Backend, so that bulk jobs do not get the client throttled by the storage service.
+	// Operations wait until they are allowed to proceed. The limits can be changed at any time with
+	// SetOptions.
+	RateLimitedBackend struct {
+		backend        Backend
+		contextBackend ContextBackend
+		concurrency    *concurrencyLimiter
+
+		mu        sync.Mutex
+		options   RateLimitOptions
+		limiters  map[string]*rate.Limiter
+		bandwidth *rate.Limiter
+	}
+
+	// concurrencyLimiter is a semaphore whose size can change while it is held
+	concurrencyLimiter struct {
+		mu     sync.Mutex
+		max    int
+		active int
+		// released is closed, and replaced, whenever a slot may have become available
+		released chan struct{}
+	}
+)
+
+// NewRateLimitedBackend creates a new instance of RateLimitedBackend limiting the operations made on backend
+func NewRateLimitedBackend(backend Backend, options RateLimitOptions) *RateLimitedBackend {
+	r := &RateLimitedBackend{
+		backend:        backend,
+		contextBackend: NewContextBackend(backend),
+		limiters:       make(map[string]*rate.Limiter, len(rateLimitedOps)),
+		concurrency:    &concurrencyLimiter{released: make(chan struct{})},
+	}
+	r.SetOptions(options)
+	return r
+}
+
+// Options returns the limits currently applied by the RateLimitedBackend
+func (r *RateLimitedBackend) Options() RateLimitOptions {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	options := r.options
+	options.Limits = make(map[string]RateLimit, len(r.options.Limits))
+	for op, limit := range r.options.Limits {
+		options.Limits[op] = limit
+	}
+	return options
+}
+
+// SetOptions replaces the limits applied by the RateLimitedBackend. Operations waiting for a slot
+// are subject to the new MaxConcurrency, while those already waiting for a token keep their turn.
+func (r *RateLimitedBackend) SetOptions(options RateLimitOptions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	limits := make(map[string]RateLimit, len(options.Limits))
+	for op, limit := range options.Limits {
+		limits[op] = limit
+	}
+	options.Limits = limits
+	r.options = options
+
+	for _, op := range rateLimitedOps {
+		limit := options.Limits[op]
+		burst := limit.Burst
+		if burst <= 0 {
+			burst = max(int(math.Ceil(limit.PerSecond)), 1)
+		}
+		r.limiters[op] = updateLimiter(r.limiters[op], limit.PerSecond, burst)
+	}
+	r.bandwidth = updateLimiter(r.bandwidth, float64(options.BytesPerSecond), int(min(options.BytesPerSecond, math.MaxInt32)))
+	r.concurrency.resize(options.MaxConcurrency)
+}
+
+// Capabilities returns the optional features supported by the RateLimitedBackend, which accepts contexts
+// and lists content if the limited backend does
+func (r *RateLimitedBackend) Capabilities() CapabilitySet {
+	return CapabilitiesOf(r.backend)&NewCapabilitySet(CapabilityListContent) | NewCapabilitySet(CapabilityContext)
+}
+
+// ListObjects lists all objects at prefix, once allowed by the limits
+func (r *RateLimitedBackend) ListObjects(prefix string) ([]Object, error) {
+	return r.ListObjectsContext(context.Background(), prefix)
+}
+
+// GetObject retrieves an object at path, once allowed by the limits
+func (r *RateLimitedBackend) GetObject(path string) (Object, error) {
+	return r.GetObjectContext(context.Background(), path)
+}
+
+// PutObject uploads an object to path, once allowed by the limits
+func (r *RateLimitedBackend) PutObject(path string, content []byte) error {
+	return r.PutObjectContext(context.Background(), path, content)
+}
+
+// DeleteObject removes an object at path, once allowed by the limits
+func (r *RateLimitedBackend) DeleteObject(path string) error {
+	return r.DeleteObjectContext(context.Background(), path)
+}
+
+// ListObjectsContext lists all objects at prefix, once allowed by the limits or until ctx is done
+func (r *RateLimitedBackend) ListObjectsContext(ctx context.Context, prefix string) ([]Object, error) {
+	release, err := r.acquire(ctx, "ListObjects")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.contextBackend.ListObjectsContext(ctx, prefix)
+}
+
+// GetObjectContext retrieves an object at path, once allowed by the limits or until ctx is done.
+// The bandwidth used by the content of the object delays the following operations.
+func (r *RateLimitedBackend) GetObjectContext(ctx context.Context, path string) (Object, error) {
+	release, err := r.acquire(ctx, "GetObject")
+	if err != nil {
+		return Object{Path: path}, err
+	}
+	object, err := r.contextBackend.GetObjectContext(ctx, path)
+	release()
+	if err != nil {
+		return object, err
+	}
+	// the content was read at once, so its bandwidth is accounted for after the fact
+	if err := r.waitBandwidth(ctx, len(object.Content)); err != nil {
+		return Object{Path: path}, err
+	}
+	return object, nil
+}
+
+// PutObjectContext uploads an object to path, once allowed by the limits, including the bandwidth
+// used by its content, or until ctx is done
+func (r *RateLimitedBackend) PutObjectContext(ctx context.Context, path string, content []byte) error {
+	if err := r.waitBandwidth(ctx, len(content)); err != nil {
+		return err
+	}
+	release, err := r.acquire(ctx, "PutObject")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return r.contextBackend.PutObjectContext(ctx, path, content)
+}
+
+// DeleteObjectContext removes an object at path, once allowed by the limits or until ctx is done
+func (r *RateLimitedBackend) DeleteObjectContext(ctx context.Context, path string) error {
+	release, err := r.acquire(ctx, "DeleteObject")
+	if err != nil {
+		return err
+	}
+	defer release()
+	return r.contextBackend.DeleteObjectContext(ctx, path)
+}
+
+// acquire waits until the rate limit of op and the concurrency limit allow an operation to proceed,
+// returning the function which ends the operation
+func (r *RateLimitedBackend) acquire(ctx context.Context, op string) (func(), error) {
+	r.mu.Lock()
+	limiter := r.limiters[op]
+	r.mu.Unlock()
+	if err := limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	if err := r.concurrency.acquire(ctx); err != nil {
+		return nil, err
+	}
+	return r.concurrency.release, nil
+}
+
+// waitBandwidth waits until the bandwidth limit allows n bytes to be transferred
+func (r *RateLimitedBackend) waitBandwidth(ctx context.Context, n int) error {
+	r.mu.Lock()
+	bandwidth := r.bandwidth
+	r.mu.Unlock()
+	// WaitN does not allow more than the burst of the limiter at once, so larger contents wait in chunks
+	for n > 0 {
+		chunk := n
+		if burst := bandwidth.Burst(); bandwidth.Limit() != rate.Inf && chunk > burst {
+			chunk = burst
+		}
+		if err := bandwidth.WaitN(ctx, chunk); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// updateLimiter applies a rate, with zero meaning unlimited, and a burst to a limiter. A limiter which was
+// unlimited holds no tokens, so it is replaced with a new one, allowing a full burst.
+func updateLimiter(limiter *rate.Limiter, perSecond float64, burst int) *rate.Limiter {
+	if perSecond <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	if limiter == nil || limiter.Limit() == rate.Inf {
+		return rate.NewLimiter(rate.Limit(perSecond), burst)
+	}
+	limiter.SetLimit(rate.Limit(perSecond))
+	limiter.SetBurst(burst)
+	return limiter
+}
+
+// acquire waits until fewer operations than the maximum are in progress, or until ctx is done
+func (c *concurrencyLimiter) acquire(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if c.max <= 0 || c.active < c.max {
+			c.active++
+			c.mu.Unlock()
+			return nil
+		}
+		released := c.released
+		c.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// release ends an operation acquired from the limiter
+func (c *concurrencyLimiter) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+	c.notify()
+}
+
+// resize changes the maximum number of operations in progress; zero means unlimited
+func (c *concurrencyLimiter) resize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.max = size
+	c.notify()
+}
+
+// notify wakes up the operations waiting for a slot; the lock must be held
+func (c *concurrencyLimiter) notify() {
+	close(c.released)
+	c.released = make(chan struct{})
+}
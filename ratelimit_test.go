@@ -0,0 +1,162 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// blockingBackend holds the calls made to GetObject until unblocked, recording how many are in progress at once
+type blockingBackend struct {
+	Backend
+	unblock chan struct{}
+
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func (b *blockingBackend) GetObject(path string) (Object, error) {
+	b.mu.Lock()
+	b.active++
+	b.maxActive = max(b.maxActive, b.active)
+	b.mu.Unlock()
+	<-b.unblock
+	b.mu.Lock()
+	b.active--
+	b.mu.Unlock()
+	return b.Backend.GetObject(path)
+}
+
+func (b *blockingBackend) Active() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.active
+}
+
+type RateLimitTestSuite struct {
+	suite.Suite
+	TempDirectory string
+	Local         *LocalFilesystemBackend
+}
+
+func (suite *RateLimitTestSuite) SetupTest() {
+	timestamp := time.Now().Format("20060102150405.000000")
+	suite.TempDirectory = fmt.Sprintf("../../.test/storage-ratelimit/%s", timestamp)
+	suite.Local = NewLocalFilesystemBackend(suite.TempDirectory)
+	suite.Nil(suite.Local.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+}
+
+func (suite *RateLimitTestSuite) TearDownTest() {
+	os.RemoveAll(suite.TempDirectory)
+}
+
+// eventually waits for condition to hold
+func (suite *RateLimitTestSuite) eventually(condition func() bool, msg string) {
+	suite.Eventually(condition, time.Second, time.Millisecond, msg)
+}
+
+func (suite *RateLimitTestSuite) TestRateLimit() {
+	limited := NewRateLimitedBackend(suite.Local, RateLimitOptions{
+		Limits: map[string]RateLimit{"GetObject": {PerSecond: 50, Burst: 1}},
+	})
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := limited.GetObject("mychart-0.1.0.tgz")
+		suite.Nil(err, "no error getting object through rate limiter")
+	}
+	suite.True(time.Since(start) >= 50*time.Millisecond, "requests beyond the burst wait for tokens")
+
+	start = time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := limited.ListObjects("")
+		suite.Nil(err)
+	}
+	suite.True(time.Since(start) < 50*time.Millisecond, "operations without a limit do not wait")
+
+	limited.SetOptions(RateLimitOptions{})
+	start = time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := limited.GetObject("mychart-0.1.0.tgz")
+		suite.Nil(err)
+	}
+	suite.True(time.Since(start) < 50*time.Millisecond, "removed limit no longer applies")
+
+	limited.SetOptions(RateLimitOptions{Limits: map[string]RateLimit{"DeleteObject": {PerSecond: 0.001}}})
+	suite.Nil(limited.DeleteObject("mychart-0.1.0.tgz"), "burst allows the first request")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := limited.DeleteObjectContext(ctx, "mychart-0.1.0.tgz")
+	suite.NotNil(err, "waiting for a token stops when the context is done")
+}
+
+func (suite *RateLimitTestSuite) TestMaxConcurrency() {
+	blocking := &blockingBackend{Backend: suite.Local, unblock: make(chan struct{})}
+	limited := NewRateLimitedBackend(blocking, RateLimitOptions{MaxConcurrency: 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := limited.GetObject("mychart-0.1.0.tgz")
+			suite.Nil(err)
+		}()
+	}
+	suite.eventually(func() bool { return blocking.Active() == 2 }, "operations up to MaxConcurrency proceed")
+
+	options := limited.Options()
+	options.MaxConcurrency = 3
+	limited.SetOptions(options)
+	suite.eventually(func() bool { return blocking.Active() == 3 }, "raised MaxConcurrency applies to waiting operations")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := limited.GetObjectContext(ctx, "mychart-0.1.0.tgz")
+	suite.True(errors.Is(err, context.DeadlineExceeded), "waiting for a slot stops when the context is done")
+
+	close(blocking.unblock)
+	wg.Wait()
+	suite.Equal(3, blocking.maxActive, "operations in progress never exceed MaxConcurrency")
+}
+
+func (suite *RateLimitTestSuite) TestBandwidth() {
+	limited := NewRateLimitedBackend(suite.Local, RateLimitOptions{BytesPerSecond: 1000})
+	suite.Nil(suite.Local.PutObject("small.tgz", make([]byte, 200)))
+
+	start := time.Now()
+	suite.Nil(limited.PutObject("large.tgz", make([]byte, 1000)), "no error putting object through bandwidth limiter")
+	suite.True(time.Since(start) < 100*time.Millisecond, "content within a second of bandwidth does not wait")
+	start = time.Now()
+	object, err := limited.GetObject("small.tgz")
+	suite.Nil(err, "no error getting object through bandwidth limiter")
+	suite.Len(object.Content, 200)
+	suite.True(time.Since(start) >= 150*time.Millisecond, "content beyond the bandwidth waits")
+}
+
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
@@ -0,0 +1,163 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	pathutil "path"
+	"strings"
+)
+
+// ReadOnlyBackend is a Backend which forbids mutating another Backend. In read-only mode, PutObject and
+// DeleteObject fail with an error matching ErrReadOnly without calling the backend. In write-once mode,
+// objects can be created, but the objects matching the write-once patterns can neither be overwritten
+// nor deleted, which fails with an error matching ErrObjectImmutable.
+// Both errors are StorageErrors of kind ErrPermissionDenied.
+type ReadOnlyBackend struct {
+	backend        Backend
+	contextBackend ContextBackend
+	name           string
+	writeOnce      bool
+	patterns       []string
+}
+
+// NewReadOnlyBackend creates a new instance of ReadOnlyBackend rejecting every mutation of backend
+func NewReadOnlyBackend(backend Backend) *ReadOnlyBackend {
+	return &ReadOnlyBackend{
+		backend:        backend,
+		contextBackend: NewContextBackend(backend),
+		name:           backendName(backend),
+	}
+}
+
+// NewWriteOnceBackend creates a new instance of ReadOnlyBackend in write-once mode, rejecting the overwriting
+// and deletion of the objects of backend whose path matches one of patterns, or of every object if there
+// are no patterns. Patterns use the syntax of path.Match; a pattern without a slash, such as "*.tgz", is
+// matched against the last element of the path.
+func NewWriteOnceBackend(backend Backend, patterns ...string) (*ReadOnlyBackend, error) {
+	for _, pattern := range patterns {
+		if _, err := pathutil.Match(pattern, ""); err != nil {
+			return nil, configError("ReadOnly", "invalid write-once pattern %q: %s", pattern, err)
+		}
+	}
+	r := NewReadOnlyBackend(backend)
+	r.writeOnce = true
+	r.patterns = patterns
+	return r, nil
+}
+
+// Capabilities returns the optional features supported by the ReadOnlyBackend, which accepts contexts
+// and lists content if the protected backend does
+func (r *ReadOnlyBackend) Capabilities() CapabilitySet {
+	return CapabilitiesOf(r.backend)&NewCapabilitySet(CapabilityListContent) | NewCapabilitySet(CapabilityContext)
+}
+
+// ListObjects lists all objects at prefix
+func (r *ReadOnlyBackend) ListObjects(prefix string) ([]Object, error) {
+	return r.ListObjectsContext(context.Background(), prefix)
+}
+
+// GetObject retrieves an object at path
+func (r *ReadOnlyBackend) GetObject(path string) (Object, error) {
+	return r.GetObjectContext(context.Background(), path)
+}
+
+// PutObject uploads an object to path, unless the backend is read-only or the object is write-once and exists
+func (r *ReadOnlyBackend) PutObject(path string, content []byte) error {
+	return r.PutObjectContext(context.Background(), path, content)
+}
+
+// DeleteObject removes an object at path, unless the backend is read-only or the object is write-once
+func (r *ReadOnlyBackend) DeleteObject(path string) error {
+	return r.DeleteObjectContext(context.Background(), path)
+}
+
+// ListObjectsContext lists all objects at prefix
+func (r *ReadOnlyBackend) ListObjectsContext(ctx context.Context, prefix string) ([]Object, error) {
+	return r.contextBackend.ListObjectsContext(ctx, prefix)
+}
+
+// GetObjectContext retrieves an object at path
+func (r *ReadOnlyBackend) GetObjectContext(ctx context.Context, path string) (Object, error) {
+	return r.contextBackend.GetObjectContext(ctx, path)
+}
+
+// PutObjectContext uploads an object to path, unless the backend is read-only or the object is write-once
+// and exists. Write-once objects are created with a conditional write if the backend supports it, and after
+// checking they do not exist otherwise.
+func (r *ReadOnlyBackend) PutObjectContext(ctx context.Context, path string, content []byte) error {
+	if !r.writeOnce {
+		return r.forbidden("PutObject", path, ErrReadOnly)
+	}
+	if !r.isWriteOnce(path) {
+		return r.contextBackend.PutObjectContext(ctx, path, content)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if cb, ok := r.backend.(ConditionalBackend); ok && Supports(r.backend, CapabilityConditional) {
+		err := cb.PutObjectIf(path, content, Condition{IfNotExists: true})
+		if errors.Is(err, ErrPreconditionFailed) {
+			return r.forbidden("PutObject", path, ErrObjectImmutable)
+		}
+		if !errors.Is(err, ErrNotSupported) {
+			return err
+		}
+	}
+	_, err := StatObject(r.backend, path)
+	if err == nil {
+		return r.forbidden("PutObject", path, ErrObjectImmutable)
+	}
+	if !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+	return r.contextBackend.PutObjectContext(ctx, path, content)
+}
+
+// DeleteObjectContext removes an object at path, unless the backend is read-only or the object is write-once
+func (r *ReadOnlyBackend) DeleteObjectContext(ctx context.Context, path string) error {
+	if !r.writeOnce {
+		return r.forbidden("DeleteObject", path, ErrReadOnly)
+	}
+	if r.isWriteOnce(path) {
+		return r.forbidden("DeleteObject", path, ErrObjectImmutable)
+	}
+	return r.contextBackend.DeleteObjectContext(ctx, path)
+}
+
+// isWriteOnce determines whether or not the object at path matches one of the write-once patterns
+func (r *ReadOnlyBackend) isWriteOnce(path string) bool {
+	if len(r.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range r.patterns {
+		name := path
+		if !strings.Contains(pattern, "/") {
+			name = pathutil.Base(path)
+		}
+		if matched, _ := pathutil.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// forbidden returns the StorageError of a mutation rejected with err
+func (r *ReadOnlyBackend) forbidden(op string, path string, err error) error {
+	return &StorageError{Backend: r.name, Op: op, Path: path, Kind: ErrPermissionDenied, Err: err}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReadOnlyTestSuite struct {
+	suite.Suite
+	TempDirectory string
+	Local         *LocalFilesystemBackend
+}
+
+func (suite *ReadOnlyTestSuite) SetupTest() {
+	timestamp := time.Now().Format("20060102150405.000000")
+	suite.TempDirectory = fmt.Sprintf("../../.test/storage-readonly/%s", timestamp)
+	suite.Local = NewLocalFilesystemBackend(suite.TempDirectory)
+	suite.Nil(suite.Local.PutObject("mychart-0.1.0.tgz", []byte("mychart")))
+	suite.Nil(suite.Local.PutObject("index.yaml", []byte("entries: {}")))
+}
+
+func (suite *ReadOnlyTestSuite) TearDownTest() {
+	os.RemoveAll(suite.TempDirectory)
+}
+
+func (suite *ReadOnlyTestSuite) TestReadOnly() {
+	counting := newCountingBackend(suite.Local)
+	readOnly := NewReadOnlyBackend(counting)
+
+	objects, err := readOnly.ListObjects("")
+	suite.Nil(err, "no error listing objects through read-only backend")
+	suite.Len(objects, 2)
+	object, err := readOnly.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err, "no error getting object through read-only backend")
+	suite.Equal([]byte("mychart"), object.Content)
+
+	err = readOnly.PutObject("mychart-0.2.0.tgz", []byte("mychart"))
+	suite.True(errors.Is(err, ErrReadOnly), "put is rejected")
+	suite.True(errors.Is(err, ErrPermissionDenied), "rejected put is denied")
+	err = readOnly.DeleteObject("mychart-0.1.0.tgz")
+	suite.True(errors.Is(err, ErrReadOnly), "delete is rejected")
+	var storageErr *StorageError
+	suite.True(errors.As(err, &storageErr))
+	suite.Equal("DeleteObject", storageErr.Op)
+	suite.Equal("mychart-0.1.0.tgz", storageErr.Path)
+
+	suite.Equal(0, counting.Calls("PutObject")+counting.Calls("DeleteObject"), "mutations do not reach the backend")
+	_, err = suite.Local.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err, "object is not deleted")
+}
+
+func (suite *ReadOnlyTestSuite) TestWriteOnce() {
+	for key, backend := range map[string]Backend{
+		"conditional": suite.Local,
+		"plain":       plainBackend{suite.Local},
+	} {
+		writeOnce, err := NewWriteOnceBackend(backend, "*.tgz")
+		suite.Nil(err, "no error creating write-once backend")
+
+		path := "mychart-0.2.0-" + key + ".tgz"
+		suite.Nil(writeOnce.PutObject(path, []byte("mychart")), fmt.Sprintf("new object is written using %s backend", key))
+		err = writeOnce.PutObject(path, []byte("changed"))
+		suite.True(errors.Is(err, ErrObjectImmutable), fmt.Sprintf("write-once object is not overwritten using %s backend", key))
+		suite.True(errors.Is(err, ErrPermissionDenied))
+		object, err := suite.Local.GetObject(path)
+		suite.Nil(err)
+		suite.Equal([]byte("mychart"), object.Content, "content of write-once object is unchanged")
+
+		err = writeOnce.DeleteObject(path)
+		suite.True(errors.Is(err, ErrObjectImmutable), fmt.Sprintf("write-once object is not deleted using %s backend", key))
+		suite.Nil(writeOnce.PutObject("stable/mychart-0.1.0.tgz", []byte("mychart")))
+		suite.True(errors.Is(writeOnce.PutObject("stable/mychart-0.1.0.tgz", nil), ErrObjectImmutable),
+			"patterns without a slash match the name of nested objects")
+
+		suite.Nil(writeOnce.PutObject("index.yaml", []byte("entries: {mychart: []}")), "other objects are overwritten")
+		suite.Nil(writeOnce.DeleteObject("index.yaml"), "other objects are deleted")
+		suite.Nil(suite.Local.PutObject("index.yaml", []byte("entries: {}")))
+		suite.Nil(suite.Local.DeleteObject("stable/mychart-0.1.0.tgz"))
+	}
+
+	writeOnce, err := NewWriteOnceBackend(suite.Local)
+	suite.Nil(err)
+	suite.True(errors.Is(writeOnce.DeleteObject("index.yaml"), ErrObjectImmutable), "every object is write-once without patterns")
+
+	_, err = NewWriteOnceBackend(suite.Local, "[")
+	suite.True(errors.Is(err, ErrInvalidConfig), "invalid pattern is rejected")
+}
+
+func TestReadOnlyTestSuite(t *testing.T) {
+	suite.Run(t, new(ReadOnlyTestSuite))
+}
@@ -0,0 +1,325 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	microsoft_storage "github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/baidubce/bce-sdk-go/bce"
+	"github.com/gophercloud/gophercloud"
+	"github.com/oracle/oci-go-sdk/common"
+	"github.com/tencentyun/cos-go-sdk-v5"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRetryPolicy is the policy of a RetryingBackend, unless configured otherwise
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.5,
+}
+
+type (
+	// RetryPolicy describes how many times, and how long apart, a failed operation is attempted
+	RetryPolicy struct {
+		// MaxAttempts is the number of attempts, including the first one; 1 disables retries
+		MaxAttempts int
+		// InitialBackoff is the delay before the first retry
+		InitialBackoff time.Duration
+		// MaxBackoff bounds the delay between two attempts
+		MaxBackoff time.Duration
+		// Multiplier is the factor applied to the delay after each retry
+		Multiplier float64
+		// Jitter is the fraction of each delay, between 0 and 1, which is randomly shortened so that
+		// clients failing together do not retry together
+		Jitter float64
+	}
+
+	// RetryOptions configures a RetryingBackend
+	RetryOptions struct {
+		// Policy is the retry policy of the operations without their own policy.
+		// DefaultRetryPolicy is used if Policy is the zero value.
+		Policy RetryPolicy
+		// Policies overrides Policy for some operations, keyed by the name of the Backend method,
+		// such as "GetObject"
+		Policies map[string]RetryPolicy
+		// Classifier determines whether or not a failed attempt is retried. IsRetryable is used if nil.
+		Classifier func(err error) bool
+		// OnAttempt is called after every attempt, if not nil
+		OnAttempt func(attempt RetryAttempt)
+	}
+
+	// RetryAttempt describes an attempt made by a RetryingBackend
+	RetryAttempt struct {
+		Op   string
+		Path string
+		// Attempt is the number of the attempt, starting at 1
+		Attempt int
+		// Err is the error of the attempt, or nil if it succeeded
+		Err error
+		// Retrying tells whether the operation is attempted again, after Delay
+		Retrying bool
+		Delay    time.Duration
+	}
+
+	// RetryingBackend is a Backend which retries the operations of another Backend when they fail with a
+	// transient error, waiting an exponentially increasing delay with jitter between attempts.
+	// PutObject and DeleteObject are retried as well, since writing or removing an object twice has the
+	// same effect as doing it once.
+	RetryingBackend struct {
+		backend        Backend
+		contextBackend ContextBackend
+		options        RetryOptions
+	}
+)
+
+// NewRetryingBackend creates a new instance of RetryingBackend retrying the operations of backend
+func NewRetryingBackend(backend Backend, options RetryOptions) *RetryingBackend {
+	if options.Policy == (RetryPolicy{}) {
+		options.Policy = DefaultRetryPolicy
+	}
+	options.Policy = options.Policy.withDefaults()
+	policies := make(map[string]RetryPolicy, len(options.Policies))
+	for op, policy := range options.Policies {
+		policies[op] = policy.withDefaults()
+	}
+	options.Policies = policies
+	if options.Classifier == nil {
+		options.Classifier = IsRetryable
+	}
+	return &RetryingBackend{backend: backend, contextBackend: NewContextBackend(backend), options: options}
+}
+
+// Capabilities returns the optional features supported by the RetryingBackend, which accepts contexts
+// and lists content if the retried backend does
+func (r *RetryingBackend) Capabilities() CapabilitySet {
+	return CapabilitiesOf(r.backend)&NewCapabilitySet(CapabilityListContent) | NewCapabilitySet(CapabilityContext)
+}
+
+// ListObjects lists all objects at prefix, retrying transient failures
+func (r *RetryingBackend) ListObjects(prefix string) ([]Object, error) {
+	return r.ListObjectsContext(context.Background(), prefix)
+}
+
+// GetObject retrieves an object at path, retrying transient failures
+func (r *RetryingBackend) GetObject(path string) (Object, error) {
+	return r.GetObjectContext(context.Background(), path)
+}
+
+// PutObject uploads an object to path, retrying transient failures
+func (r *RetryingBackend) PutObject(path string, content []byte) error {
+	return r.PutObjectContext(context.Background(), path, content)
+}
+
+// DeleteObject removes an object at path, retrying transient failures
+func (r *RetryingBackend) DeleteObject(path string) error {
+	return r.DeleteObjectContext(context.Background(), path)
+}
+
+// ListObjectsContext lists all objects at prefix, retrying transient failures until ctx is done
+func (r *RetryingBackend) ListObjectsContext(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	err := r.retry(ctx, "ListObjects", prefix, func() (err error) {
+		objects, err = r.contextBackend.ListObjectsContext(ctx, prefix)
+		return err
+	})
+	return objects, err
+}
+
+// GetObjectContext retrieves an object at path, retrying transient failures until ctx is done
+func (r *RetryingBackend) GetObjectContext(ctx context.Context, path string) (Object, error) {
+	var object Object
+	err := r.retry(ctx, "GetObject", path, func() (err error) {
+		object, err = r.contextBackend.GetObjectContext(ctx, path)
+		return err
+	})
+	return object, err
+}
+
+// PutObjectContext uploads an object to path, retrying transient failures until ctx is done
+func (r *RetryingBackend) PutObjectContext(ctx context.Context, path string, content []byte) error {
+	return r.retry(ctx, "PutObject", path, func() error {
+		return r.contextBackend.PutObjectContext(ctx, path, content)
+	})
+}
+
+// DeleteObjectContext removes an object at path, retrying transient failures until ctx is done
+func (r *RetryingBackend) DeleteObjectContext(ctx context.Context, path string) error {
+	return r.retry(ctx, "DeleteObject", path, func() error {
+		return r.contextBackend.DeleteObjectContext(ctx, path)
+	})
+}
+
+// retry calls attempt until it succeeds, fails with an error which is not retryable, or the policy of
+// op allows no more attempts. The error of the last attempt is returned, or the error of ctx if it is
+// done while waiting for the next attempt.
+func (r *RetryingBackend) retry(ctx context.Context, op string, path string, attempt func() error) error {
+	policy, ok := r.options.Policies[op]
+	if !ok {
+		policy = r.options.Policy
+	}
+	backoff := policy.InitialBackoff
+	for n := 1; ; n++ {
+		err := attempt()
+		retrying := err != nil && n < policy.MaxAttempts && ctx.Err() == nil && r.options.Classifier(err)
+		var delay time.Duration
+		if retrying {
+			delay = policy.jitter(backoff)
+			backoff = policy.next(backoff)
+		}
+		if r.options.OnAttempt != nil {
+			r.options.OnAttempt(RetryAttempt{Op: op, Path: path, Attempt: n, Err: err, Retrying: retrying, Delay: delay})
+		}
+		if !retrying {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// withDefaults replaces the unset fields of a policy with those of DefaultRetryPolicy
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultRetryPolicy.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = max(DefaultRetryPolicy.MaxBackoff, p.InitialBackoff)
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultRetryPolicy.Multiplier
+	}
+	p.Jitter = min(max(p.Jitter, 0), 1)
+	return p
+}
+
+// next returns the backoff following backoff, bounded by MaxBackoff
+func (p RetryPolicy) next(backoff time.Duration) time.Duration {
+	return time.Duration(min(float64(backoff)*p.Multiplier, float64(p.MaxBackoff)))
+}
+
+// jitter randomly shortens a backoff by up to its Jitter fraction
+func (p RetryPolicy) jitter(backoff time.Duration) time.Duration {
+	backoff = min(backoff, p.MaxBackoff)
+	return backoff - time.Duration(rand.Float64()*p.Jitter*float64(backoff))
+}
+
+// retryableErrorCodes lists the error codes which storage services return for transient failures,
+// beyond those classified as ErrThrottled or ErrUnavailable by their HTTP status code
+var retryableErrorCodes = map[string]bool{
+	// Amazon S3, Alibaba Cloud OSS, Tencent Cloud COS and Baidu BOS
+	"RequestTimeout":     true,
+	"InternalError":      true,
+	"ServiceUnavailable": true,
+	// AWS SDK failures reading a response
+	request.ErrCodeRead:            true,
+	request.ErrCodeResponseTimeout: true,
+	// Microsoft Azure Blob Storage
+	"OperationTimedOut": true,
+	// reasons of Google Cloud Storage errors
+	"backendError":          true,
+	"internalError":         true,
+	"rateLimitExceeded":     true,
+	"userRateLimitExceeded": true,
+}
+
+// IsRetryable determines whether or not err is a transient failure worth retrying: the storage service is
+// throttling requests or temporarily unavailable, the request timed out, or the connection was lost.
+// Errors of canceled contexts and expired deadlines are not retryable, nor are the operations failed fast by an
+// open circuit, which keeps failing until its cool-down elapses.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	if errors.Is(err, ErrThrottled) || errors.Is(err, ErrUnavailable) || retryableServiceError(err) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
+
+// retryableServiceError determines whether or not err is an error of a storage service SDK reporting
+// a timeout or a transient failure
+func retryableServiceError(err error) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return retryableErrorCodes[awsErr.Code()]
+	}
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		for _, item := range googleErr.Errors {
+			if retryableErrorCodes[item.Reason] {
+				return true
+			}
+		}
+		return googleErr.Code == http.StatusRequestTimeout
+	}
+	var azureErr microsoft_storage.AzureStorageServiceError
+	if errors.As(err, &azureErr) {
+		return retryableErrorCodes[azureErr.Code] || azureErr.StatusCode == http.StatusRequestTimeout
+	}
+	var ossErr oss.ServiceError
+	if errors.As(err, &ossErr) {
+		return retryableErrorCodes[ossErr.Code] || ossErr.StatusCode == http.StatusRequestTimeout
+	}
+	var cosErr *cos.ErrorResponse
+	if errors.As(err, &cosErr) {
+		return retryableErrorCodes[cosErr.Code] || cosErr.Response != nil && cosErr.Response.StatusCode == http.StatusRequestTimeout
+	}
+	var bosErr *bce.BceServiceError
+	if errors.As(err, &bosErr) {
+		return retryableErrorCodes[bosErr.Code] || bosErr.StatusCode == http.StatusRequestTimeout
+	}
+	var swiftErr gophercloud.StatusCodeError
+	if errors.As(err, &swiftErr) {
+		return swiftErr.GetStatusCode() == http.StatusRequestTimeout
+	}
+	var ociErr common.ServiceError
+	if errors.As(err, &ociErr) {
+		return ociErr.GetHTTPStatusCode() == http.StatusRequestTimeout
+	}
+	// etcd aborts requests which conflict with a leader election or a compaction
+	return status.Code(err) == codes.Aborted
+}
@@ -0,0 +1,217 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/suite"
+	"github.com/tencentyun/cos-go-sdk-v5"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyBackend fails the first calls made to the methods of a Backend, without delegating them
+type flakyBackend struct {
+	*countingBackend
+	failures int
+	err      error
+}
+
+// fail counts a call to op, and returns the error it fails with, if any
+func (b *flakyBackend) fail(op string) error {
+	b.count(op)
+	if b.Calls(op) <= b.failures {
+		return b.err
+	}
+	return nil
+}
+
+func (b *flakyBackend) ListObjects(prefix string) ([]Object, error) {
+	if err := b.fail("ListObjects"); err != nil {
+		return nil, err
+	}
+	return b.Backend.ListObjects(prefix)
+}
+
+func (b *flakyBackend) GetObject(path string) (Object, error) {
+	if err := b.fail("GetObject"); err != nil {
+		return Object{Path: path}, err
+	}
+	return b.Backend.GetObject(path)
+}
+
+func (b *flakyBackend) PutObject(path string, content []byte) error {
+	if err := b.fail("PutObject"); err != nil {
+		return err
+	}
+	return b.Backend.PutObject(path, content)
+}
+
+func (b *flakyBackend) DeleteObject(path string) error {
+	if err := b.fail("DeleteObject"); err != nil {
+		return err
+	}
+	return b.Backend.DeleteObject(path)
+}
+
+var retryTestPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+type RetryTestSuite struct {
+	suite.Suite
+	TempDirectory string
+	Local         *LocalFilesystemBackend
+}
+
+func (suite *RetryTestSuite) SetupTest() {
+	timestamp := time.Now().Format("20060102150405.000000")
+	suite.TempDirectory = fmt.Sprintf("../../.test/storage-retry/%s", timestamp)
+	suite.Local = NewLocalFilesystemBackend(suite.TempDirectory)
+}
+
+func (suite *RetryTestSuite) TearDownTest() {
+	os.RemoveAll(suite.TempDirectory)
+}
+
+func (suite *RetryTestSuite) newFlaky(failures int, err error) *flakyBackend {
+	return &flakyBackend{countingBackend: newCountingBackend(suite.Local), failures: failures, err: err}
+}
+
+func (suite *RetryTestSuite) TestRetry() {
+	flaky := suite.newFlaky(2, &StorageError{Backend: "test", Op: "test", Kind: ErrUnavailable, Err: errors.New("503")})
+	var attempts []RetryAttempt
+	retrying := NewRetryingBackend(flaky, RetryOptions{
+		Policy:    retryTestPolicy,
+		OnAttempt: func(attempt RetryAttempt) { attempts = append(attempts, attempt) },
+	})
+
+	suite.Nil(retrying.PutObject("mychart-0.1.0.tgz", []byte("mychart")), "put object succeeds on the third attempt")
+	suite.Equal(3, flaky.Calls("PutObject"))
+	suite.Len(attempts, 3, "every attempt is observed")
+	for i, attempt := range attempts {
+		suite.Equal("PutObject", attempt.Op)
+		suite.Equal("mychart-0.1.0.tgz", attempt.Path)
+		suite.Equal(i+1, attempt.Attempt)
+		suite.Equal(i < 2, attempt.Retrying)
+		suite.Equal(i < 2, attempt.Err != nil)
+		suite.True(attempt.Delay <= retryTestPolicy.MaxBackoff, "delay is bounded by MaxBackoff")
+	}
+
+	object, err := retrying.GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err, "get object succeeds on the third attempt")
+	suite.Equal([]byte("mychart"), object.Content)
+	objects, err := retrying.ListObjects("")
+	suite.Nil(err, "list objects succeeds on the third attempt")
+	suite.Len(objects, 1)
+	suite.Nil(retrying.DeleteObject("mychart-0.1.0.tgz"), "delete object succeeds on the third attempt")
+}
+
+func (suite *RetryTestSuite) TestMaxAttempts() {
+	flaky := suite.newFlaky(5, &StorageError{Backend: "test", Op: "test", Kind: ErrThrottled, Err: errors.New("429")})
+	retrying := NewRetryingBackend(flaky, RetryOptions{
+		Policy:   retryTestPolicy,
+		Policies: map[string]RetryPolicy{"DeleteObject": {MaxAttempts: 1}},
+	})
+
+	_, err := retrying.GetObject("mychart-0.1.0.tgz")
+	suite.True(errors.Is(err, ErrThrottled), "error of the last attempt is returned")
+	suite.Equal(3, flaky.Calls("GetObject"), "object is got MaxAttempts times")
+
+	err = retrying.DeleteObject("mychart-0.1.0.tgz")
+	suite.True(errors.Is(err, ErrThrottled))
+	suite.Equal(1, flaky.Calls("DeleteObject"), "policy of the operation overrides the default policy")
+}
+
+func (suite *RetryTestSuite) TestNotRetryable() {
+	retrying := NewRetryingBackend(newCountingBackend(suite.Local), RetryOptions{Policy: retryTestPolicy})
+	counting := retrying.backend.(*countingBackend)
+	_, err := retrying.GetObject("missing.tgz")
+	suite.True(errors.Is(err, ErrObjectNotFound))
+	suite.Equal(1, counting.Calls("GetObject"), "missing object is not retried")
+
+	flaky := suite.newFlaky(1, errors.New("custom"))
+	retrying = NewRetryingBackend(flaky, RetryOptions{
+		Policy:     retryTestPolicy,
+		Classifier: func(err error) bool { return err.Error() == "custom" },
+	})
+	_, err = retrying.ListObjects("")
+	suite.Nil(err, "classifier decides which errors are retried")
+	suite.Equal(2, flaky.Calls("ListObjects"))
+}
+
+func (suite *RetryTestSuite) TestContext() {
+	flaky := suite.newFlaky(5, &StorageError{Backend: "test", Op: "test", Kind: ErrUnavailable, Err: errors.New("503")})
+	retrying := NewRetryingBackend(flaky, RetryOptions{Policy: RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}})
+	suite.True(retrying.Capabilities().Has(CapabilityContext))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := retrying.PutObjectContext(ctx, "mychart-0.1.0.tgz", []byte("mychart"))
+	suite.True(errors.Is(err, context.DeadlineExceeded), "waiting for the next attempt stops when the context is done")
+	suite.Equal(1, flaky.Calls("PutObject"))
+}
+
+func (suite *RetryTestSuite) TestBackoff() {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 3 * time.Second, Jitter: 0.5}.withDefaults()
+	suite.Equal(DefaultRetryPolicy.MaxAttempts, policy.MaxAttempts, "unset fields use the default policy")
+	suite.Equal(2*time.Second, policy.next(time.Second), "backoff grows exponentially")
+	suite.Equal(3*time.Second, policy.next(2*time.Second), "backoff is bounded by MaxBackoff")
+	for i := 0; i < 10; i++ {
+		delay := policy.jitter(time.Second)
+		suite.True(delay > 500*time.Millisecond && delay <= time.Second, "jitter shortens the delay by up to half")
+	}
+}
+
+func (suite *RetryTestSuite) TestIsRetryable() {
+	retryable := []error{
+		&StorageError{Backend: "test", Op: "test", Kind: ErrThrottled, Err: errors.New("429")},
+		fmt.Errorf("read: %w", io.ErrUnexpectedEOF),
+		fmt.Errorf("read: %w", syscall.ECONNRESET),
+		awserr.New("RequestTimeout", "timeout", nil),
+		&googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}},
+		&cos.ErrorResponse{Code: "InternalError"},
+		status.Error(codes.Aborted, "aborted"),
+	}
+	for _, err := range retryable {
+		suite.True(IsRetryable(err), "%v is retryable", err)
+	}
+	notRetryable := []error{
+		nil,
+		&StorageError{Backend: "test", Op: "test", Kind: ErrObjectNotFound, Err: errors.New("404")},
+		awserr.New("AccessDenied", "denied", nil),
+		&googleapi.Error{Code: http.StatusForbidden},
+		context.Canceled,
+		errors.New("unknown"),
+	}
+	for _, err := range notRetryable {
+		suite.False(IsRetryable(err), "%v is not retryable", err)
+	}
+}
+
+func TestRetryTestSuite(t *testing.T) {
+	suite.Run(t, new(RetryTestSuite))
+}
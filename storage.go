@@ -17,19 +17,44 @@ limitations under the License.
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	pathutil "path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	// defaultPageSize is the number of keys requested per page when listing objects
+	defaultPageSize = 1000
+	// maxDeleteBatchSize is the maximum number of keys deleted by a single bulk delete request
+	maxDeleteBatchSize = 1000
+	// maxParallelDeletes is the maximum number of concurrent requests made by backends without bulk delete
+	maxParallelDeletes = 16
+)
+
 type (
 	// Object is a generic representation of a storage object
+	// Size, ETag, ContentType, StorageClass and UserMetadata are filled in as far as the backend
+	// reports them; in particular most backends do not return content type or user metadata when listing
 	Object struct {
 		Meta         Metadata
 		Path         string
 		Content      []byte
 		LastModified time.Time
+		Size         int64
+		ETag         string
+		ContentType  string
+		StorageClass string
+		UserMetadata map[string]string
 	}
 	// Metadata represents the meta information of the object
 	// includes object name , object version , etc...
@@ -38,6 +63,76 @@ type (
 		Version string
 	}
 
+	// ObjectInfo describes a storage object without its content
+	ObjectInfo struct {
+		Path         string
+		Size         int64
+		LastModified time.Time
+		ETag         string
+		ContentType  string
+		StorageClass string
+		UserMetadata map[string]string
+	}
+
+	// PutObjectOptions holds the attributes stored along with the content of an object.
+	// User metadata keys are case-insensitive and are returned in lower case.
+	PutObjectOptions struct {
+		ContentType  string
+		StorageClass string
+		UserMetadata map[string]string
+	}
+
+	// ListObjectsOptions controls how far ListObjectsWithOptions descends below a prefix
+	ListObjectsOptions struct {
+		// Recursive lists the objects at every level below the prefix, with their paths relative to the prefix.
+		// Otherwise only the objects directly below the prefix are listed, and deeper levels are returned as common prefixes.
+		Recursive bool
+		// Delimiter separates the levels of the hierarchy, "/" if empty
+		Delimiter string
+	}
+
+	// ListObjectsResult holds the objects and the common prefixes found below a prefix.
+	// Common prefixes are relative to the listed prefix and end with the delimiter, e.g. "org/".
+	ListObjectsResult struct {
+		Objects        []Object
+		CommonPrefixes []string
+	}
+
+	// ObjectPage is one page of the objects listed below a prefix.
+	// NextToken resumes the listing after the page, and is empty once the listing is complete.
+	ObjectPage struct {
+		Objects   []Object
+		NextToken string
+	}
+
+	// DeleteResult is the outcome of the deletion of one object by DeleteObjects.
+	// Err is nil if the object was deleted.
+	DeleteResult struct {
+		Path string
+		Err  error
+	}
+
+	// Condition restricts a conditional write to a given state of the object being written.
+	// At most one of IfNotExists and IfMatch may be set; the zero Condition always matches.
+	Condition struct {
+		// IfNotExists only matches when there is no object at the path yet
+		IfNotExists bool
+		// IfMatch only matches when the ETag of the object at the path is IfMatch
+		IfMatch string
+	}
+
+	// WatchEventType tells whether a WatchEvent is for an object put or deleted
+	WatchEventType string
+
+	// WatchEvent is a change of an object observed by a WatchBackend. The Object of a put holds its content
+	// and information; the Object of a deletion only its Path. Err is set on the last event of a watch which
+	// failed, such as one resumed at a revision the storage service no longer holds.
+	WatchEvent struct {
+		Type   WatchEventType
+		Object Object
+		Err    error
+	}
+
 	// ObjectSliceDiff provides information on what has changed since last calling ListObjects
 	ObjectSliceDiff struct {
 		Change  bool
@@ -53,6 +148,138 @@ type (
 		PutObject(path string, content []byte) error
 		DeleteObject(path string) error
 	}
+
+	// ContextBackend is a Backend whose operations honour cancellation and deadlines of a context
+	ContextBackend interface {
+		Backend
+		ListObjectsContext(ctx context.Context, prefix string) ([]Object, error)
+		GetObjectContext(ctx context.Context, path string) (Object, error)
+		PutObjectContext(ctx context.Context, path string, content []byte) error
+		DeleteObjectContext(ctx context.Context, path string) error
+	}
+
+	// StreamBackend is a Backend which can read and write objects as streams instead of byte slices.
+	// The caller must close the reader returned by GetObjectReader. A negative size passed to
+	// PutObjectStream means the length of the content is not known in advance.
+	StreamBackend interface {
+		Backend
+		GetObjectReader(path string) (io.ReadCloser, ObjectInfo, error)
+		PutObjectStream(path string, content io.Reader, size int64) error
+	}
+
+	// RangeBackend is a Backend which can read part of an object. GetObjectRange reads length bytes from offset,
+	// or until the end of the object if length is negative. Ranges extending past the end of the object are
+	// truncated, so a range starting at or past the end reads no bytes.
+	RangeBackend interface {
+		Backend
+		GetObjectRange(path string, offset int64, length int64) ([]byte, error)
+	}
+
+	// MetadataBackend is a Backend which can store a content type, storage class and user metadata along with an object
+	MetadataBackend interface {
+		Backend
+		PutObjectWithOptions(path string, content []byte, options PutObjectOptions) error
+	}
+
+	// StatBackend is a Backend which can retrieve the information of an object without its content
+	StatBackend interface {
+		Backend
+		StatObject(path string) (ObjectInfo, error)
+	}
+
+	// HierarchicalBackend is a Backend which can list nested objects, and the common prefixes of the levels below a prefix
+	HierarchicalBackend interface {
+		Backend
+		ListObjectsWithOptions(prefix string, options ListObjectsOptions) (ListObjectsResult, error)
+	}
+
+	// PagingBackend is a Backend which can list the objects below a prefix one page at a time.
+	// ListObjectsPage lists at most limit objects (a backend specific default if limit is not positive),
+	// starting after the opaque token returned with the previous page, or at the beginning if token is empty.
+	// A page may hold fewer than limit objects even when more follow. ListObjectsIter iterates over all
+	// the objects below a prefix, requesting one page at a time, and stops at the first error.
+	PagingBackend interface {
+		Backend
+		ListObjectsPage(prefix string, token string, limit int) (ObjectPage, error)
+		ListObjectsIter(prefix string) iter.Seq2[Object, error]
+	}
+
+	// BatchDeleteBackend is a Backend which can delete many objects at once.
+	// DeleteObjects returns one DeleteResult per path, in the order of paths. The returned error is not nil
+	// if a bulk request failed as a whole, in which case the results of the paths it held carry that error too.
+	BatchDeleteBackend interface {
+		Backend
+		DeleteObjects(paths []string) ([]DeleteResult, error)
+	}
+
+	// CopyBackend is a Backend which can copy and move objects within the storage service, without
+	// downloading and uploading their content. CopyObject replaces any object at dst. MoveObject copies src
+	// to dst and then deletes src, atomically where the storage service supports renames; moving an object
+	// onto itself leaves it unchanged.
+	CopyBackend interface {
+		Backend
+		CopyObject(src string, dst string) error
+		MoveObject(src string, dst string) error
+	}
+
+	// Presigner is a Backend which can generate URLs granting temporary access to a single object, so that
+	// clients can download or upload it directly from or to the storage service, without credentials.
+	// The URLs expire after ttl. A Presigner returns an error matching ErrNotSupported if its credentials
+	// cannot sign URLs.
+	Presigner interface {
+		Backend
+		PresignGet(path string, ttl time.Duration) (string, error)
+		PresignPut(path string, ttl time.Duration) (string, error)
+	}
+
+	// ConditionalBackend is a Backend which can write an object only if it is in the expected state,
+	// so that concurrent writers do not silently overwrite each other. PutObjectIf returns an error
+	// matching ErrPreconditionFailed when the condition is not met, and the object is left unchanged.
+	ConditionalBackend interface {
+		Backend
+		PutObjectIf(path string, content []byte, condition Condition) error
+	}
+
+	// WatchBackend is a Backend which notifies the changes of the objects below a prefix (depth 1) as they happen,
+	// instead of having them polled with ListObjects. Watch sends an event on the returned channel for each object
+	// put or deleted after it was called, until ctx is done or the watch fails, and then closes the channel.
+	WatchBackend interface {
+		Backend
+		Watch(ctx context.Context, prefix string) <-chan WatchEvent
+	}
+
+	// CapabilityBackend is a Backend which declares the optional features it supports.
+	// Every backend of this package implements it; see CapabilitiesOf for other backends.
+	// The backends wrapping another Backend, such as CachingBackend or RetryingBackend, do not forward the
+	// optional interfaces of the wrapped backend, which would bypass them, and so do not declare them either:
+	// through a wrapper, helpers such as CopyObject and DeleteObjects fall back to the operations of Backend.
+	CapabilityBackend interface {
+		Backend
+		Capabilities() CapabilitySet
+	}
+
+	// LoggerBackend is a Backend which logs its failed operations, and the events its callers cannot observe such
+	// as broken locks, to a logger. Every backend of this package implements it and logs nothing until given one.
+	LoggerBackend interface {
+		Backend
+		SetLogger(logger *slog.Logger)
+	}
+
+	// VersionedBackend is a Backend which keeps the previous versions of overwritten objects, identified by the
+	// Version of their Meta. ListObjectVersions lists the versions of the objects below a prefix (depth 1), without
+	// their content, in a backend specific order. Versions are only kept if the bucket has versioning enabled.
+	VersionedBackend interface {
+		Backend
+		ListObjectVersions(prefix string) ([]Object, error)
+		GetObjectVersion(path string, versionID string) (Object, error)
+		DeleteObjectVersion(path string, versionID string) error
+	}
+)
+
+// Types of the WatchEvents sent by a WatchBackend
+const (
+	WatchPut    WatchEventType = "put"
+	WatchDelete WatchEventType = "delete"
 )
 
 // HasExtension determines whether or not an object contains a file extension
@@ -60,6 +287,228 @@ func (object Object) HasExtension(extension string) bool {
 	return filepath.Ext(object.Path) == fmt.Sprintf(".%s", extension)
 }
 
+// delimiter returns the delimiter separating the levels of the hierarchy
+func (options ListObjectsOptions) delimiter() string {
+	if options.Delimiter == "" {
+		return "/"
+	}
+	return options.Delimiter
+}
+
+// nativeDelimiter returns the delimiter to pass to a storage service, empty when listing recursively
+func (options ListObjectsOptions) nativeDelimiter() string {
+	if options.Recursive {
+		return ""
+	}
+	return options.delimiter()
+}
+
+// listPrefix returns the key prefix of the objects below prefix, within the base prefix of a backend
+func listPrefix(basePrefix string, prefix string) string {
+	listPrefix := cleanPrefix(pathutil.Join(basePrefix, prefix))
+	if listPrefix == "" {
+		return ""
+	}
+	return listPrefix + "/"
+}
+
+// groupObjects turns objects listed recursively, with paths relative to the listed prefix, into the result
+// of a listing with options, by replacing the objects nested below another level with their common prefix
+func groupObjects(objects []Object, options ListObjectsOptions) ListObjectsResult {
+	if options.Recursive {
+		return ListObjectsResult{Objects: objects}
+	}
+	var result ListObjectsResult
+	delimiter := options.delimiter()
+	seen := make(map[string]bool)
+	for _, object := range objects {
+		index := strings.Index(object.Path, delimiter)
+		if index < 0 {
+			result.Objects = append(result.Objects, object)
+			continue
+		}
+		commonPrefix := object.Path[:index+len(delimiter)]
+		if !seen[commonPrefix] {
+			seen[commonPrefix] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix)
+		}
+	}
+	return result
+}
+
+// pageSize returns limit, or defaultPageSize if limit is not positive
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	return limit
+}
+
+// listObjectsIter iterates over the objects below prefix, listing them one page at a time
+func listObjectsIter(backend PagingBackend, prefix string) iter.Seq2[Object, error] {
+	return func(yield func(Object, error) bool) {
+		token := ""
+		for {
+			page, err := backend.ListObjectsPage(prefix, token, 0)
+			if err != nil {
+				yield(Object{}, err)
+				return
+			}
+			for _, object := range page.Objects {
+				if !yield(object, nil) {
+					return
+				}
+			}
+			if page.NextToken == "" {
+				return
+			}
+			token = page.NextToken
+		}
+	}
+}
+
+// deleteResults returns the results of the deletion of paths, without errors
+func deleteResults(paths []string) []DeleteResult {
+	results := make([]DeleteResult, len(paths))
+	for i, path := range paths {
+		results[i].Path = path
+	}
+	return results
+}
+
+// deleteObjectsInBatches deletes paths in batches of at most size paths using deleteBatch, which records the
+// outcome of each deletion in the results of its batch, or returns an error if the batch failed as a whole
+func deleteObjectsInBatches(paths []string, size int, deleteBatch func(batch []DeleteResult) error) ([]DeleteResult, error) {
+	var err error
+	results := deleteResults(paths)
+	for offset := 0; offset < len(results); offset += size {
+		batch := results[offset:min(offset+size, len(results))]
+		if batchErr := deleteBatch(batch); batchErr != nil {
+			for i := range batch {
+				batch[i].Err = batchErr
+			}
+			if err == nil {
+				err = batchErr
+			}
+		}
+	}
+	return results, err
+}
+
+// validatePresignTTL checks that presigned URLs expiring after ttl remain valid for at least a second
+func validatePresignTTL(ttl time.Duration) error {
+	if ttl < time.Second {
+		return fmt.Errorf("presigned URL lifetime %s is shorter than a second", ttl)
+	}
+	return nil
+}
+
+// byteRange returns the bounds of length bytes from offset, until the end of the object if length is negative,
+// in the format of an HTTP Range header without its unit, e.g. "10-19" or "10-"
+func byteRange(offset int64, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("%d-", offset)
+	}
+	return fmt.Sprintf("%d-%d", offset, offset+length-1)
+}
+
+// httpRange returns the HTTP Range header requesting length bytes from offset, until the end of the object if length is negative
+func httpRange(offset int64, length int64) string {
+	return "bytes=" + byteRange(offset, length)
+}
+
+// validateRangeOffset checks that a range starts within or past an object
+func validateRangeOffset(offset int64) error {
+	if offset < 0 {
+		return fmt.Errorf("invalid range offset %d", offset)
+	}
+	return nil
+}
+
+// getObjectRange reads length bytes of an object from offset with getRange, which requests them from a storage service.
+// As an HTTP range cannot be empty, getRange is asked for at least one byte. Storage services reject ranges starting
+// at or past the end of an object as not satisfiable, which kind detects, and which read no bytes instead.
+func getObjectRange(offset int64, length int64, kind func(error) error, getRange func(offset int64, length int64) ([]byte, error)) ([]byte, error) {
+	if err := validateRangeOffset(offset); err != nil {
+		return nil, err
+	}
+	requested := length
+	if requested == 0 {
+		requested = 1
+	}
+	content, err := getRange(offset, requested)
+	if err != nil {
+		if kind(err) == errRangeNotSatisfiable {
+			return []byte{}, nil
+		}
+		return nil, err
+	}
+	return truncateRange(content, length), nil
+}
+
+// sliceRange returns the length bytes of content from offset, until the end of content if length is negative
+func sliceRange(content []byte, offset int64, length int64) ([]byte, error) {
+	if err := validateRangeOffset(offset); err != nil {
+		return nil, err
+	}
+	if offset >= int64(len(content)) {
+		return []byte{}, nil
+	}
+	return truncateRange(content[offset:], length), nil
+}
+
+// truncateRange truncates content to length bytes, unless length is negative
+func truncateRange(content []byte, length int64) []byte {
+	if length >= 0 && int64(len(content)) > length {
+		return content[:length]
+	}
+	return content
+}
+
+// moveObject moves src to dst with copyObject, and deletes src with deleteObject once the copy succeeded.
+// src is left in place when it is the same path as dst.
+func moveObject(src string, dst string, copyObject func(src string, dst string) error, deleteObject func(path string) error) error {
+	if samePath(src, dst) {
+		return nil
+	}
+	err := copyObject(src, dst)
+	if err != nil {
+		return err
+	}
+	return deleteObject(src)
+}
+
+// samePath determines whether two object paths designate the same object
+func samePath(a string, b string) bool {
+	return pathutil.Clean("/"+a) == pathutil.Clean("/"+b)
+}
+
+// deleteObjectsInParallel deletes every path with deleteObject, making at most maxParallelDeletes calls at a time
+func deleteObjectsInParallel(paths []string, deleteObject func(path string) error) []DeleteResult {
+	results := deleteResults(paths)
+	semaphore := make(chan struct{}, maxParallelDeletes)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(result *DeleteResult) {
+			defer wg.Done()
+			result.Err = deleteObject(result.Path)
+			<-semaphore
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// validate checks the condition does not combine mutually exclusive requirements
+func (condition Condition) validate() error {
+	if condition.IfNotExists && condition.IfMatch != "" {
+		return errors.New("condition cannot require both IfNotExists and IfMatch")
+	}
+	return nil
+}
+
 // GetObjectSliceDiff takes two objects slices and returns an ObjectSliceDiff
 func GetObjectSliceDiff(prev []Object, curr []Object, timestampTolerance time.Duration) ObjectSliceDiff {
 	var diff ObjectSliceDiff
@@ -108,3 +557,82 @@ func removePrefixFromObjectPath(prefix string, path string) string {
 func objectPathIsInvalid(path string) bool {
 	return strings.Contains(path, "/") || path == ""
 }
+
+// Info returns the information of the object, without its content
+func (object Object) Info() ObjectInfo {
+	return ObjectInfo{
+		Path:         object.Path,
+		Size:         object.Size,
+		LastModified: object.LastModified,
+		ETag:         object.ETag,
+		ContentType:  object.ContentType,
+		StorageClass: object.StorageClass,
+		UserMetadata: object.UserMetadata,
+	}
+}
+
+// objectWithInfo builds an Object from its information and content
+func objectWithInfo(info ObjectInfo, content []byte) Object {
+	return Object{
+		Path:         info.Path,
+		Content:      content,
+		LastModified: info.LastModified,
+		Size:         info.Size,
+		ETag:         info.ETag,
+		ContentType:  info.ContentType,
+		StorageClass: info.StorageClass,
+		UserMetadata: info.UserMetadata,
+	}
+}
+
+// objectInfoFromHeader builds an ObjectInfo from the HTTP response headers of an object.
+// vendor is the provider specific header namespace, e.g. "oss" for the X-Oss-Meta-* headers.
+func objectInfoFromHeader(path string, header http.Header, vendor string) ObjectInfo {
+	info := ObjectInfo{
+		Path:         path,
+		Size:         -1,
+		ETag:         cleanETag(header.Get("ETag")),
+		ContentType:  header.Get("Content-Type"),
+		StorageClass: header.Get(fmt.Sprintf("X-%s-Storage-Class", vendor)),
+		UserMetadata: userMetadataFromHeader(header, fmt.Sprintf("X-%s-Meta-", vendor)),
+	}
+	if size, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		info.Size = size
+	}
+	if lastModified, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		info.LastModified = lastModified
+	}
+	return info
+}
+
+// userMetadataFromHeader extracts the user metadata sent as headers with the given prefix
+func userMetadataFromHeader(header http.Header, prefix string) map[string]string {
+	var metadata map[string]string
+	prefix = http.CanonicalHeaderKey(prefix)
+	for key := range header {
+		if strings.HasPrefix(key, prefix) {
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[strings.ToLower(strings.TrimPrefix(key, prefix))] = header.Get(key)
+		}
+	}
+	return metadata
+}
+
+// lowerCaseKeys returns a copy of metadata with all keys in lower case, or nil if it is empty
+func lowerCaseKeys(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	lowered := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		lowered[strings.ToLower(key)] = value
+	}
+	return lowered
+}
+
+// cleanETag removes the quotes surrounding an entity tag
+func cleanETag(etag string) string {
+	return strings.Trim(etag, "\"")
+}
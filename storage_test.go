@@ -17,12 +17,24 @@ limitations under the License.
 package storage
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
+	pathutil "path"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type StorageTestSuite struct {
@@ -149,6 +161,103 @@ func (suite *StorageTestSuite) TestListObjects() {
 	}
 }
 
+func (suite *StorageTestSuite) TestListObjectsPage() {
+	for key, backend := range suite.StorageBackends {
+		pagingBackend, ok := backend.(PagingBackend)
+		message := fmt.Sprintf("%s backend implements PagingBackend", key)
+		suite.True(ok, message)
+
+		var paths []string
+		token := ""
+		for pages := 1; ; pages++ {
+			page, err := pagingBackend.ListObjectsPage("", token, 4)
+			message = fmt.Sprintf("no error listing page %d using %s backend", pages, key)
+			suite.Nil(err, message)
+			message = fmt.Sprintf("page %d holds at most 4 objects using %s backend", pages, key)
+			suite.True(len(page.Objects) <= 4, message)
+			for _, object := range page.Objects {
+				paths = append(paths, object.Path)
+			}
+			if page.NextToken == "" || pages > 9 {
+				break
+			}
+			token = page.NextToken
+		}
+		message = fmt.Sprintf("all objects listed page by page using %s backend", key)
+		suite.Equal(9, len(paths), message)
+		for i, path := range paths {
+			suite.Equal(fmt.Sprintf("test%d.txt", i+1), path, message)
+		}
+
+		paths = nil
+		for object, err := range pagingBackend.ListObjectsIter("") {
+			message = fmt.Sprintf("no error iterating over objects using %s backend", key)
+			suite.Nil(err, message)
+			paths = append(paths, object.Path)
+			if len(paths) == 5 {
+				break
+			}
+		}
+		message = fmt.Sprintf("iteration over objects stops early using %s backend", key)
+		suite.Equal([]string{"test1.txt", "test2.txt", "test3.txt", "test4.txt", "test5.txt"}, paths, message)
+	}
+}
+
+func (suite *StorageTestSuite) TestListObjectsWithOptions() {
+	for key, backend := range suite.StorageBackends {
+		hierarchicalBackend, ok := backend.(HierarchicalBackend)
+		message := fmt.Sprintf("%s backend implements HierarchicalBackend", key)
+		suite.True(ok, message)
+
+		paths := []string{"org/other.tgz", "org/repo/chart.tgz"}
+		for _, path := range paths {
+			err := backend.PutObject(path, []byte("test content"))
+			message = fmt.Sprintf("no error putting nested object %s using %s backend", path, key)
+			suite.Nil(err, message)
+		}
+
+		result, err := hierarchicalBackend.ListObjectsWithOptions("", ListObjectsOptions{})
+		message = fmt.Sprintf("no error listing top level using %s backend", key)
+		suite.Nil(err, message)
+		message = fmt.Sprintf("top level lists objects and common prefixes using %s backend", key)
+		suite.Equal(9, len(result.Objects), message)
+		suite.Contains(result.CommonPrefixes, "org/", message)
+
+		result, err = hierarchicalBackend.ListObjectsWithOptions("org", ListObjectsOptions{})
+		message = fmt.Sprintf("no error listing one level below org using %s backend", key)
+		suite.Nil(err, message)
+		suite.Equal(1, len(result.Objects), message)
+		suite.Equal("other.tgz", result.Objects[0].Path, message)
+		suite.Equal([]string{"repo/"}, result.CommonPrefixes, message)
+
+		result, err = hierarchicalBackend.ListObjectsWithOptions("org", ListObjectsOptions{Recursive: true})
+		message = fmt.Sprintf("no error listing recursively below org using %s backend", key)
+		suite.Nil(err, message)
+		suite.Equal(2, len(result.Objects), message)
+		for i, object := range result.Objects {
+			suite.Equal(paths[i], "org/"+object.Path, message)
+		}
+		suite.Empty(result.CommonPrefixes, message)
+
+		for _, path := range paths {
+			err := backend.DeleteObject(path)
+			message = fmt.Sprintf("no error deleting nested object %s using %s backend", path, key)
+			suite.Nil(err, message)
+		}
+	}
+}
+
+func (suite *StorageTestSuite) TestGroupObjects() {
+	objects := []Object{{Path: "mychart-0.1.0.tgz"}, {Path: "org-a-chart.tgz"}, {Path: "org-b-chart.tgz"}}
+	result := groupObjects(objects, ListObjectsOptions{Delimiter: "-"})
+	suite.Empty(result.Objects, "objects containing the delimiter are grouped")
+	suite.Equal([]string{"mychart-", "org-"}, result.CommonPrefixes, "common prefixes listed once, in order")
+
+	result = groupObjects(objects, ListObjectsOptions{Recursive: true, Delimiter: "-"})
+	suite.Equal(objects, result.Objects, "all objects listed recursively")
+	suite.Empty(result.CommonPrefixes, "no common prefixes listed recursively")
+}
+
 func (suite *StorageTestSuite) TestGetObject() {
 	for key, backend := range suite.StorageBackends {
 		for i := 1; i <= 9; i++ {
@@ -162,6 +271,675 @@ func (suite *StorageTestSuite) TestGetObject() {
 	}
 }
 
+func (suite *StorageTestSuite) TestObjectMetadata() {
+	for key, backend := range suite.StorageBackends {
+		objects, err := backend.ListObjects("")
+		message := fmt.Sprintf("no error listing objects using %s backend", key)
+		suite.Nil(err, message)
+		for i, object := range objects {
+			message = fmt.Sprintf("object %s listed with size and ETag using %s backend", object.Path, key)
+			suite.Equal(int64(len(fmt.Sprintf("test content %d", i+1))), object.Size, message)
+			suite.NotEmpty(object.ETag, message)
+		}
+
+		for i := 1; i <= 9; i++ {
+			path := fmt.Sprintf("test%d.txt", i)
+			object, err := backend.GetObject(path)
+			message = fmt.Sprintf("no error getting object %s using %s backend", path, key)
+			suite.Nil(err, message)
+			message = fmt.Sprintf("object %s retrieved with size and ETag using %s backend", path, key)
+			suite.Equal(int64(len(object.Content)), object.Size, message)
+			suite.NotEmpty(object.ETag, message)
+		}
+
+		metadataBackend, ok := backend.(MetadataBackend)
+		if !ok {
+			continue
+		}
+		path := "metadata.json"
+		options := PutObjectOptions{
+			ContentType:  "application/json",
+			UserMetadata: map[string]string{"Chart-Name": "mychart"},
+		}
+		err = metadataBackend.PutObjectWithOptions(path, []byte("{}"), options)
+		message = fmt.Sprintf("no error putting object %s with options using %s backend", path, key)
+		suite.Nil(err, message)
+		object, err := backend.GetObject(path)
+		message = fmt.Sprintf("object %s retrieved with its content type and user metadata using %s backend", path, key)
+		suite.Nil(err, message)
+		suite.Equal("application/json", object.ContentType, message)
+		suite.Equal(map[string]string{"chart-name": "mychart"}, object.UserMetadata, message)
+		err = backend.DeleteObject(path)
+		message = fmt.Sprintf("no error deleting object %s using %s backend", path, key)
+		suite.Nil(err, message)
+	}
+}
+
+func (suite *StorageTestSuite) TestContextBackend() {
+	for key, backend := range suite.StorageBackends {
+		contextBackend, ok := backend.(ContextBackend)
+		message := fmt.Sprintf("%s backend implements ContextBackend", key)
+		suite.True(ok, message)
+		suite.Equal(contextBackend, NewContextBackend(backend), message)
+
+		object, err := contextBackend.GetObjectContext(context.Background(), "test1.txt")
+		message = fmt.Sprintf("no error getting object with context using %s backend", key)
+		suite.Nil(err, message)
+		suite.Equal([]byte("test content 1"), object.Content, message)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = contextBackend.ListObjectsContext(ctx, "")
+		message = fmt.Sprintf("cannot list objects with cancelled context using %s backend", key)
+		suite.NotNil(err, message)
+		_, err = contextBackend.GetObjectContext(ctx, "test1.txt")
+		message = fmt.Sprintf("cannot get object with cancelled context using %s backend", key)
+		suite.NotNil(err, message)
+		err = contextBackend.PutObjectContext(ctx, "cancelled.txt", []byte("cancelled"))
+		message = fmt.Sprintf("cannot put object with cancelled context using %s backend", key)
+		suite.NotNil(err, message)
+		err = contextBackend.DeleteObjectContext(ctx, "test1.txt")
+		message = fmt.Sprintf("cannot delete object with cancelled context using %s backend", key)
+		suite.NotNil(err, message)
+	}
+}
+
+func (suite *StorageTestSuite) TestStreamBackend() {
+	for key, backend := range suite.StorageBackends {
+		streamBackend, ok := backend.(StreamBackend)
+		message := fmt.Sprintf("%s backend implements StreamBackend", key)
+		suite.True(ok, message)
+
+		content := []byte("streamed content")
+		path := "streamed.txt"
+		// io.MultiReader hides the length and seekability of the underlying reader
+		err := streamBackend.PutObjectStream(path, io.MultiReader(bytes.NewReader(content)), -1)
+		message = fmt.Sprintf("no error streaming object %s of unknown size using %s backend", path, key)
+		suite.Nil(err, message)
+
+		reader, info, err := streamBackend.GetObjectReader(path)
+		message = fmt.Sprintf("no error opening object %s using %s backend", path, key)
+		suite.Nil(err, message)
+		data, err := ioutil.ReadAll(reader)
+		suite.Nil(err, message)
+		suite.Nil(reader.Close(), message)
+		message = fmt.Sprintf("object %s streamed as expected using %s backend", path, key)
+		suite.Equal(content, data, message)
+		suite.Equal(path, info.Path, message)
+		suite.Equal(int64(len(content)), info.Size, message)
+		suite.False(info.LastModified.IsZero(), message)
+
+		err = streamBackend.PutObjectStream(path, bytes.NewReader(content[:8]), 8)
+		message = fmt.Sprintf("no error streaming object %s of known size using %s backend", path, key)
+		suite.Nil(err, message)
+		object, err := backend.GetObject(path)
+		suite.Nil(err, message)
+		suite.Equal(content[:8], object.Content, message)
+
+		err = backend.DeleteObject(path)
+		message = fmt.Sprintf("no error deleting object %s using %s backend", path, key)
+		suite.Nil(err, message)
+
+		_, _, err = streamBackend.GetObjectReader(path)
+		message = fmt.Sprintf("cannot open deleted object %s using %s backend", path, key)
+		suite.NotNil(err, message)
+	}
+}
+
+func (suite *StorageTestSuite) TestStatObject() {
+	for key, backend := range suite.StorageBackends {
+		statBackend, ok := backend.(StatBackend)
+		message := fmt.Sprintf("%s backend implements StatBackend", key)
+		suite.True(ok, message)
+
+		for i := 1; i <= 9; i++ {
+			path := fmt.Sprintf("test%d.txt", i)
+			info, err := statBackend.StatObject(path)
+			message = fmt.Sprintf("no error getting info of object %s using %s backend", path, key)
+			suite.Nil(err, message)
+			message = fmt.Sprintf("object %s info as expected using %s backend", path, key)
+			suite.Equal(path, info.Path, message)
+			suite.Equal(int64(len(fmt.Sprintf("test content %d", i))), info.Size, message)
+			suite.NotEmpty(info.ETag, message)
+			object, err := backend.GetObject(path)
+			suite.Nil(err, message)
+			suite.True(object.LastModified.Equal(info.LastModified), message)
+		}
+
+		path := "this-file-cannot-possibly-exist.tgz"
+		_, err := statBackend.StatObject(path)
+		message = fmt.Sprintf("getting info of missing object %s returns ErrObjectNotFound using %s backend", path, key)
+		suite.True(errors.Is(err, ErrObjectNotFound), message)
+	}
+}
+
+func (suite *StorageTestSuite) TestConditionalBackend() {
+	for key, backend := range suite.StorageBackends {
+		conditionalBackend, ok := backend.(ConditionalBackend)
+		if !ok {
+			continue
+		}
+		path := "index-cache.yaml"
+
+		err := conditionalBackend.PutObjectIf(path, []byte("v1"), Condition{IfMatch: "missing"})
+		message := fmt.Sprintf("writing missing object %s if its ETag matches fails using %s backend", path, key)
+		suite.True(errors.Is(err, ErrPreconditionFailed), message)
+
+		err = conditionalBackend.PutObjectIf(path, []byte("v1"), Condition{IfNotExists: true})
+		message = fmt.Sprintf("no error writing new object %s if it does not exist using %s backend", path, key)
+		suite.Nil(err, message)
+
+		err = conditionalBackend.PutObjectIf(path, []byte("v2"), Condition{IfNotExists: true})
+		message = fmt.Sprintf("writing existing object %s if it does not exist fails using %s backend", path, key)
+		suite.True(errors.Is(err, ErrPreconditionFailed), message)
+
+		info, err := backend.(StatBackend).StatObject(path)
+		message = fmt.Sprintf("no error getting info of object %s using %s backend", path, key)
+		suite.Nil(err, message)
+
+		err = conditionalBackend.PutObjectIf(path, []byte("v2 with more content"), Condition{IfMatch: info.ETag})
+		message = fmt.Sprintf("no error writing object %s if its ETag matches using %s backend", path, key)
+		suite.Nil(err, message)
+
+		err = conditionalBackend.PutObjectIf(path, []byte("v3"), Condition{IfMatch: info.ETag})
+		message = fmt.Sprintf("writing object %s with a stale ETag fails using %s backend", path, key)
+		suite.True(errors.Is(err, ErrPreconditionFailed), message)
+
+		object, err := backend.GetObject(path)
+		message = fmt.Sprintf("object %s keeps the last successfully written content using %s backend", path, key)
+		suite.Nil(err, message)
+		suite.Equal([]byte("v2 with more content"), object.Content, message)
+
+		err = conditionalBackend.PutObjectIf(path, []byte("v3"), Condition{IfNotExists: true, IfMatch: info.ETag})
+		message = fmt.Sprintf("writing object %s with an invalid condition fails using %s backend", path, key)
+		suite.NotNil(err, message)
+
+		err = backend.DeleteObject(path)
+		message = fmt.Sprintf("no error deleting object %s using %s backend", path, key)
+		suite.Nil(err, message)
+	}
+}
+
+func (suite *StorageTestSuite) TestDeleteObjects() {
+	for key, backend := range suite.StorageBackends {
+		batchDeleteBackend, ok := backend.(BatchDeleteBackend)
+		suite.True(ok, fmt.Sprintf("%s backend implements BatchDeleteBackend", key))
+		if !ok {
+			continue
+		}
+		paths := []string{"batch/a.tgz", "batch/b.tgz", "batch/c.tgz"}
+		for _, path := range paths {
+			err := backend.PutObject(path, []byte("content"))
+			message := fmt.Sprintf("no error putting object %s using %s backend", path, key)
+			suite.Nil(err, message)
+		}
+
+		results, err := batchDeleteBackend.DeleteObjects(paths)
+		message := fmt.Sprintf("no error deleting objects using %s backend", key)
+		suite.Nil(err, message)
+		suite.Len(results, len(paths), message)
+		for i, result := range results {
+			message = fmt.Sprintf("object %s is deleted using %s backend", paths[i], key)
+			suite.Equal(paths[i], result.Path, message)
+			suite.Nil(result.Err, message)
+
+			_, err = backend.GetObject(paths[i])
+			suite.True(errors.Is(err, ErrObjectNotFound), message)
+		}
+
+		results, err = batchDeleteBackend.DeleteObjects(nil)
+		message = fmt.Sprintf("no error deleting no objects using %s backend", key)
+		suite.Nil(err, message)
+		suite.Empty(results, message)
+	}
+}
+
+func (suite *StorageTestSuite) TestDeleteObjectsInBatches() {
+	paths := []string{"a", "b", "c", "d", "e"}
+	var batches [][]string
+	failure := errors.New("batch failed")
+	results, err := deleteObjectsInBatches(paths, 2, func(batch []DeleteResult) error {
+		var names []string
+		for _, result := range batch {
+			names = append(names, result.Path)
+		}
+		batches = append(batches, names)
+		switch len(batches) {
+		case 1:
+			batch[1].Err = ErrPermissionDenied
+		case 2:
+			return failure
+		}
+		return nil
+	})
+	suite.Equal(failure, err)
+	suite.Equal([][]string{{"a", "b"}, {"c", "d"}, {"e"}}, batches)
+	suite.Equal([]DeleteResult{
+		{Path: "a"},
+		{Path: "b", Err: ErrPermissionDenied},
+		{Path: "c", Err: failure},
+		{Path: "d", Err: failure},
+		{Path: "e"},
+	}, results)
+}
+
+func (suite *StorageTestSuite) TestCopyBackend() {
+	for key, backend := range suite.StorageBackends {
+		copyBackend, ok := backend.(CopyBackend)
+		suite.True(ok, fmt.Sprintf("%s backend implements CopyBackend", key))
+		if !ok {
+			continue
+		}
+		src, dst, moved := "staging/mychart-0.1.0.tgz", "stable/mychart-0.1.0.tgz", "archive/mychart-0.1.0.tgz"
+		content := []byte("mychart")
+		err := backend.PutObject(src, content)
+		message := fmt.Sprintf("no error putting object %s using %s backend", src, key)
+		suite.Nil(err, message)
+
+		err = copyBackend.CopyObject(src, dst)
+		message = fmt.Sprintf("no error copying object %s to %s using %s backend", src, dst, key)
+		suite.Nil(err, message)
+		for _, path := range []string{src, dst} {
+			object, err := backend.GetObject(path)
+			message = fmt.Sprintf("object %s holds the copied content using %s backend", path, key)
+			suite.Nil(err, message)
+			suite.Equal(content, object.Content, message)
+		}
+
+		err = copyBackend.MoveObject(src, src)
+		message = fmt.Sprintf("moving object %s onto itself leaves it unchanged using %s backend", src, key)
+		suite.Nil(err, message)
+		_, err = backend.GetObject(src)
+		suite.Nil(err, message)
+
+		err = copyBackend.MoveObject(src, moved)
+		message = fmt.Sprintf("no error moving object %s to %s using %s backend", src, moved, key)
+		suite.Nil(err, message)
+		object, err := backend.GetObject(moved)
+		suite.Nil(err, message)
+		suite.Equal(content, object.Content, message)
+		_, err = backend.GetObject(src)
+		message = fmt.Sprintf("moved object %s is not found using %s backend", src, key)
+		suite.True(errors.Is(err, ErrObjectNotFound), message)
+
+		err = copyBackend.CopyObject(src, dst)
+		message = fmt.Sprintf("copying missing object %s fails using %s backend", src, key)
+		suite.True(errors.Is(err, ErrObjectNotFound), message)
+
+		err = copyBackend.MoveObject(src, dst)
+		message = fmt.Sprintf("moving missing object %s fails using %s backend", src, key)
+		suite.True(errors.Is(err, ErrObjectNotFound), message)
+
+		for _, path := range []string{dst, moved} {
+			err = backend.DeleteObject(path)
+			message = fmt.Sprintf("no error deleting object %s using %s backend", path, key)
+			suite.Nil(err, message)
+		}
+	}
+}
+
+func (suite *StorageTestSuite) TestPresigner() {
+	for key, backend := range suite.StorageBackends {
+		presigner, ok := backend.(Presigner)
+		if !ok {
+			continue
+		}
+		path := "presigned/mychart-0.1.0.tgz"
+		content := []byte("mychart")
+
+		putURL, err := presigner.PresignPut(path, 5*time.Minute)
+		message := fmt.Sprintf("no error presigning upload of %s using %s backend", path, key)
+		suite.Nil(err, message)
+		req, err := http.NewRequest(http.MethodPut, putURL, bytes.NewReader(content))
+		suite.Nil(err, message)
+		if key == "MicrosoftBlob" {
+			req.Header.Set("x-ms-blob-type", "BlockBlob")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		message = fmt.Sprintf("object %s is uploaded with a presigned URL using %s backend", path, key)
+		suite.Nil(err, message)
+		resp.Body.Close()
+		suite.Less(resp.StatusCode, 300, message)
+
+		getURL, err := presigner.PresignGet(path, 5*time.Minute)
+		message = fmt.Sprintf("no error presigning download of %s using %s backend", path, key)
+		suite.Nil(err, message)
+		resp, err = http.Get(getURL)
+		message = fmt.Sprintf("object %s is downloaded with a presigned URL using %s backend", path, key)
+		suite.Nil(err, message)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		suite.Nil(err, message)
+		suite.Equal(http.StatusOK, resp.StatusCode, message)
+		suite.Equal(content, body, message)
+
+		_, err = presigner.PresignGet(path, 0)
+		message = fmt.Sprintf("presigning with no lifetime fails using %s backend", key)
+		suite.NotNil(err, message)
+
+		err = backend.DeleteObject(path)
+		message = fmt.Sprintf("no error deleting object %s using %s backend", path, key)
+		suite.Nil(err, message)
+	}
+}
+
+func (suite *StorageTestSuite) TestPresignerCredentials() {
+	backend := NewAmazonS3BackendWithCredentials("mybucket", "charts", "us-east-1", "", "", credentials.AnonymousCredentials)
+	_, err := backend.PresignGet("mychart-0.1.0.tgz", time.Minute)
+	suite.True(errors.Is(err, ErrNotSupported), "presigning with anonymous credentials is not supported")
+
+	backend = NewAmazonS3BackendWithCredentials("mybucket", "charts", "us-east-1", "", "",
+		credentials.NewStaticCredentials("AKID", "SECRET", ""))
+	presignedURL, err := backend.PresignGet("mychart-0.1.0.tgz", time.Minute)
+	suite.Nil(err, "no error presigning with static credentials")
+	parsedURL, err := url.Parse(presignedURL)
+	suite.Nil(err, "presigned URL can be parsed")
+	suite.Equal("/charts/mychart-0.1.0.tgz", strings.TrimPrefix(parsedURL.Path, "/mybucket"), "presigned URL designates the object")
+	suite.Equal("60", parsedURL.Query().Get("X-Amz-Expires"), "presigned URL expires after the lifetime")
+	suite.NotEmpty(parsedURL.Query().Get("X-Amz-Signature"), "presigned URL is signed")
+
+	backend = NewAmazonS3BackendWithCredentials("mybucket", "charts", "us-east-1", "", "AES256",
+		credentials.NewStaticCredentials("AKID", "SECRET", ""))
+	presignedURL, err = backend.PresignPut("mychart-0.1.0.tgz", time.Minute)
+	suite.Nil(err, "no error presigning upload with SSE")
+	parsedURL, err = url.Parse(presignedURL)
+	suite.Nil(err)
+	suite.Contains(strings.Split(parsedURL.Query().Get("X-Amz-SignedHeaders"), ";"), "x-amz-server-side-encryption",
+		"server side encryption of uploads is signed")
+}
+
+func (suite *StorageTestSuite) TestVersionedBackend() {
+	for key, backend := range suite.StorageBackends {
+		versionedBackend, ok := backend.(VersionedBackend)
+		if !ok {
+			continue
+		}
+		path := "versioned/mychart-0.1.0.tgz"
+		for _, content := range []string{"version 1", "version 2"} {
+			err := backend.PutObject(path, []byte(content))
+			message := fmt.Sprintf("no error putting object %s using %s backend", path, key)
+			suite.Nil(err, message)
+		}
+
+		current, err := backend.GetObject(path)
+		message := fmt.Sprintf("no error getting object %s using %s backend", path, key)
+		suite.Nil(err, message)
+
+		versions, err := versionedBackend.ListObjectVersions("versioned")
+		message = fmt.Sprintf("no error listing versions of %s using %s backend", path, key)
+		suite.Nil(err, message)
+		suite.NotEmpty(versions, message)
+		// buckets without versioning only keep the current version
+		for _, version := range versions {
+			suite.Equal(path, version.Path, message)
+			suite.WithinDuration(time.Now(), version.LastModified, time.Hour, "versions are listed with their modification time")
+			object, err := versionedBackend.GetObjectVersion(path, version.Meta.Version)
+			message = fmt.Sprintf("no error getting version %q of %s using %s backend", version.Meta.Version, path, key)
+			suite.Nil(err, message)
+			suite.Equal(version.Meta.Version, object.Meta.Version, message)
+			if object.Meta.Version == current.Meta.Version {
+				suite.Equal(current.Content, object.Content, message)
+			} else {
+				suite.Equal([]byte("version 1"), object.Content, message)
+			}
+		}
+
+		for _, version := range versions {
+			err = versionedBackend.DeleteObjectVersion(path, version.Meta.Version)
+			message = fmt.Sprintf("deleting version %q of %s using %s backend", version.Meta.Version, path, key)
+			// versions which cannot be deleted are reported as a failed precondition, or missing once the object is deleted
+			suite.True(err == nil || errors.Is(err, ErrPreconditionFailed) || errors.Is(err, ErrObjectNotFound), message)
+		}
+		err = versionedBackend.DeleteObjectVersion("versioned/missing.tgz", current.Meta.Version)
+		message = fmt.Sprintf("deleting a version of a missing object is not found using %s backend", key)
+		suite.True(err == nil || errors.Is(err, ErrObjectNotFound), message)
+		_, err = versionedBackend.GetObjectVersion(path, current.Meta.Version)
+		message = fmt.Sprintf("deleted version %q of %s is not found using %s backend", current.Meta.Version, path, key)
+		suite.True(errors.Is(err, ErrObjectNotFound), message)
+	}
+}
+
+func (suite *StorageTestSuite) TestCapabilities() {
+	for key, backend := range suite.StorageBackends {
+		capabilityBackend, ok := backend.(CapabilityBackend)
+		suite.True(ok, fmt.Sprintf("%s backend implements CapabilityBackend", key))
+		if !ok {
+			continue
+		}
+		capabilities := capabilityBackend.Capabilities()
+		implemented := implementedCapabilities(backend)
+		if !capabilities.Has(CapabilityContext) {
+			// backends whose requests cannot be aborted only check the context between them, without declaring it
+			implemented = implemented.Without(CapabilityContext)
+		}
+		message := fmt.Sprintf("%s backend declares the capabilities of the interfaces it implements", key)
+		suite.Equal(implemented.String(), capabilities.Without(CapabilityListContent).String(), message)
+
+		objects, err := backend.ListObjects("")
+		message = fmt.Sprintf("no error listing objects using %s backend", key)
+		suite.Nil(err, message)
+		for _, object := range objects {
+			message = fmt.Sprintf("content of %s is listed as declared using %s backend", object.Path, key)
+			suite.Equal(capabilities.Has(CapabilityListContent), len(object.Content) > 0, message)
+		}
+
+		src, dst, moved := "capabilities/mychart-0.1.0.tgz", "capabilities/mychart-0.1.1.tgz", "capabilities/mychart-0.1.2.tgz"
+		content := []byte("mychart")
+		err = backend.PutObject(src, content)
+		message = fmt.Sprintf("no error putting object %s using %s backend", src, key)
+		suite.Nil(err, message)
+
+		info, err := StatObject(backend, src)
+		message = fmt.Sprintf("no error getting information of %s using %s backend", src, key)
+		suite.Nil(err, message)
+		suite.Equal(src, info.Path, message)
+
+		part, err := GetObjectRange(backend, src, 2, 3)
+		message = fmt.Sprintf("no error reading range of %s using %s backend", src, key)
+		suite.Nil(err, message)
+		suite.Equal([]byte("cha"), part, message)
+
+		err = CopyObject(backend, src, dst)
+		message = fmt.Sprintf("no error copying object %s using %s backend", src, key)
+		suite.Nil(err, message)
+		err = MoveObject(backend, dst, moved)
+		message = fmt.Sprintf("no error moving object %s using %s backend", dst, key)
+		suite.Nil(err, message)
+		object, err := backend.GetObject(moved)
+		suite.Nil(err, message)
+		suite.Equal(content, object.Content, message)
+
+		if capabilities.Has(CapabilityConditional) {
+			conditionalBackend := backend.(ConditionalBackend)
+			err = conditionalBackend.PutObjectIf(src, content, Condition{IfNotExists: true})
+			message = fmt.Sprintf("writing existing object %s if it does not exist fails using %s backend", src, key)
+			suite.True(errors.Is(err, ErrPreconditionFailed), message)
+			err = conditionalBackend.PutObjectIf(src, content, Condition{IfMatch: info.ETag})
+			message = fmt.Sprintf("no error writing object %s if its ETag matches using %s backend", src, key)
+			suite.Nil(err, message)
+		}
+
+		if capabilities.Has(CapabilityPresign) {
+			for name, presign := range map[string]func(string, time.Duration) (string, error){
+				"get": backend.(Presigner).PresignGet,
+				"put": backend.(Presigner).PresignPut,
+			} {
+				presigned, err := presign(src, time.Minute)
+				message = fmt.Sprintf("no error presigning %s of %s using %s backend", name, src, key)
+				suite.Nil(err, message)
+				_, err = url.Parse(presigned)
+				suite.Nil(err, message)
+				suite.NotEmpty(presigned, message)
+			}
+		}
+
+		if capabilities.Has(CapabilityVersioning) {
+			versions, err := backend.(VersionedBackend).ListObjectVersions("capabilities")
+			message = fmt.Sprintf("no error listing versions of %s using %s backend", src, key)
+			suite.Nil(err, message)
+			listed := false
+			for _, version := range versions {
+				listed = listed || version.Path == pathutil.Base(src)
+			}
+			suite.True(listed, message)
+		}
+
+		results, err := DeleteObjects(backend, []string{src, moved})
+		message = fmt.Sprintf("no error deleting objects using %s backend", key)
+		suite.Nil(err, message)
+		for _, result := range results {
+			suite.Nil(result.Err, message)
+		}
+	}
+}
+
+func (suite *StorageTestSuite) TestWrapperCapabilities() {
+	backend := suite.StorageBackends["LocalFilesystem"]
+	diskCache, err := NewDiskCacheBackend(backend, DiskCacheOptions{Directory: suite.TempDirectory + "-diskcache"})
+	suite.Nil(err)
+	defer os.RemoveAll(suite.TempDirectory + "-diskcache")
+	for key, wrapper := range map[string]CapabilityBackend{
+		"Caching":        NewCachingBackend(backend, CachingOptions{}),
+		"DiskCache":      diskCache,
+		"Retrying":       NewRetryingBackend(backend, RetryOptions{}),
+		"RateLimited":    NewRateLimitedBackend(backend, RateLimitOptions{}),
+		"Instrumented":   NewInstrumentedBackend(backend, "", nil),
+		"Tracing":        NewTracingBackend(backend, trace.NewNoopTracerProvider()),
+		"Logging":        NewLoggingBackend(backend, LoggingOptions{}),
+		"CircuitBreaker": NewCircuitBreakerBackend(backend, CircuitBreakerOptions{}),
+		"ReadOnly":       NewReadOnlyBackend(backend),
+	} {
+		// the optional interfaces of the wrapped backend are not forwarded, so they must not be declared
+		message := fmt.Sprintf("%s wrapper declares the capabilities of the interfaces it implements", key)
+		suite.Equal(implementedCapabilities(wrapper).String(), wrapper.Capabilities().Without(CapabilityListContent).String(), message)
+		suite.False(Supports(wrapper, CapabilityCopy), message)
+	}
+}
+
+func (suite *StorageTestSuite) TestGetObjectRange() {
+	for key, backend := range suite.StorageBackends {
+		rangeBackend, ok := backend.(RangeBackend)
+		suite.True(ok, fmt.Sprintf("%s backend implements RangeBackend", key))
+		if !ok {
+			continue
+		}
+		path := "range/mychart-0.1.0.tgz"
+		err := backend.PutObject(path, []byte("0123456789"))
+		message := fmt.Sprintf("no error putting object %s using %s backend", path, key)
+		suite.Nil(err, message)
+
+		for _, test := range []struct {
+			name           string
+			offset, length int64
+			expected       string
+		}{
+			{"first bytes", 0, 4, "0123"},
+			{"first byte", 0, 1, "0"},
+			{"middle bytes", 3, 4, "3456"},
+			{"last bytes", 6, 4, "6789"},
+			{"bytes until the end", 6, -1, "6789"},
+			{"whole object", 0, -1, "0123456789"},
+			{"no bytes", 3, 0, ""},
+			{"range extending past the end", 8, 10, "89"},
+			{"range starting at the end", 10, 5, ""},
+			{"range starting past the end", 20, 5, ""},
+			{"bytes past the end until the end", 20, -1, ""},
+		} {
+			content, err := rangeBackend.GetObjectRange(path, test.offset, test.length)
+			message = fmt.Sprintf("reading %s of object %s using %s backend", test.name, path, key)
+			suite.Nil(err, message)
+			suite.Equal(test.expected, string(content), message)
+		}
+
+		_, err = rangeBackend.GetObjectRange(path, -1, 5)
+		message = fmt.Sprintf("reading a negative offset of object %s fails using %s backend", path, key)
+		suite.NotNil(err, message)
+
+		_, err = rangeBackend.GetObjectRange("range/missing.tgz", 0, 5)
+		message = fmt.Sprintf("reading a range of a missing object fails using %s backend", key)
+		suite.True(errors.Is(err, ErrObjectNotFound), message)
+
+		err = backend.DeleteObject(path)
+		message = fmt.Sprintf("no error deleting object %s using %s backend", path, key)
+		suite.Nil(err, message)
+	}
+}
+
+func (suite *StorageTestSuite) TestGetObjectRangeRequests() {
+	content := []byte("0123456789")
+	var requested []string
+	getRange := func(offset int64, length int64) ([]byte, error) {
+		requested = append(requested, httpRange(offset, length))
+		if offset >= int64(len(content)) {
+			return nil, errRangeNotSatisfiable
+		}
+		return sliceRange(content, offset, length)
+	}
+	kind := func(err error) error { return err }
+
+	result, err := getObjectRange(2, 3, kind, getRange)
+	suite.Nil(err, "no error reading a range")
+	suite.Equal("234", string(result), "range holds the requested bytes")
+
+	result, err = getObjectRange(2, 0, kind, getRange)
+	suite.Nil(err, "no error reading an empty range")
+	suite.Equal("", string(result), "empty range holds no bytes")
+
+	result, err = getObjectRange(2, -1, kind, getRange)
+	suite.Nil(err, "no error reading until the end")
+	suite.Equal("23456789", string(result), "range holds the bytes until the end")
+
+	result, err = getObjectRange(12, 3, kind, getRange)
+	suite.Nil(err, "no error reading a range which cannot be satisfied")
+	suite.Equal("", string(result), "range starting past the end holds no bytes")
+
+	suite.Equal([]string{"bytes=2-4", "bytes=2-2", "bytes=2-", "bytes=12-14"}, requested, "HTTP ranges are requested")
+}
+
+func (suite *StorageTestSuite) TestObjectNotFound() {
+	path := "this-file-cannot-possibly-exist.tgz"
+	for key, backend := range suite.StorageBackends {
+		_, err := backend.GetObject(path)
+		message := fmt.Sprintf("getting missing object %s returns ErrObjectNotFound using %s backend", path, key)
+		suite.True(errors.Is(err, ErrObjectNotFound), message)
+
+		var storageErr *StorageError
+		message = fmt.Sprintf("getting missing object %s returns a StorageError using %s backend", path, key)
+		suite.True(errors.As(err, &storageErr), message)
+		suite.Equal(key, storageErr.Backend, message)
+		suite.Equal("GetObject", storageErr.Op, message)
+		suite.Equal(path, storageErr.Path, message)
+
+		if streamBackend, ok := backend.(StreamBackend); ok {
+			_, _, err = streamBackend.GetObjectReader(path)
+			message = fmt.Sprintf("opening missing object %s returns ErrObjectNotFound using %s backend", path, key)
+			suite.True(errors.Is(err, ErrObjectNotFound), message)
+		}
+	}
+}
+
+func (suite *StorageTestSuite) TestLoggerBackend() {
+	path := "this-file-cannot-possibly-exist.tgz"
+	for key, backend := range suite.StorageBackends {
+		loggerBackend, ok := backend.(LoggerBackend)
+		suite.True(ok, fmt.Sprintf("%s backend implements LoggerBackend", key))
+		if !ok {
+			continue
+		}
+		var buf bytes.Buffer
+		loggerBackend.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		_, err := backend.GetObject(path)
+		loggerBackend.SetLogger(nil)
+		suite.True(errors.Is(err, ErrObjectNotFound))
+
+		message := fmt.Sprintf("failed operation is logged once using %s backend", key)
+		suite.Equal(1, strings.Count(buf.String(), "storage operation failed"), message)
+		suite.Contains(buf.String(), "level=DEBUG", message)
+		suite.Contains(buf.String(), "backend="+key, message)
+		suite.Contains(buf.String(), "path="+path, message)
+	}
+}
+
 func (suite *StorageTestSuite) TestHasSuffix() {
 	now := time.Now()
 	o1 := Object{
@@ -178,6 +956,28 @@ func (suite *StorageTestSuite) TestHasSuffix() {
 	suite.False(o2.HasExtension("tgz"), "object does not have tgz suffix")
 }
 
+func (suite *StorageTestSuite) TestObjectInfoFromHeader() {
+	header := http.Header{}
+	header.Set("Content-Length", "42")
+	header.Set("Content-Type", "application/gzip")
+	header.Set("ETag", "\"d41d8cd98f00b204e9800998ecf8427e\"")
+	header.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	header.Set("X-Oss-Storage-Class", "IA")
+	header.Set("X-Oss-Meta-Chart-Name", "mychart")
+	info := objectInfoFromHeader("mychart-0.1.0.tgz", header, "oss")
+	suite.Equal("mychart-0.1.0.tgz", info.Path, "path as expected")
+	suite.Equal(int64(42), info.Size, "size parsed from header")
+	suite.Equal("application/gzip", info.ContentType, "content type parsed from header")
+	suite.Equal("d41d8cd98f00b204e9800998ecf8427e", info.ETag, "quotes removed from ETag")
+	suite.Equal(time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC), info.LastModified, "last modified parsed from header")
+	suite.Equal("IA", info.StorageClass, "storage class parsed from vendor header")
+	suite.Equal(map[string]string{"chart-name": "mychart"}, info.UserMetadata, "user metadata parsed from vendor headers")
+
+	info = objectInfoFromHeader("mychart-0.1.0.tgz", http.Header{}, "cos")
+	suite.Equal(int64(-1), info.Size, "unknown size without Content-Length header")
+	suite.Nil(info.UserMetadata, "no user metadata without vendor headers")
+}
+
 func (suite *StorageTestSuite) TestGetObjectSliceDiff() {
 	now := time.Now()
 	os1 := []Object{
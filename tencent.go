@@ -19,12 +19,17 @@ package storage
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"iter"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	pathutil "path"
+	"strings"
 	"time"
 
 	"github.com/tencentyun/cos-go-sdk-v5"
@@ -36,24 +41,40 @@ type TencentCloudCOSBackend struct {
 	Object *cos.ObjectService
 	Client *cos.Client
 	Prefix string
+	logger *slog.Logger
 }
 
 const (
 	HTTPHeaderLastModified = "Last-Modified"
 )
 
-// NewTencentCloudCOSBackend creates a new instance of TencentCloudCOSBackend
+// NewTencentCloudCOSBackend creates a new instance of TencentCloudCOSBackend.
+// It panics if the backend cannot be created; NewTencentCloudCOSBackendE returns the error instead.
 func NewTencentCloudCOSBackend(bucket string, prefix string, endpoint string) *TencentCloudCOSBackend {
+	t, err := NewTencentCloudCOSBackendE(bucket, prefix, endpoint)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// NewTencentCloudCOSBackendE creates a new instance of TencentCloudCOSBackend, using the credentials
+// of the TENCENT_CLOUD_COS_SECRET_ID and TENCENT_CLOUD_COS_SECRET_KEY environment variables
+func NewTencentCloudCOSBackendE(bucket string, prefix string, endpoint string) (*TencentCloudCOSBackend, error) {
 
 	secretID := os.Getenv("TENCENT_CLOUD_COS_SECRET_ID")
 	secretKey := os.Getenv("TENCENT_CLOUD_COS_SECRET_KEY")
 
+	if len(bucket) == 0 {
+		return nil, configError("tencent", "missing bucket")
+	}
+
 	if len(secretID) == 0 {
-		panic("TENCENT_CLOUD_COS_SECRET_ID environment variable is not set")
+		return nil, configError("tencent", "TENCENT_CLOUD_COS_SECRET_ID environment variable is not set")
 	}
 
 	if len(secretKey) == 0 {
-		panic("TENCENT_CLOUD_COS_SECRET_KEY environment variable is not set")
+		return nil, configError("tencent", "TENCENT_CLOUD_COS_SECRET_KEY environment variable is not set")
 	}
 
 	if len(endpoint) == 0 {
@@ -63,7 +84,7 @@ func NewTencentCloudCOSBackend(bucket string, prefix string, endpoint string) *T
 
 	bucketURL, err := url.Parse("http://" + bucket + "." + endpoint)
 	if err != nil {
-		panic("Access domain is error: http://" + bucket + "." + endpoint)
+		return nil, configError("tencent", "invalid access domain http://%s.%s: %s", bucket, endpoint, err)
 	}
 	baseURL := &cos.BaseURL{BucketURL: bucketURL}
 
@@ -71,6 +92,7 @@ func NewTencentCloudCOSBackend(bucket string, prefix string, endpoint string) *T
 		Transport: &cos.AuthorizationTransport{
 			SecretID:  secretID,
 			SecretKey: secretKey,
+			Transport: tracingTransport(http.DefaultTransport),
 		},
 	})
 
@@ -80,11 +102,31 @@ func NewTencentCloudCOSBackend(bucket string, prefix string, endpoint string) *T
 		Client: client,
 		Prefix: cleanPrefix(prefix),
 	}
-	return tencentCloudCOSBackend
+	return tencentCloudCOSBackend, nil
+}
+
+// SetLogger sets the logger of the failed operations on Tencent Cloud COS. Nothing is logged without a logger.
+func (t *TencentCloudCOSBackend) SetLogger(logger *slog.Logger) {
+	t.logger = logger
+}
+
+// Capabilities returns the optional features supported by Tencent Cloud COS
+func (t TencentCloudCOSBackend) Capabilities() CapabilitySet {
+	return NewCapabilitySet(
+		CapabilityContext, CapabilityStream, CapabilityRange, CapabilityMetadata,
+		CapabilityStat, CapabilityHierarchical, CapabilityPaging, CapabilityBatchDelete,
+		CapabilityCopy, CapabilityPresign, CapabilityVersioning,
+	)
 }
 
 // ListObjects lists all objects in Tencent Cloud COS bucket, at prefix
 func (t TencentCloudCOSBackend) ListObjects(prefix string) ([]Object, error) {
+	return t.ListObjectsContext(context.Background(), prefix)
+}
+
+// ListObjectsContext lists all objects in Tencent Cloud COS bucket, at prefix, using ctx for the requests
+func (t TencentCloudCOSBackend) ListObjectsContext(ctx context.Context, prefix string) (_ []Object, err error) {
+	defer t.wrapError(&err, "ListObjects", prefix)
 
 	var objects []Object
 
@@ -98,10 +140,10 @@ func (t TencentCloudCOSBackend) ListObjects(prefix string) ([]Object, error) {
 	for {
 		opt := &cos.BucketGetOptions{
 			Prefix:  cosPrefix,
-			MaxKeys: 100,
+			MaxKeys: defaultPageSize,
 			Marker:  cosMarker,
 		}
-		bucketGetResult, _, err := t.Bucket.Get(context.Background(), opt)
+		bucketGetResult, _, err := t.Bucket.Get(ctx, opt)
 		if err != nil {
 			return objects, err
 		}
@@ -116,6 +158,9 @@ func (t TencentCloudCOSBackend) ListObjects(prefix string) ([]Object, error) {
 				Path:         path,
 				Content:      []byte{},
 				LastModified: lastModified,
+				Size:         obj.Size,
+				ETag:         cleanETag(obj.ETag),
+				StorageClass: obj.StorageClass,
 			}
 			objects = append(objects, object)
 		}
@@ -131,8 +176,110 @@ func (t TencentCloudCOSBackend) ListObjects(prefix string) ([]Object, error) {
 	return objects, nil
 }
 
+// ListObjectsPage lists a page of the objects in Tencent Cloud COS bucket, at prefix.
+// The token is the marker returned by COS for the next page.
+func (t TencentCloudCOSBackend) ListObjectsPage(prefix string, token string, limit int) (_ ObjectPage, err error) {
+	defer t.wrapError(&err, "ListObjectsPage", prefix)
+
+	var page ObjectPage
+
+	prefix = listPrefix(t.Prefix, prefix)
+	opt := &cos.BucketGetOptions{
+		Prefix:    prefix,
+		Delimiter: "/",
+		Marker:    token,
+		MaxKeys:   pageSize(limit),
+	}
+	bucketGetResult, _, err := t.Bucket.Get(context.Background(), opt)
+	if err != nil {
+		return page, err
+	}
+
+	for _, obj := range bucketGetResult.Contents {
+		path := strings.TrimPrefix(obj.Key, prefix)
+		if objectPathIsInvalid(path) {
+			continue
+		}
+		lastModified, _ := time.Parse(time.RFC3339, obj.LastModified)
+		object := Object{
+			Path:         path,
+			Content:      []byte{},
+			LastModified: lastModified,
+			Size:         obj.Size,
+			ETag:         cleanETag(obj.ETag),
+			StorageClass: obj.StorageClass,
+		}
+		page.Objects = append(page.Objects, object)
+	}
+	if bucketGetResult.IsTruncated {
+		page.NextToken = bucketGetResult.NextMarker
+	}
+
+	return page, nil
+}
+
+// ListObjectsIter iterates over the objects in Tencent Cloud COS bucket, at prefix
+func (t TencentCloudCOSBackend) ListObjectsIter(prefix string) iter.Seq2[Object, error] {
+	return listObjectsIter(t, prefix)
+}
+
+// ListObjectsWithOptions lists the objects and common prefixes below prefix in Tencent Cloud COS bucket
+func (t TencentCloudCOSBackend) ListObjectsWithOptions(prefix string, options ListObjectsOptions) (_ ListObjectsResult, err error) {
+	defer t.wrapError(&err, "ListObjects", prefix)
+
+	var result ListObjectsResult
+
+	prefix = listPrefix(t.Prefix, prefix)
+	opt := &cos.BucketGetOptions{
+		Prefix:    prefix,
+		Delimiter: options.nativeDelimiter(),
+		MaxKeys:   1000,
+	}
+
+	for {
+		bucketGetResult, _, err := t.Bucket.Get(context.Background(), opt)
+		if err != nil {
+			return result, err
+		}
+
+		for _, obj := range bucketGetResult.Contents {
+			lastModified, _ := time.Parse(time.RFC3339, obj.LastModified)
+			path := strings.TrimPrefix(obj.Key, prefix)
+			if objectPathIsInvalid(path) {
+				continue
+			}
+			object := Object{
+				Path:         path,
+				Content:      []byte{},
+				LastModified: lastModified,
+				Size:         obj.Size,
+				ETag:         cleanETag(obj.ETag),
+				StorageClass: obj.StorageClass,
+			}
+			result.Objects = append(result.Objects, object)
+		}
+		for _, commonPrefix := range bucketGetResult.CommonPrefixes {
+			result.CommonPrefixes = append(result.CommonPrefixes, strings.TrimPrefix(commonPrefix, prefix))
+		}
+
+		if !bucketGetResult.IsTruncated {
+			break
+		}
+
+		opt.Marker = bucketGetResult.NextMarker
+	}
+
+	return result, nil
+}
+
 // GetObject retrieves an object from Tencent Cloud COS bucket, at prefix
 func (t TencentCloudCOSBackend) GetObject(path string) (Object, error) {
+	return t.GetObjectContext(context.Background(), path)
+}
+
+// GetObjectContext retrieves an object from Tencent Cloud COS bucket, at prefix, using ctx for the request
+func (t TencentCloudCOSBackend) GetObjectContext(ctx context.Context, path string) (_ Object, err error) {
+	defer t.wrapError(&err, "GetObject", path)
 
 	var object Object
 	object.Path = path
@@ -141,7 +288,7 @@ func (t TencentCloudCOSBackend) GetObject(path string) (Object, error) {
 	key := pathutil.Join(t.Prefix, path)
 
 	opt := &cos.ObjectGetOptions{}
-	resp, err := t.Object.Get(context.Background(), key, opt)
+	resp, err := t.Object.Get(ctx, key, opt)
 	if err != nil {
 		return object, err
 	}
@@ -158,26 +305,260 @@ func (t TencentCloudCOSBackend) GetObject(path string) (Object, error) {
 		return object, err
 	}
 
+	object = objectWithInfo(objectInfoFromHeader(path, resp.Header, "cos"), content)
 	object.LastModified = lastModified
 	return object, nil
 }
 
 // PutObject uploads an object to Tencent Cloud COS bucket, at prefix
 func (t TencentCloudCOSBackend) PutObject(path string, content []byte) error {
+	return t.PutObjectContext(context.Background(), path, content)
+}
+
+// PutObjectContext uploads an object to Tencent Cloud COS bucket, at prefix, using ctx for the request
+func (t TencentCloudCOSBackend) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	defer t.wrapError(&err, "PutObject", path)
 
 	key := pathutil.Join(t.Prefix, path)
-	var err error
 
 	opt := &cos.ObjectPutOptions{}
+	_, err = t.Object.Put(ctx, key, bytes.NewReader(content), opt)
+
+	return err
+}
+
+// PutObjectWithOptions uploads an object to Tencent Cloud COS bucket, at prefix, along with its content type,
+// storage class and user metadata
+func (t TencentCloudCOSBackend) PutObjectWithOptions(path string, content []byte, options PutObjectOptions) (err error) {
+	defer t.wrapError(&err, "PutObjectWithOptions", path)
+
+	key := pathutil.Join(t.Prefix, path)
+
+	headerOptions := &cos.ObjectPutHeaderOptions{
+		ContentType:      options.ContentType,
+		XCosStorageClass: options.StorageClass,
+	}
+	if len(options.UserMetadata) > 0 {
+		metadata := make(http.Header)
+		for key, value := range options.UserMetadata {
+			metadata.Set("x-cos-meta-"+key, value)
+		}
+		headerOptions.XCosMetaXXX = &metadata
+	}
+	opt := &cos.ObjectPutOptions{ObjectPutHeaderOptions: headerOptions}
 	_, err = t.Object.Put(context.Background(), key, bytes.NewReader(content), opt)
 
 	return err
 }
 
+// GetObjectReader opens an object in Tencent Cloud COS bucket, at prefix, for reading
+func (t TencentCloudCOSBackend) GetObjectReader(path string) (_ io.ReadCloser, _ ObjectInfo, err error) {
+	defer t.wrapError(&err, "GetObjectReader", path)
+	key := pathutil.Join(t.Prefix, path)
+	resp, err := t.Object.Get(context.Background(), key, &cos.ObjectGetOptions{})
+	if err != nil {
+		return nil, ObjectInfo{Path: path}, err
+	}
+	return resp.Body, objectInfoFromHeader(path, resp.Header, "cos"), nil
+}
+
+// GetObjectRange reads part of an object from Tencent Cloud COS bucket, at prefix, with an HTTP range request
+func (t TencentCloudCOSBackend) GetObjectRange(path string, offset int64, length int64) (_ []byte, err error) {
+	defer t.wrapError(&err, "GetObjectRange", path)
+	return getObjectRange(offset, length, tencentCloudCOSErrorKind, func(offset int64, length int64) ([]byte, error) {
+		resp, err := t.Object.Get(context.Background(), pathutil.Join(t.Prefix, path), &cos.ObjectGetOptions{Range: httpRange(offset, length)})
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		return io.ReadAll(resp.Body)
+	})
+}
+
+// PutObjectStream uploads an object to Tencent Cloud COS bucket, at prefix, from a stream.
+// When size is negative the content is sent with chunked transfer encoding.
+func (t TencentCloudCOSBackend) PutObjectStream(path string, content io.Reader, size int64) (err error) {
+	defer t.wrapError(&err, "PutObjectStream", path)
+	key := pathutil.Join(t.Prefix, path)
+	opt := &cos.ObjectPutOptions{}
+	if size >= 0 {
+		opt.ObjectPutHeaderOptions = &cos.ObjectPutHeaderOptions{ContentLength: size}
+	}
+	_, err = t.Object.Put(context.Background(), key, content, opt)
+	return err
+}
+
+// StatObject retrieves the information of an object in Tencent Cloud COS bucket, at prefix, with a HEAD request
+func (t TencentCloudCOSBackend) StatObject(path string) (_ ObjectInfo, err error) {
+	defer t.wrapError(&err, "StatObject", path)
+	resp, err := t.Object.Head(context.Background(), pathutil.Join(t.Prefix, path), nil)
+	if err != nil {
+		return ObjectInfo{Path: path}, err
+	}
+	return objectInfoFromHeader(path, resp.Header, "cos"), nil
+}
+
 // DeleteObject removes an object from Tencent Cloud COS bucket, at prefix
 func (t TencentCloudCOSBackend) DeleteObject(path string) error {
+	return t.DeleteObjectContext(context.Background(), path)
+}
+
+// DeleteObjectContext removes an object from Tencent Cloud COS bucket, at prefix, using ctx for the request
+func (t TencentCloudCOSBackend) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	defer t.wrapError(&err, "DeleteObject", path)
 
 	key := pathutil.Join(t.Prefix, path)
-	_, err := t.Object.Delete(context.Background(), key)
+	_, err = t.Object.Delete(ctx, key)
+	return err
+}
+
+// DeleteObjects removes objects from Tencent Cloud COS bucket, at prefix, with up to 1000 keys per request
+func (t TencentCloudCOSBackend) DeleteObjects(paths []string) ([]DeleteResult, error) {
+	return deleteObjectsInBatches(paths, maxDeleteBatchSize, t.deleteBatch)
+}
+
+// deleteBatch removes the objects of a batch from Tencent Cloud COS bucket, at prefix, with a single request
+func (t TencentCloudCOSBackend) deleteBatch(batch []DeleteResult) (err error) {
+	defer t.wrapError(&err, "DeleteObjects", "")
+	results := make(map[string]*DeleteResult, len(batch))
+	opt := &cos.ObjectDeleteMultiOptions{Quiet: true}
+	for i := range batch {
+		key := pathutil.Join(t.Prefix, batch[i].Path)
+		results[key] = &batch[i]
+		opt.Objects = append(opt.Objects, cos.Object{Key: key})
+	}
+	deleteResult, _, err := t.Object.DeleteMulti(context.Background(), opt)
+	if err != nil {
+		return err
+	}
+	for _, cosError := range deleteResult.Errors {
+		if result, ok := results[cosError.Key]; ok {
+			result.Err = fmt.Errorf("%s: %s", cosError.Code, cosError.Message)
+			t.wrapError(&result.Err, "DeleteObjects", result.Path)
+		}
+	}
+	return nil
+}
+
+// CopyObject copies an object within Tencent Cloud COS bucket, at prefix, without downloading it
+func (t TencentCloudCOSBackend) CopyObject(src string, dst string) (err error) {
+	defer t.wrapError(&err, "CopyObject", src)
+	sourceURL := pathutil.Join(t.Client.BaseURL.BucketURL.Host, t.Prefix, src)
+	_, _, err = t.Object.Copy(context.Background(), pathutil.Join(t.Prefix, dst), sourceURL, nil)
+	return err
+}
+
+// MoveObject copies an object within Tencent Cloud COS bucket, at prefix, and deletes the original
+func (t TencentCloudCOSBackend) MoveObject(src string, dst string) (err error) {
+	defer t.wrapError(&err, "MoveObject", src)
+	return moveObject(src, dst, t.CopyObject, t.DeleteObject)
+}
+
+// PresignGet generates a signed URL to download an object from Tencent Cloud COS bucket, at prefix, until ttl expires
+func (t TencentCloudCOSBackend) PresignGet(path string, ttl time.Duration) (_ string, err error) {
+	defer t.wrapError(&err, "PresignGet", path)
+	return t.presignedURL(path, http.MethodGet, ttl)
+}
+
+// PresignPut generates a signed URL to upload an object to Tencent Cloud COS bucket, at prefix, until ttl expires
+func (t TencentCloudCOSBackend) PresignPut(path string, ttl time.Duration) (_ string, err error) {
+	defer t.wrapError(&err, "PresignPut", path)
+	return t.presignedURL(path, http.MethodPut, ttl)
+}
+
+// presignedURL signs a request with the secret key of the client, which is only known to clients authorized
+// with an AuthorizationTransport
+func (t TencentCloudCOSBackend) presignedURL(path string, method string, ttl time.Duration) (string, error) {
+	if err := validatePresignTTL(ttl); err != nil {
+		return "", err
+	}
+	credential := t.Client.GetCredential()
+	if credential == nil {
+		return "", ErrNotSupported
+	}
+	var opt interface{}
+	if credential.SessionToken != "" {
+		opt = &cos.PresignedURLOptions{Query: &url.Values{"x-cos-security-token": []string{credential.SessionToken}}}
+	}
+	presignedURL, err := t.Object.GetPresignedURL(context.Background(), method, pathutil.Join(t.Prefix, path),
+		credential.SecretID, credential.SecretKey, ttl, opt)
+	if err != nil {
+		return "", err
+	}
+	return presignedURL.String(), nil
+}
+
+// ListObjectVersions lists all versions of the objects in Tencent Cloud COS bucket, at prefix.
+// Versions are only kept by buckets with versioning enabled; delete markers are not listed.
+func (t TencentCloudCOSBackend) ListObjectVersions(prefix string) (_ []Object, err error) {
+	defer t.wrapError(&err, "ListObjectVersions", prefix)
+	var objects []Object
+	prefix = pathutil.Join(t.Prefix, prefix)
+	opt := &cos.BucketGetObjectVersionsOptions{Prefix: prefix, MaxKeys: defaultPageSize}
+	for {
+		result, _, err := t.Bucket.GetObjectVersions(context.Background(), opt)
+		if err != nil {
+			return objects, err
+		}
+		for _, version := range result.Version {
+			path := removePrefixFromObjectPath(prefix, version.Key)
+			if objectPathIsInvalid(path) {
+				continue
+			}
+			lastModified, _ := time.Parse(time.RFC3339, version.LastModified)
+			objects = append(objects, Object{
+				Meta:         Metadata{Name: path, Version: version.VersionId},
+				Path:         path,
+				Content:      []byte{},
+				LastModified: lastModified,
+				Size:         int64(version.Size),
+				ETag:         cleanETag(version.ETag),
+				StorageClass: version.StorageClass,
+			})
+		}
+		if !result.IsTruncated {
+			break
+		}
+		opt.KeyMarker, opt.VersionIdMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+	return objects, nil
+}
+
+// GetObjectVersion retrieves a version of an object from Tencent Cloud COS bucket, at prefix
+func (t TencentCloudCOSBackend) GetObjectVersion(path string, versionID string) (_ Object, err error) {
+	defer t.wrapError(&err, "GetObjectVersion", path)
+	object := Object{Path: path}
+	resp, err := t.Object.Get(context.Background(), pathutil.Join(t.Prefix, path), &cos.ObjectGetOptions{}, versionID)
+	if err != nil {
+		return object, err
+	}
+	content, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return object, err
+	}
+	object = objectWithInfo(objectInfoFromHeader(path, resp.Header, "cos"), content)
+	object.Meta = Metadata{Name: path, Version: versionID}
+	return object, nil
+}
+
+// DeleteObjectVersion permanently removes a version of an object from Tencent Cloud COS bucket, at prefix
+func (t TencentCloudCOSBackend) DeleteObjectVersion(path string, versionID string) (err error) {
+	defer t.wrapError(&err, "DeleteObjectVersion", path)
+	_, err = t.Object.Delete(context.Background(), pathutil.Join(t.Prefix, path), &cos.ObjectDeleteOptions{VersionId: versionID})
 	return err
 }
+
+func (t TencentCloudCOSBackend) wrapError(err *error, op string, path string) {
+	if wrapStorageError(err, "TencentCloudCOS", op, path, tencentCloudCOSErrorKind) {
+		logStorageError(t.logger, *err)
+	}
+}
+
+func tencentCloudCOSErrorKind(err error) error {
+	var errorResponse *cos.ErrorResponse
+	if errors.As(err, &errorResponse) && errorResponse.Response != nil {
+		return kindFromStatusCode(errorResponse.Response.StatusCode)
+	}
+	return nil
+}
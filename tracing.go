@@ -0,0 +1,242 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the instrumentation library creating the spans of a TracingBackend
+const tracerName = "github.com/chartmuseum/storage"
+
+// Attributes of the spans created by a TracingBackend
+const (
+	AttributeBackend      = attribute.Key("storage.backend")
+	AttributeBucket       = attribute.Key("storage.bucket")
+	AttributeKey          = attribute.Key("storage.key")
+	AttributePrefix       = attribute.Key("storage.prefix")
+	AttributeBytes        = attribute.Key("storage.bytes")
+	AttributeObjects      = attribute.Key("storage.objects")
+	AttributeOutcome      = attribute.Key("storage.outcome")
+	AttributeHTTPRequests = attribute.Key("storage.http.requests")
+	AttributeSDKRetries   = attribute.Key("storage.sdk.retries")
+)
+
+type (
+	// TracingBackend is a Backend which creates an OpenTelemetry span around every operation made on another
+	// Backend. The spans describe the backend, bucket, key and bytes transferred, and the number of HTTP
+	// requests made by the SDK of the backend, along with those it sent again, so that its retries show up.
+	// The HTTP clients of the backends of this package are instrumented to propagate the trace context to the
+	// storage services, except Baidu Cloud BOS, whose SDK sends every request with a package-global client.
+	TracingBackend struct {
+		backend        Backend
+		contextBackend ContextBackend
+		tracer         trace.Tracer
+		attributes     []attribute.KeyValue
+	}
+
+	// httpRequestsKey is the context key of the httpRequests of a traced operation
+	httpRequestsKey struct{}
+
+	// httpRequests counts the HTTP requests made within a traced operation, and those which repeat an earlier
+	// request with the same method, URL and range, which the SDK of the backend retried
+	httpRequests struct {
+		mu       sync.Mutex
+		requests int64
+		retries  int64
+		sent     map[string]bool
+	}
+
+	// requestCountingTransport counts the HTTP requests made within a traced operation
+	requestCountingTransport struct {
+		base http.RoundTripper
+	}
+)
+
+// NewTracingBackend creates a new instance of TracingBackend tracing the operations made on backend with
+// the tracers of provider, or of the global TracerProvider if provider is nil
+func NewTracingBackend(backend Backend, provider trace.TracerProvider) *TracingBackend {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	attributes := []attribute.KeyValue{AttributeBackend.String(backendName(backend))}
+	if bucket := backendBucket(backend); bucket != "" {
+		attributes = append(attributes, AttributeBucket.String(bucket))
+	}
+	return &TracingBackend{
+		backend:        backend,
+		contextBackend: NewContextBackend(backend),
+		tracer:         provider.Tracer(tracerName),
+		attributes:     attributes,
+	}
+}
+
+// Capabilities returns the optional features supported by the TracingBackend, which accepts contexts
+// and lists content if the traced backend does
+func (t *TracingBackend) Capabilities() CapabilitySet {
+	return CapabilitiesOf(t.backend)&NewCapabilitySet(CapabilityListContent) | NewCapabilitySet(CapabilityContext)
+}
+
+// ListObjects lists all objects at prefix, in a new trace
+func (t *TracingBackend) ListObjects(prefix string) ([]Object, error) {
+	return t.ListObjectsContext(context.Background(), prefix)
+}
+
+// GetObject retrieves an object at path, in a new trace
+func (t *TracingBackend) GetObject(path string) (Object, error) {
+	return t.GetObjectContext(context.Background(), path)
+}
+
+// PutObject uploads an object to path, in a new trace
+func (t *TracingBackend) PutObject(path string, content []byte) error {
+	return t.PutObjectContext(context.Background(), path, content)
+}
+
+// DeleteObject removes an object at path, in a new trace
+func (t *TracingBackend) DeleteObject(path string) error {
+	return t.DeleteObjectContext(context.Background(), path)
+}
+
+// ListObjectsContext lists all objects at prefix, in a span child of the span of ctx
+func (t *TracingBackend) ListObjectsContext(ctx context.Context, prefix string) (objects []Object, err error) {
+	ctx, span := t.start(ctx, "ListObjects", AttributePrefix.String(prefix))
+	defer t.end(ctx, span, &err)
+	objects, err = t.contextBackend.ListObjectsContext(ctx, prefix)
+	span.SetAttributes(AttributeObjects.Int(len(objects)))
+	return objects, err
+}
+
+// GetObjectContext retrieves an object at path, in a span child of the span of ctx
+func (t *TracingBackend) GetObjectContext(ctx context.Context, path string) (object Object, err error) {
+	ctx, span := t.start(ctx, "GetObject", AttributeKey.String(path))
+	defer t.end(ctx, span, &err)
+	object, err = t.contextBackend.GetObjectContext(ctx, path)
+	span.SetAttributes(AttributeBytes.Int(len(object.Content)))
+	return object, err
+}
+
+// PutObjectContext uploads an object to path, in a span child of the span of ctx
+func (t *TracingBackend) PutObjectContext(ctx context.Context, path string, content []byte) (err error) {
+	ctx, span := t.start(ctx, "PutObject", AttributeKey.String(path), AttributeBytes.Int(len(content)))
+	defer t.end(ctx, span, &err)
+	return t.contextBackend.PutObjectContext(ctx, path, content)
+}
+
+// DeleteObjectContext removes an object at path, in a span child of the span of ctx
+func (t *TracingBackend) DeleteObjectContext(ctx context.Context, path string) (err error) {
+	ctx, span := t.start(ctx, "DeleteObject", AttributeKey.String(path))
+	defer t.end(ctx, span, &err)
+	return t.contextBackend.DeleteObjectContext(ctx, path)
+}
+
+// start starts the span of an operation, returning a context holding the span and a counter of HTTP requests
+func (t *TracingBackend) start(ctx context.Context, op string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx, span := t.tracer.Start(ctx, "storage."+op,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(t.attributes...),
+		trace.WithAttributes(attributes...),
+	)
+	return context.WithValue(ctx, httpRequestsKey{}, &httpRequests{sent: make(map[string]bool)}), span
+}
+
+// end ends the span of an operation which returned *err, recording the HTTP requests made by the operation
+func (t *TracingBackend) end(ctx context.Context, span trace.Span, err *error) {
+	if requests, retries := ctx.Value(httpRequestsKey{}).(*httpRequests).counts(); requests > 0 {
+		span.SetAttributes(AttributeHTTPRequests.Int64(requests), AttributeSDKRetries.Int64(retries))
+	}
+	span.SetAttributes(AttributeOutcome.String(Outcome(*err)))
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(otelcodes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// backendBucket returns the bucket or container of a backend, or an empty string if it has none
+func backendBucket(backend Backend) string {
+	switch b := backend.(type) {
+	case *AmazonS3Backend:
+		return b.Bucket
+	case *GoogleCSBackend:
+		if b.Client != nil {
+			return b.Client.Object("").BucketName()
+		}
+	case *MicrosoftBlobBackend:
+		if b.Container != nil {
+			return b.Container.Name
+		}
+	case *AlibabaCloudOSSBackend:
+		if b.Bucket != nil {
+			return b.Bucket.BucketName
+		}
+	case *TencentCloudCOSBackend:
+		if b.Client != nil && b.Client.BaseURL != nil && b.Client.BaseURL.BucketURL != nil {
+			bucket, _, _ := strings.Cut(b.Client.BaseURL.BucketURL.Host, ".")
+			return bucket
+		}
+	case *BaiduBOSBackend:
+		return b.Bucket
+	case *OpenstackOSBackend:
+		return b.Container
+	case *OracleCSBackend:
+		return b.Bucket
+	}
+	return ""
+}
+
+// tracingTransport instruments the transport of the HTTP client of a backend, so that its requests
+// propagate the trace context of their context, are traced, and are counted by the TracingBackend
+func tracingTransport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(requestCountingTransport{base: base})
+}
+
+// RoundTrip counts the request in the operation traced by its context, and sends it with the base transport
+func (t requestCountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if requests, ok := req.Context().Value(httpRequestsKey{}).(*httpRequests); ok {
+		requests.add(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// add counts a request, as a retry if the same request was already sent. Pages of a listing, parts of a
+// multipart upload and chunks of a resumable upload differ by their query or range, and are not retries.
+func (r *httpRequests) add(req *http.Request) {
+	key := strings.Join([]string{req.Method, req.URL.String(), req.Header.Get("Range"), req.Header.Get("Content-Range")}, " ")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	if r.sent[key] {
+		r.retries++
+	}
+	r.sent[key] = true
+}
+
+// counts returns the number of requests and retries counted
+func (r *httpRequests) counts() (int64, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests, r.retries
+}
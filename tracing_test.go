@@ -0,0 +1,213 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// httpBackend gets objects from an HTTP server, retrying once on server errors like the SDKs of storage services do
+type httpBackend struct {
+	contextAdapter
+	url    string
+	client *http.Client
+}
+
+func (b httpBackend) GetObjectContext(ctx context.Context, path string) (Object, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+"/"+path, nil)
+		if err != nil {
+			return Object{Path: path}, err
+		}
+		resp, err := b.client.Do(req)
+		if err != nil {
+			return Object{Path: path}, err
+		}
+		content, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError && attempt == 0 {
+			continue
+		}
+		return Object{Path: path, Content: content}, err
+	}
+}
+
+// ListObjectsContext lists the objects at prefix in two pages, like the SDKs of storage services do
+func (b httpBackend) ListObjectsContext(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	for _, marker := range []string{"", "mychart-0.1.0.tgz"} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+"/?prefix="+prefix+"&marker="+marker, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := b.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		objects = append(objects, Object{Path: marker})
+	}
+	return objects, nil
+}
+
+type TracingTestSuite struct {
+	suite.Suite
+	TempDirectory string
+	Local         *LocalFilesystemBackend
+	Recorder      *tracetest.SpanRecorder
+	Provider      *sdktrace.TracerProvider
+}
+
+func (suite *TracingTestSuite) SetupTest() {
+	timestamp := time.Now().Format("20060102150405.000000")
+	suite.TempDirectory = fmt.Sprintf("../../.test/storage-tracing/%s", timestamp)
+	suite.Local = NewLocalFilesystemBackend(suite.TempDirectory)
+	suite.Recorder = tracetest.NewSpanRecorder()
+	suite.Provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(suite.Recorder))
+}
+
+func (suite *TracingTestSuite) TearDownTest() {
+	os.RemoveAll(suite.TempDirectory)
+}
+
+// attributes returns the attributes of a span by key
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	values := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		values[kv.Key] = kv.Value
+	}
+	return values
+}
+
+func (suite *TracingTestSuite) TestTracingBackend() {
+	traced := NewTracingBackend(suite.Local, suite.Provider)
+	ctx, parent := suite.Provider.Tracer("test").Start(context.Background(), "parent")
+
+	suite.Nil(traced.PutObjectContext(ctx, "mychart-0.1.0.tgz", []byte("mychart")))
+	_, err := traced.GetObjectContext(ctx, "mychart-0.1.0.tgz")
+	suite.Nil(err, "no error getting object through tracing backend")
+	_, err = traced.GetObject("missing.tgz")
+	suite.True(errors.Is(err, ErrObjectNotFound))
+	_, err = traced.ListObjects("")
+	suite.Nil(err)
+	suite.Nil(traced.DeleteObject("mychart-0.1.0.tgz"))
+	parent.End()
+
+	spans := suite.Recorder.Ended()
+	suite.Len(spans, 6)
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	suite.Equal([]string{"storage.PutObject", "storage.GetObject", "storage.GetObject", "storage.ListObjects",
+		"storage.DeleteObject", "parent"}, names, "every operation has a span")
+
+	put := attributes(spans[0])
+	suite.Equal("LocalFilesystem", put[AttributeBackend].AsString())
+	suite.Equal("mychart-0.1.0.tgz", put[AttributeKey].AsString())
+	suite.Equal(int64(len("mychart")), put[AttributeBytes].AsInt64())
+	suite.Equal(OutcomeSuccess, put[AttributeOutcome].AsString())
+	suite.Equal(parent.SpanContext().SpanID(), spans[0].Parent().SpanID(), "span is a child of the span of the context")
+	suite.Equal(int64(len("mychart")), attributes(spans[1])[AttributeBytes].AsInt64())
+
+	suite.Equal(otelcodes.Error, spans[2].Status().Code, "failed operation has an error status")
+	suite.Equal(OutcomeNotFound, attributes(spans[2])[AttributeOutcome].AsString())
+	suite.False(spans[2].Parent().IsValid(), "operation without a context starts a new trace")
+	suite.Equal(int64(1), attributes(spans[3])[AttributeObjects].AsInt64())
+}
+
+func (suite *TracingTestSuite) TestHTTPRequests() {
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(suite.Provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	var mu sync.Mutex
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		if len(traceparents) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("mychart"))
+	}))
+	defer server.Close()
+
+	backend := httpBackend{
+		contextAdapter: contextAdapter{Backend: suite.Local},
+		url:            server.URL,
+		client:         &http.Client{Transport: tracingTransport(http.DefaultTransport)},
+	}
+	object, err := NewTracingBackend(backend, suite.Provider).GetObject("mychart-0.1.0.tgz")
+	suite.Nil(err, "no error getting object over HTTP")
+	suite.Equal([]byte("mychart"), object.Content)
+
+	spans := suite.Recorder.Ended()
+	operation := spans[len(spans)-1]
+	suite.Equal("storage.GetObject", operation.Name())
+	suite.Equal(int64(2), attributes(operation)[AttributeHTTPRequests].AsInt64(), "HTTP requests are counted")
+	suite.Equal(int64(1), attributes(operation)[AttributeSDKRetries].AsInt64(), "SDK retries are counted")
+	suite.Len(spans, 3, "HTTP requests are traced")
+	for _, span := range spans[:2] {
+		suite.Equal(operation.SpanContext().SpanID(), span.Parent().SpanID(), "HTTP request span is a child of the operation span")
+	}
+	suite.Len(traceparents, 2)
+	for _, traceparent := range traceparents {
+		suite.Contains(traceparent, operation.SpanContext().TraceID().String(), "trace context is propagated to the server")
+	}
+
+	_, err = NewTracingBackend(backend, suite.Provider).ListObjects("")
+	suite.Nil(err, "no error listing objects over HTTP")
+	spans = suite.Recorder.Ended()
+	operation = spans[len(spans)-1]
+	suite.Equal("storage.ListObjects", operation.Name())
+	suite.Equal(int64(2), attributes(operation)[AttributeHTTPRequests].AsInt64(), "requests for every page are counted")
+	suite.Equal(int64(0), attributes(operation)[AttributeSDKRetries].AsInt64(), "pages are not counted as retries")
+}
+
+func (suite *TracingTestSuite) TestBackendBucket() {
+	suite.Equal("charts", backendBucket(&AmazonS3Backend{Bucket: "charts"}))
+	suite.Equal("charts", backendBucket(&OpenstackOSBackend{Container: "charts"}))
+	suite.Equal("", backendBucket(suite.Local), "local filesystem has no bucket")
+}
+
+func TestTracingTestSuite(t *testing.T) {
+	suite.Run(t, new(TracingTestSuite))
+}